@@ -3,6 +3,8 @@ package drive
 import (
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
 	"text/tabwriter"
 )
 
@@ -12,7 +14,12 @@ type AboutArgs struct {
 }
 
 func (self *Drive) About(args AboutArgs) (err error) {
-	about, err := self.service.About.Get().Fields("maxImportSizes", "maxUploadSize", "storageQuota", "user").Do()
+	google, err := self.googleOnly("about")
+	if err != nil {
+		return err
+	}
+
+	about, err := google.service.About.Get().Fields("maxImportSizes", "maxUploadSize", "storageQuota", "user").Do()
 	if err != nil {
 		return fmt.Errorf("Failed to get about: %s", err)
 	}
@@ -20,12 +27,48 @@ func (self *Drive) About(args AboutArgs) (err error) {
 	user := about.User
 	quota := about.StorageQuota
 
-	fmt.Fprintf(args.Out, "User: %s, %s\n", user.DisplayName, user.EmailAddress)
-	fmt.Fprintf(args.Out, "Used: %s\n", formatSize(quota.Usage, args.SizeInBytes))
-	fmt.Fprintf(args.Out, "Free: %s\n", formatSize(quota.Limit-quota.Usage, args.SizeInBytes))
-	fmt.Fprintf(args.Out, "Total: %s\n", formatSize(quota.Limit, args.SizeInBytes))
-	fmt.Fprintf(args.Out, "Max upload size: %s\n", formatSize(about.MaxUploadSize, args.SizeInBytes))
-	return
+	record := AboutRecord{
+		Name:          user.DisplayName,
+		Email:         user.EmailAddress,
+		Used:          quota.Usage,
+		Free:          quota.Limit - quota.Usage,
+		Total:         quota.Limit,
+		MaxUploadSize: about.MaxUploadSize,
+	}
+
+	return self.render(args.Out, Result{
+		Value:  record,
+		Header: []string{"Name", "Email", "Used", "Free", "Total", "MaxUploadSize"},
+		Rows: [][]string{{
+			record.Name,
+			record.Email,
+			strconv.FormatInt(record.Used, 10),
+			strconv.FormatInt(record.Free, 10),
+			strconv.FormatInt(record.Total, 10),
+			strconv.FormatInt(record.MaxUploadSize, 10),
+		}},
+		Table: func(w io.Writer) {
+			fmt.Fprintf(w, "User: %s, %s\n", record.Name, record.Email)
+			fmt.Fprintf(w, "Used: %s\n", formatSize(record.Used, args.SizeInBytes))
+			fmt.Fprintf(w, "Free: %s\n", formatSize(record.Free, args.SizeInBytes))
+			fmt.Fprintf(w, "Total: %s\n", formatSize(record.Total, args.SizeInBytes))
+			fmt.Fprintf(w, "Max upload size: %s\n", formatSize(record.MaxUploadSize, args.SizeInBytes))
+		},
+	})
+}
+
+type AboutRecord struct {
+	Name          string `json:"name"`
+	Email         string `json:"emailAddress"`
+	Used          int64  `json:"used"`
+	Free          int64  `json:"free"`
+	Total         int64  `json:"total"`
+	MaxUploadSize int64  `json:"maxUploadSize"`
+}
+
+type FormatRecord struct {
+	From string   `json:"from"`
+	To   []string `json:"to"`
 }
 
 type AboutImportArgs struct {
@@ -33,12 +76,16 @@ type AboutImportArgs struct {
 }
 
 func (self *Drive) AboutImport(args AboutImportArgs) (err error) {
-	about, err := self.service.About.Get().Fields("importFormats").Do()
+	google, err := self.googleOnly("import formats")
+	if err != nil {
+		return err
+	}
+
+	about, err := google.service.About.Get().Fields("importFormats").Do()
 	if err != nil {
 		return fmt.Errorf("Failed to get about: %s", err)
 	}
-	printAboutFormats(args.Out, about.ImportFormats)
-	return
+	return self.renderFormats(args.Out, about.ImportFormats)
 }
 
 type AboutExportArgs struct {
@@ -46,12 +93,34 @@ type AboutExportArgs struct {
 }
 
 func (self *Drive) AboutExport(args AboutExportArgs) (err error) {
-	about, err := self.service.About.Get().Fields("exportFormats").Do()
+	google, err := self.googleOnly("export formats")
+	if err != nil {
+		return err
+	}
+
+	about, err := google.service.About.Get().Fields("exportFormats").Do()
 	if err != nil {
 		return fmt.Errorf("Failed to get about: %s", err)
 	}
-	printAboutFormats(args.Out, about.ExportFormats)
-	return
+	return self.renderFormats(args.Out, about.ExportFormats)
+}
+
+func (self *Drive) renderFormats(out io.Writer, formats map[string][]string) error {
+	records := make([]FormatRecord, 0, len(formats))
+	var rows [][]string
+	for from, toFormats := range formats {
+		records = append(records, FormatRecord{from, toFormats})
+		rows = append(rows, []string{from, strings.Join(toFormats, " ")})
+	}
+
+	return self.render(out, Result{
+		Value:  records,
+		Header: []string{"From", "To"},
+		Rows:   rows,
+		Table: func(w io.Writer) {
+			printAboutFormats(w, formats)
+		},
+	})
 }
 
 func printAboutFormats(out io.Writer, formats map[string][]string) {
@@ -0,0 +1,99 @@
+package drive
+
+import (
+	"fmt"
+	"golang.org/x/net/context"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"io"
+)
+
+// Backend is the storage that files are listed, transferred and synced
+// against. Files are described by drive metadata regardless of where they
+// are stored, backends without a search api of their own can evaluate
+// queries with the query package
+type Backend interface {
+	// Name used in messages, i.e. 'google drive'
+	Name() string
+
+	// Id of the root directory
+	RootId() string
+
+	GetFile(id string, fields ...googleapi.Field) (*drive.File, error)
+
+	// Calls fn with every page of files that matches the query
+	ListFiles(query FileQuery, fn func(*drive.FileList) error) error
+
+	CreateFile(f *drive.File, media *Media, fields ...googleapi.Field) (*drive.File, error)
+
+	// Copies the content and metadata of a file that is not a directory,
+	// the metadata of f overrides the copied values
+	CopyFile(id string, f *drive.File, fields ...googleapi.Field) (*drive.File, error)
+
+	// Files are moved to and restored from the trash by updating the trashed field
+	UpdateFile(id string, f *drive.File, update FileUpdate) (*drive.File, error)
+
+	// Removes keys from the properties and appProperties of a file,
+	// updates can only add or change them
+	DeleteProperties(id string, properties, appProperties []string) error
+
+	// Permanently deletes the file, skipping the trash
+	DeleteFile(id string) error
+
+	// Permanently deletes all trashed files
+	EmptyTrash() error
+
+	// Opens the file content starting at offset. The returned offset
+	// is zero if the backend ignored it and returns the whole file
+	Download(ctx context.Context, id string, offset int64) (io.ReadCloser, int64, error)
+
+	GetStartPageToken(driveId string) (string, error)
+	ListChanges(query ChangeQuery) (*drive.ChangeList, error)
+
+	// Returns the storage limit and usage in bytes, a limit of zero means unlimited
+	Quota() (int64, int64, error)
+}
+
+// Returned by ListFiles callbacks to stop listing without an error
+var errStopListing = fmt.Errorf("Stop listing")
+
+type FileQuery struct {
+	Query     string
+	Fields    []googleapi.Field
+	SortOrder string
+	PageSize  int64
+	// Shared drive to list, defaults to the drive given to SetDriveId
+	DriveId string
+}
+
+type ChangeQuery struct {
+	PageToken         string
+	PageSize          int64
+	Fields            []googleapi.Field
+	RestrictToMyDrive bool
+	// Shared drive to list, defaults to the drive given to SetDriveId
+	DriveId string
+}
+
+// Content of a created or updated file
+type Media struct {
+	Reader    io.Reader
+	ChunkSize int64
+	Context   context.Context
+}
+
+type FileUpdate struct {
+	AddParents    []string
+	RemoveParents []string
+	Media         *Media
+	Fields        []googleapi.Field
+}
+
+// Returns the google drive backend for features that only exists there,
+// like sharing and revisions
+func (self *Drive) googleOnly(feature string) (*googleBackend, error) {
+	if self.google == nil {
+		return nil, fmt.Errorf("The %s backend does not support %s", self.backend.Name(), feature)
+	}
+	return self.google, nil
+}
@@ -0,0 +1,235 @@
+package drive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type googleBackend struct {
+	service *drive.Service
+	client  *http.Client
+	driveId string
+}
+
+func newGoogleBackend(client *http.Client, baseUrl string) (*googleBackend, error) {
+	service, err := drive.New(client)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(baseUrl, "/") {
+		baseUrl += "/"
+	}
+	service.BasePath = baseUrl + "drive/v3/"
+
+	return &googleBackend{service: service, client: client}, nil
+}
+
+func (self *googleBackend) Name() string {
+	return "google drive"
+}
+
+// The root directory is the shared drive itself if one is set
+func (self *googleBackend) RootId() string {
+	if self.driveId != "" {
+		return self.driveId
+	}
+	return "root"
+}
+
+func (self *googleBackend) GetFile(id string, fields ...googleapi.Field) (*drive.File, error) {
+	call := self.service.Files.Get(id)
+	if len(fields) > 0 {
+		call = call.Fields(fields...)
+	}
+	return call.Do(self.fileOptions()...)
+}
+
+func (self *googleBackend) ListFiles(query FileQuery, fn func(*drive.FileList) error) error {
+	call := self.service.Files.List().Q(query.Query).OrderBy(query.SortOrder)
+	if len(query.Fields) > 0 {
+		call.Fields(query.Fields...)
+	}
+	if query.PageSize > 0 {
+		call.PageSize(query.PageSize)
+	}
+
+	// Same as FilesListCall.Pages, but passes the call options on to every request
+	for {
+		fl, err := call.Do(self.listOptions(query.DriveId)...)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(fl); err != nil {
+			return err
+		}
+
+		if fl.NextPageToken == "" {
+			return nil
+		}
+		call.PageToken(fl.NextPageToken)
+	}
+}
+
+func (self *googleBackend) CreateFile(f *drive.File, media *Media, fields ...googleapi.Field) (*drive.File, error) {
+	call := self.service.Files.Create(f)
+	if len(fields) > 0 {
+		call = call.Fields(fields...)
+	}
+	if media != nil {
+		call = call.Context(media.Context).Media(media.Reader, googleapi.ChunkSize(int(media.ChunkSize)))
+	}
+	return call.Do(self.fileOptions()...)
+}
+
+func (self *googleBackend) CopyFile(id string, f *drive.File, fields ...googleapi.Field) (*drive.File, error) {
+	call := self.service.Files.Copy(id, f)
+	if len(fields) > 0 {
+		call = call.Fields(fields...)
+	}
+	return call.Do(self.fileOptions()...)
+}
+
+func (self *googleBackend) UpdateFile(id string, f *drive.File, update FileUpdate) (*drive.File, error) {
+	call := self.service.Files.Update(id, f)
+	if len(update.Fields) > 0 {
+		call = call.Fields(update.Fields...)
+	}
+	if len(update.AddParents) > 0 {
+		call = call.AddParents(strings.Join(update.AddParents, ","))
+	}
+	if len(update.RemoveParents) > 0 {
+		call = call.RemoveParents(strings.Join(update.RemoveParents, ","))
+	}
+	if update.Media != nil {
+		call = call.Context(update.Media.Context).Media(update.Media.Reader, googleapi.ChunkSize(int(update.Media.ChunkSize)))
+	}
+	return call.Do(self.fileOptions()...)
+}
+
+// The drive package can not send null values, which is how drive removes
+// a property, so the request is made directly
+func (self *googleBackend) DeleteProperties(id string, properties, appProperties []string) error {
+	body := map[string]map[string]*string{}
+	for field, keys := range map[string][]string{"properties": properties, "appProperties": appProperties} {
+		if len(keys) == 0 {
+			continue
+		}
+
+		body[field] = map[string]*string{}
+		for _, key := range keys {
+			body[field][key] = nil
+		}
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	params.Set("fields", "id")
+	self.setFileOptions(params)
+
+	urls := googleapi.ResolveRelative(self.service.BasePath, "files/"+id)
+	req, err := http.NewRequest("PATCH", urls+"?"+params.Encode(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := self.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return googleapi.CheckResponse(res)
+}
+
+func (self *googleBackend) DeleteFile(id string) error {
+	return self.service.Files.Delete(id).Do(self.fileOptions()...)
+}
+
+func (self *googleBackend) EmptyTrash() error {
+	opts := []googleapi.CallOption{}
+	if self.driveId != "" {
+		opts = append(opts, queryParam{"driveId", self.driveId})
+	}
+	return self.service.Files.EmptyTrash().Do(opts...)
+}
+
+// A range request is used if offset is larger than zero
+func (self *googleBackend) Download(ctx context.Context, id string, offset int64) (io.ReadCloser, int64, error) {
+	if offset == 0 {
+		res, err := self.service.Files.Get(id).Context(ctx).Download(self.fileOptions()...)
+		if err != nil {
+			return nil, 0, err
+		}
+		return res.Body, 0, nil
+	}
+
+	params := url.Values{}
+	params.Set("alt", "media")
+	self.setFileOptions(params)
+
+	urls := googleapi.ResolveRelative(self.service.BasePath, "files/"+id)
+	req, err := http.NewRequest("GET", urls+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+
+	res, err := ctxhttp.Do(ctx, self.client, req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := googleapi.CheckResponse(res); err != nil {
+		res.Body.Close()
+		return nil, 0, err
+	}
+
+	if res.StatusCode != http.StatusPartialContent {
+		return res.Body, 0, nil
+	}
+
+	return res.Body, offset, nil
+}
+
+func (self *googleBackend) GetStartPageToken(driveId string) (string, error) {
+	res, err := self.service.Changes.GetStartPageToken().Do(self.changesOptions(driveId)...)
+	if err != nil {
+		return "", err
+	}
+	return res.StartPageToken, nil
+}
+
+func (self *googleBackend) ListChanges(query ChangeQuery) (*drive.ChangeList, error) {
+	call := self.service.Changes.List(query.PageToken).PageSize(query.PageSize).Fields(query.Fields...)
+
+	// Changes in shared drives are not part of my drive
+	if query.RestrictToMyDrive && self.sharedDriveId(query.DriveId) == "" {
+		call = call.RestrictToMyDrive(true)
+	}
+
+	return call.Do(self.changesOptions(query.DriveId)...)
+}
+
+func (self *googleBackend) Quota() (int64, int64, error) {
+	about, err := self.service.About.Get().Fields("storageQuota").Do()
+	if err != nil {
+		return 0, 0, err
+	}
+	return about.StorageQuota.Limit, about.StorageQuota.Usage, nil
+}
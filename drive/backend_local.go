@@ -0,0 +1,1055 @@
+package drive
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/prasmussen/gdrive/drive/query"
+	"golang.org/x/net/context"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"io"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Name of the file in the root directory that holds the metadata of a local backend
+const LocalIndexFilename = ".gdrive-backend.json"
+
+const localRootId = "root"
+
+// Trashed files are moved to a directory named by their id below this directory
+const localTrashDir = ".gdrive-trash"
+
+// Content is written to a tmp file below this directory before it is moved into place
+const localUploadDir = ".gdrive-uploads"
+
+// The oldest changes are dropped when the change log grows beyond this,
+// page tokens that point to dropped changes are rejected as invalid
+var localMaxChanges = 10000
+
+// LocalBackend stores files in a directory on disk, the directory tree is
+// the file tree. Ids, metadata and the change log are kept in an index file
+// in the root directory, which is reconciled with the disk when opened
+type LocalBackend struct {
+	mu    sync.Mutex
+	root  string
+	index localIndex
+}
+
+type localIndex struct {
+	Files   map[string]*drive.File `json:"files"`
+	Changes []localChange          `json:"changes"`
+
+	// Number of changes dropped from the start of the log
+	DroppedChanges int `json:"droppedChanges,omitempty"`
+}
+
+type localChange struct {
+	FileId  string `json:"fileId"`
+	Removed bool   `json:"removed,omitempty"`
+	Time    string `json:"time"`
+}
+
+func NewLocalBackend(dir string) (*LocalBackend, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to determine absolute path: %s", err)
+	}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open backend directory: %s", err)
+	}
+
+	if !info.IsDir() {
+		return nil, fmt.Errorf("Backend path '%s' is not a directory", root)
+	}
+
+	// Leftovers from interrupted uploads
+	os.RemoveAll(filepath.Join(root, localUploadDir))
+
+	self := &LocalBackend{root: root}
+	if err := self.loadIndex(); err != nil {
+		return nil, err
+	}
+
+	if err := self.reconcile(); err != nil {
+		return nil, err
+	}
+
+	return self, self.saveIndex()
+}
+
+func (self *LocalBackend) Name() string {
+	return "local"
+}
+
+func (self *LocalBackend) RootId() string {
+	return localRootId
+}
+
+func (self *LocalBackend) GetFile(id string, fields ...googleapi.Field) (*drive.File, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	f, err := self.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	return self.resource(f)
+}
+
+func (self *LocalBackend) ListFiles(q FileQuery, fn func(*drive.FileList) error) error {
+	files, err := self.findFiles(q)
+	if err != nil {
+		return err
+	}
+
+	pageSize := int(q.PageSize)
+	if pageSize <= 0 {
+		pageSize = 100
+	}
+
+	for start := 0; ; start += pageSize {
+		end := min(start+pageSize, len(files))
+
+		fl := &drive.FileList{Files: files[start:end]}
+		if end < len(files) {
+			fl.NextPageToken = strconv.Itoa(end)
+		}
+
+		if err := fn(fl); err != nil {
+			return err
+		}
+
+		if fl.NextPageToken == "" {
+			return nil
+		}
+	}
+}
+
+func (self *LocalBackend) findFiles(q FileQuery) ([]*drive.File, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	match, err := query.Parse(q.Query, localEnv{self})
+	if err != nil {
+		return nil, fmt.Errorf("Invalid query: %s", err)
+	}
+
+	var files []*drive.File
+	for _, f := range self.index.Files {
+		if f.Id != localRootId && match(f) {
+			r, err := self.resource(f)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, r)
+		}
+	}
+
+	// Stable order for queries without sort keys
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Id < files[j].Id
+	})
+
+	if err := query.Sort(files, q.SortOrder); err != nil {
+		return nil, fmt.Errorf("Invalid sort order: %s", err)
+	}
+
+	return files, nil
+}
+
+func (self *LocalBackend) CreateFile(f *drive.File, media *Media, fields ...googleapi.Field) (*drive.File, error) {
+	var content *localContent
+	if !isDir(f) {
+		var reader io.Reader = bytes.NewReader(nil)
+		if media != nil {
+			reader = media.Reader
+		}
+
+		// The content is written before taking the lock, as it takes as long as the upload
+		var err error
+		content, err = self.writeTmpContent(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer content.remove()
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return self.create(f, content)
+}
+
+func (self *LocalBackend) CopyFile(id string, f *drive.File, fields ...googleapi.Field) (*drive.File, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	src, err := self.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if isDir(src) {
+		return nil, fmt.Errorf("'%s' is a directory, directories can not be copied", src.Name)
+	}
+
+	dst := &drive.File{
+		Name:          src.Name,
+		MimeType:      src.MimeType,
+		Description:   src.Description,
+		Parents:       src.Parents,
+		Properties:    src.Properties,
+		AppProperties: src.AppProperties,
+	}
+
+	if f.Name != "" {
+		dst.Name = f.Name
+	}
+	if len(f.Parents) > 0 {
+		dst.Parents = f.Parents
+	}
+	if f.Description != "" {
+		dst.Description = f.Description
+	}
+	dst.Properties = mergeLocalProperties(dst.Properties, f.Properties)
+	dst.AppProperties = mergeLocalProperties(dst.AppProperties, f.AppProperties)
+
+	srcPath, err := self.path(src)
+	if err != nil {
+		return nil, err
+	}
+
+	srcFile, err := os.Open(srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open file: %s", err)
+	}
+	defer srcFile.Close()
+
+	content, err := self.writeTmpContent(srcFile)
+	if err != nil {
+		return nil, err
+	}
+	defer content.remove()
+
+	return self.create(dst, content)
+}
+
+func (self *LocalBackend) create(f *drive.File, content *localContent) (*drive.File, error) {
+	if f.Name == "" {
+		return nil, fmt.Errorf("File name is required")
+	}
+
+	parentId := localRootId
+	if len(f.Parents) > 0 {
+		parentId = f.Parents[0]
+	}
+
+	parent, err := self.lookup(parentId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isDir(parent) {
+		return nil, fmt.Errorf("Parent '%s' is not a directory", parent.Name)
+	}
+
+	parentPath, err := self.path(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	fpath := filepath.Join(parentPath, f.Name)
+	if _, err := os.Lstat(fpath); err == nil {
+		return nil, fmt.Errorf("File '%s' already exists", self.relPath(fpath))
+	}
+
+	now := localTime(time.Now())
+	created := &drive.File{
+		Id:            newLocalId(),
+		Name:          f.Name,
+		MimeType:      f.MimeType,
+		Description:   f.Description,
+		Parents:       []string{parent.Id},
+		Properties:    f.Properties,
+		AppProperties: f.AppProperties,
+		CreatedTime:   now,
+		ModifiedTime:  now,
+		Owners:        []*drive.User{{Me: true, DisplayName: "me"}},
+	}
+
+	if f.ModifiedTime != "" {
+		created.ModifiedTime = f.ModifiedTime
+	}
+
+	if isDir(f) {
+		if err := os.Mkdir(fpath, 0755); err != nil {
+			return nil, fmt.Errorf("Failed to create directory: %s", err)
+		}
+	} else {
+		if created.MimeType == "" {
+			created.MimeType = localMimeType(f.Name)
+		}
+
+		if err := content.place(created, fpath); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := setLocalModTime(fpath, created.ModifiedTime); err != nil {
+		return nil, err
+	}
+
+	self.index.Files[created.Id] = created
+	self.recordChange(created.Id, false)
+	return self.saveResource(created)
+}
+
+func (self *LocalBackend) UpdateFile(id string, f *drive.File, update FileUpdate) (*drive.File, error) {
+	// The content is written before taking the lock, as it takes as long as the upload
+	var content *localContent
+	if update.Media != nil {
+		var err error
+		content, err = self.writeTmpContent(update.Media.Reader)
+		if err != nil {
+			return nil, err
+		}
+		defer content.remove()
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	existing, err := self.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	trash := f.Trashed || hasForceSendField(f, "Trashed")
+	if existing.Id == localRootId && (f.Name != "" || len(update.AddParents) > 0 || trash) {
+		return nil, fmt.Errorf("The root directory can not be moved or trashed")
+	}
+
+	if f.MimeType != "" && isDir(existing) != (f.MimeType == DirectoryMimeType) {
+		return nil, fmt.Errorf("The mime type of '%s' can not change between file and directory", existing.Name)
+	}
+
+	oldPath, err := self.path(existing)
+	if err != nil {
+		return nil, err
+	}
+	name, parents, trashed := existing.Name, existing.Parents, existing.Trashed
+
+	// Move, rename, trash or restore
+	if len(update.AddParents) > 0 {
+		parent, err := self.lookup(update.AddParents[0])
+		if err != nil {
+			return nil, err
+		}
+
+		descendant, err := self.isDescendant(parent, existing.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		if !isDir(parent) || parent.Id == existing.Id || descendant {
+			return nil, fmt.Errorf("Can not move '%s' into '%s'", existing.Name, parent.Name)
+		}
+		existing.Parents = []string{parent.Id}
+	}
+
+	if f.Name != "" {
+		existing.Name = f.Name
+	}
+
+	if trash {
+		existing.Trashed = f.Trashed
+	}
+
+	newPath, err := self.path(existing)
+	if err != nil {
+		existing.Name, existing.Parents, existing.Trashed = name, parents, trashed
+		return nil, err
+	}
+
+	if newPath != oldPath {
+		if err := self.rename(oldPath, newPath); err != nil {
+			existing.Name, existing.Parents, existing.Trashed = name, parents, trashed
+			return nil, err
+		}
+	}
+
+	if content != nil {
+		if isDir(existing) {
+			return nil, fmt.Errorf("'%s' is a directory", existing.Name)
+		}
+
+		if err := content.place(existing, newPath); err != nil {
+			return nil, err
+		}
+		existing.ModifiedTime = localTime(time.Now())
+	}
+
+	if f.Description != "" {
+		existing.Description = f.Description
+	}
+	if f.Starred || hasForceSendField(f, "Starred") {
+		existing.Starred = f.Starred
+	}
+	if f.MimeType != "" {
+		existing.MimeType = f.MimeType
+	}
+	existing.Properties = mergeLocalProperties(existing.Properties, f.Properties)
+	existing.AppProperties = mergeLocalProperties(existing.AppProperties, f.AppProperties)
+
+	if f.ModifiedTime != "" {
+		existing.ModifiedTime = f.ModifiedTime
+	}
+
+	if err := setLocalModTime(newPath, existing.ModifiedTime); err != nil {
+		return nil, err
+	}
+
+	self.recordChange(existing.Id, false)
+	return self.saveResource(existing)
+}
+
+func (self *LocalBackend) DeleteProperties(id string, properties, appProperties []string) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	f, err := self.lookup(id)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range properties {
+		delete(f.Properties, key)
+	}
+	for _, key := range appProperties {
+		delete(f.AppProperties, key)
+	}
+
+	self.recordChange(f.Id, false)
+	return self.saveIndex()
+}
+
+func (self *LocalBackend) DeleteFile(id string) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	f, err := self.lookup(id)
+	if err != nil {
+		return err
+	}
+
+	if f.Id == localRootId {
+		return fmt.Errorf("The root directory can not be deleted")
+	}
+
+	if err := self.remove(f); err != nil {
+		return err
+	}
+	return self.saveIndex()
+}
+
+// Removes the file and everything below it from disk and from the index.
+// The descendants are collected first so that nothing is removed from
+// disk if the index is inconsistent
+func (self *LocalBackend) remove(f *drive.File) error {
+	fpath, err := self.path(f)
+	if err != nil {
+		return err
+	}
+
+	var ids []string
+	for _, child := range self.index.Files {
+		descendant, err := self.isDescendant(child, f.Id)
+		if err != nil {
+			return err
+		}
+		if descendant {
+			ids = append(ids, child.Id)
+		}
+	}
+
+	if err := os.RemoveAll(fpath); err != nil {
+		return fmt.Errorf("Failed to delete file: %s", err)
+	}
+
+	for _, id := range append(ids, f.Id) {
+		delete(self.index.Files, id)
+		self.recordChange(id, true)
+	}
+	return nil
+}
+
+func (self *LocalBackend) EmptyTrash() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	var trashed []string
+	for _, f := range self.index.Files {
+		if f.Trashed {
+			trashed = append(trashed, f.Id)
+		}
+	}
+
+	for _, id := range trashed {
+		// Already removed along with a trashed ancestor
+		f, ok := self.index.Files[id]
+		if !ok {
+			continue
+		}
+
+		if err := self.remove(f); err != nil {
+			return err
+		}
+	}
+
+	// Leftovers from interrupted operations
+	os.RemoveAll(filepath.Join(self.root, localTrashDir))
+
+	return self.saveIndex()
+}
+
+func (self *LocalBackend) Download(ctx context.Context, id string, offset int64) (io.ReadCloser, int64, error) {
+	self.mu.Lock()
+	f, err := self.lookup(id)
+	var fpath string
+	if err == nil {
+		fpath, err = self.path(f)
+	}
+	self.mu.Unlock()
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if isDir(f) {
+		return nil, 0, fmt.Errorf("'%s' is a directory", f.Name)
+	}
+
+	file, err := os.Open(fpath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+
+	return file, offset, nil
+}
+
+// Page tokens are positions in the change log, starting at one.
+// There are no shared drives, so the drive id is ignored
+func (self *LocalBackend) GetStartPageToken(driveId string) (string, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return self.pageToken(len(self.index.Changes)), nil
+}
+
+// Returns the page token of the change at index i of the log
+func (self *LocalBackend) pageToken(i int) string {
+	return strconv.Itoa(self.index.DroppedChanges + i + 1)
+}
+
+func (self *LocalBackend) ListChanges(q ChangeQuery) (*drive.ChangeList, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	// Tokens of dropped changes are invalid as well
+	start, err := strconv.Atoi(q.PageToken)
+	start -= self.index.DroppedChanges
+	if err != nil || start < 1 || start > len(self.index.Changes)+1 {
+		return nil, fmt.Errorf("Invalid page token: %s", q.PageToken)
+	}
+
+	pageSize := int(q.PageSize)
+	if pageSize <= 0 {
+		pageSize = 100
+	}
+
+	list := &drive.ChangeList{}
+
+	i := start - 1
+	for ; i < len(self.index.Changes) && len(list.Changes) < pageSize; i++ {
+		c := self.index.Changes[i]
+
+		// Changes always reflect the current state of the file
+		entry := &drive.Change{FileId: c.FileId, Time: c.Time, Removed: true}
+		if f, ok := self.index.Files[c.FileId]; ok && !c.Removed {
+			r, err := self.resource(f)
+			if err != nil {
+				return nil, err
+			}
+			entry.Removed = false
+			entry.File = r
+		}
+		list.Changes = append(list.Changes, entry)
+	}
+
+	if i >= len(self.index.Changes) {
+		list.NewStartPageToken = self.pageToken(len(self.index.Changes))
+	} else {
+		list.NextPageToken = self.pageToken(i)
+	}
+
+	return list, nil
+}
+
+// The available disk space is not tracked, so the quota is unlimited
+func (self *LocalBackend) Quota() (int64, int64, error) {
+	return 0, 0, nil
+}
+
+func (self *LocalBackend) lookup(id string) (*drive.File, error) {
+	f, ok := self.index.Files[id]
+	if !ok {
+		return nil, fmt.Errorf("File not found: %s", id)
+	}
+	return f, nil
+}
+
+// Returns the absolute path of the file on disk
+func (self *LocalBackend) path(f *drive.File) (string, error) {
+	var names []string
+	for f.Id != localRootId {
+		if f.Trashed {
+			return filepath.Join(append([]string{self.root, localTrashDir, f.Id}, names...)...), nil
+		}
+		names = append([]string{f.Name}, names...)
+
+		parent, err := self.parent(f)
+		if err != nil {
+			return "", err
+		}
+		f = parent
+	}
+	return filepath.Join(append([]string{self.root}, names...)...), nil
+}
+
+// Returns the parent of f, an error is returned if the parent is not
+// in the index as the path of the file can not be determined
+func (self *LocalBackend) parent(f *drive.File) (*drive.File, error) {
+	if len(f.Parents) == 0 {
+		return nil, fmt.Errorf("Broken backend index, '%s' has no parent", f.Name)
+	}
+
+	parent, ok := self.index.Files[f.Parents[0]]
+	if !ok {
+		return nil, fmt.Errorf("Broken backend index, the parent of '%s' is missing", f.Name)
+	}
+	return parent, nil
+}
+
+// Returns true if the file, or any of its ancestors, is trashed
+func (self *LocalBackend) isTrashed(f *drive.File) (bool, error) {
+	for f.Id != localRootId {
+		if f.Trashed {
+			return true, nil
+		}
+
+		parent, err := self.parent(f)
+		if err != nil {
+			return false, err
+		}
+		f = parent
+	}
+	return false, nil
+}
+
+func (self *LocalBackend) rename(oldPath, newPath string) error {
+	if _, err := os.Lstat(newPath); err == nil {
+		return fmt.Errorf("File '%s' already exists", self.relPath(newPath))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return fmt.Errorf("Failed to move file: %s", err)
+	}
+
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return fmt.Errorf("Failed to move file: %s", err)
+	}
+	return nil
+}
+
+func (self *LocalBackend) relPath(fpath string) string {
+	rel, err := filepath.Rel(self.root, fpath)
+	if err != nil {
+		return fpath
+	}
+	return rel
+}
+
+// Returns true if ancestorId is one of the parents of f, all the way up to the root
+func (self *LocalBackend) isDescendant(f *drive.File, ancestorId string) (bool, error) {
+	for f.Id != localRootId {
+		parent, err := self.parent(f)
+		if err != nil {
+			return false, err
+		}
+
+		if parent.Id == ancestorId {
+			return true, nil
+		}
+		f = parent
+	}
+	return false, nil
+}
+
+// Content written to a tmp file in the backend directory
+type localContent struct {
+	path string
+	size int64
+	md5  string
+}
+
+// Writes the content to a tmp file, which is moved into place with place.
+// The index is not touched, so the lock does not need to be held
+func (self *LocalBackend) writeTmpContent(reader io.Reader) (*localContent, error) {
+	dir := filepath.Join(self.root, localUploadDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("Failed to create file: %s", err)
+	}
+
+	tmp, err := ioutil.TempFile(dir, "upload")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create file: %s", err)
+	}
+
+	hasher := md5.New()
+	size, err := io.Copy(tmp, io.TeeReader(reader, hasher))
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("Failed to write file: %s", err)
+	}
+
+	return &localContent{
+		path: tmp.Name(),
+		size: size,
+		md5:  hex.EncodeToString(hasher.Sum(nil)),
+	}, nil
+}
+
+// Replaces the file with the content and updates the size and md5 of the metadata
+func (self *localContent) place(f *drive.File, fpath string) error {
+	if err := os.Rename(self.path, fpath); err != nil {
+		return fmt.Errorf("Failed to write file: %s", err)
+	}
+
+	f.Size = self.size
+	f.Md5Checksum = self.md5
+	return nil
+}
+
+// Removes the tmp file if it was not moved into place
+func (self *localContent) remove() {
+	os.Remove(self.path)
+}
+
+func (self *LocalBackend) recordChange(id string, removed bool) {
+	self.index.Changes = append(self.index.Changes, localChange{
+		FileId:  id,
+		Removed: removed,
+		Time:    localTime(time.Now()),
+	})
+
+	// Drop the oldest changes, callers with older page tokens list all files instead
+	if n := len(self.index.Changes) - localMaxChanges; n > 0 {
+		self.index.Changes = append([]localChange(nil), self.index.Changes[n:]...)
+		self.index.DroppedChanges += n
+	}
+}
+
+func (self *LocalBackend) loadIndex() error {
+	self.index = localIndex{Files: map[string]*drive.File{}}
+
+	data, err := ioutil.ReadFile(filepath.Join(self.root, LocalIndexFilename))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to read backend index: %s", err)
+	}
+
+	if err := json.Unmarshal(data, &self.index); err != nil {
+		return fmt.Errorf("Failed to parse backend index: %s", err)
+	}
+
+	if self.index.Files == nil {
+		self.index.Files = map[string]*drive.File{}
+	}
+	return nil
+}
+
+func (self *LocalBackend) saveIndex() error {
+	data, err := json.MarshalIndent(self.index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Failed to encode backend index: %s", err)
+	}
+
+	fpath := filepath.Join(self.root, LocalIndexFilename)
+	if err := ioutil.WriteFile(fpath+".tmp", data, 0600); err != nil {
+		return fmt.Errorf("Failed to save backend index: %s", err)
+	}
+
+	if err := os.Rename(fpath+".tmp", fpath); err != nil {
+		return fmt.Errorf("Failed to save backend index: %s", err)
+	}
+	return nil
+}
+
+// Brings the index up to date with files that were added, changed
+// or removed on disk since it was last saved
+func (self *LocalBackend) reconcile() error {
+	if _, ok := self.index.Files[localRootId]; !ok {
+		now := localTime(time.Now())
+		self.index.Files[localRootId] = &drive.File{
+			Id:           localRootId,
+			Name:         filepath.Base(self.root),
+			MimeType:     DirectoryMimeType,
+			CreatedTime:  now,
+			ModifiedTime: now,
+			Owners:       []*drive.User{{Me: true, DisplayName: "me"}},
+		}
+	}
+
+	// Index the known files by path, the trash is not checked
+	known := map[string]*drive.File{}
+	for _, f := range self.index.Files {
+		trashed, err := self.isTrashed(f)
+		if err != nil {
+			return err
+		}
+		if trashed {
+			continue
+		}
+
+		fpath, err := self.path(f)
+		if err != nil {
+			return err
+		}
+		known[fpath] = f
+	}
+
+	seen := map[string]bool{self.root: true}
+
+	err := filepath.Walk(self.root, func(fpath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if fpath == self.root {
+			return nil
+		}
+
+		// Skip the index, the trash and anything that is not a regular file or directory
+		name := info.Name()
+		if filepath.Dir(fpath) == self.root && (name == localTrashDir || name == localUploadDir) {
+			return filepath.SkipDir
+		}
+		if filepath.Dir(fpath) == self.root && (name == LocalIndexFilename || name == LocalIndexFilename+".tmp") {
+			return nil
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+
+		parent := known[filepath.Dir(fpath)]
+		if parent == nil {
+			return nil
+		}
+
+		seen[fpath] = true
+
+		f, ok := known[fpath]
+		if ok && isDir(f) != info.IsDir() {
+			// Replaced by a file of another kind, which gets a new id
+			delete(self.index.Files, f.Id)
+			self.recordChange(f.Id, true)
+		} else if ok {
+			if !info.IsDir() && (f.Size != info.Size() || f.ModifiedTime != localTime(info.ModTime())) {
+				if err := self.updateFromDisk(f, fpath, info); err != nil {
+					return err
+				}
+				self.recordChange(f.Id, false)
+			}
+			return nil
+		}
+
+		f = &drive.File{
+			Id:            newLocalId(),
+			Name:          name,
+			Parents:       []string{parent.Id},
+			AppProperties: inheritedSyncProperties(parent),
+			CreatedTime:   localTime(info.ModTime()),
+			Owners:        []*drive.User{{Me: true, DisplayName: "me"}},
+		}
+
+		if info.IsDir() {
+			f.MimeType = DirectoryMimeType
+			f.ModifiedTime = localTime(info.ModTime())
+		} else {
+			f.MimeType = localMimeType(name)
+			if err := self.updateFromDisk(f, fpath, info); err != nil {
+				return err
+			}
+		}
+
+		known[fpath] = f
+		self.index.Files[f.Id] = f
+		self.recordChange(f.Id, false)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to read backend directory: %s", err)
+	}
+
+	for fpath, f := range known {
+		if !seen[fpath] {
+			delete(self.index.Files, f.Id)
+			self.recordChange(f.Id, true)
+		}
+	}
+
+	return nil
+}
+
+func (self *LocalBackend) updateFromDisk(f *drive.File, fpath string, info os.FileInfo) error {
+	file, err := os.Open(fpath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hasher := md5.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return err
+	}
+
+	f.Size = info.Size()
+	f.Md5Checksum = hex.EncodeToString(hasher.Sum(nil))
+	f.ModifiedTime = localTime(info.ModTime())
+	return nil
+}
+
+type localEnv struct {
+	backend *LocalBackend
+}
+
+// Files that can not be placed in the tree are treated as trashed
+func (self localEnv) IsTrashed(f *drive.File) bool {
+	trashed, err := self.backend.isTrashed(f)
+	return trashed || err != nil
+}
+
+func (self localEnv) IsMember(f *drive.File, collection, value string) bool {
+	switch collection {
+	case "parents":
+		return len(f.Parents) > 0 && f.Parents[0] == value
+	case "owners", "writers":
+		// Everything is owned by the current user
+		return value == "me"
+	}
+	return false
+}
+
+// Timestamps have millisecond precision, like on drive
+func localTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+func setLocalModTime(fpath, modifiedTime string) error {
+	t, err := time.Parse(time.RFC3339, modifiedTime)
+	if err != nil {
+		return fmt.Errorf("Invalid modified time '%s': %s", modifiedTime, err)
+	}
+
+	if err := os.Chtimes(fpath, t, t); err != nil {
+		return fmt.Errorf("Failed to set modified time: %s", err)
+	}
+	return nil
+}
+
+func localMimeType(name string) string {
+	if mimeType := mime.TypeByExtension(filepath.Ext(name)); mimeType != "" {
+		return mimeType
+	}
+	return "application/octet-stream"
+}
+
+// Properties are merged like on drive, empty values deletes the key
+func mergeLocalProperties(existing, updates map[string]string) map[string]string {
+	if len(updates) == 0 {
+		return existing
+	}
+
+	merged := map[string]string{}
+	for k, v := range existing {
+		merged[k] = v
+	}
+	for k, v := range updates {
+		if v == "" {
+			delete(merged, k)
+		} else {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+func hasForceSendField(f *drive.File, field string) bool {
+	for _, name := range f.ForceSendFields {
+		if name == field {
+			return true
+		}
+	}
+	return false
+}
+
+func newLocalId() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Returns a copy that the caller can modify without changing the index
+func (self *LocalBackend) resource(f *drive.File) (*drive.File, error) {
+	trashed, err := self.isTrashed(f)
+	if err != nil {
+		return nil, err
+	}
+
+	c := *f
+	c.Parents = append([]string(nil), f.Parents...)
+	c.Trashed = trashed
+	c.ExplicitlyTrashed = f.Trashed
+	return &c, nil
+}
+
+// Saves the index and returns a copy of f
+func (self *LocalBackend) saveResource(f *drive.File) (*drive.File, error) {
+	if err := self.saveIndex(); err != nil {
+		return nil, err
+	}
+	return self.resource(f)
+}
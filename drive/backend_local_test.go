@@ -0,0 +1,261 @@
+package drive
+
+import (
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newLocalTestDrive(t *testing.T, dir string) *Drive {
+	backend, err := NewLocalBackend(dir)
+	if err != nil {
+		t.Fatalf("Failed to open local backend: %s", err)
+	}
+	return NewWithBackend(backend)
+}
+
+func TestLocalBackendSync(t *testing.T) {
+	backendDir := t.TempDir()
+	gdrive := newLocalTestDrive(t, backendDir)
+
+	root, err := gdrive.backend.CreateFile(&drive.File{Name: "sync", MimeType: DirectoryMimeType}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := t.TempDir()
+	writeTestFiles(t, src, map[string]string{
+		"a.txt":     "a",
+		"dir/b.txt": "b",
+	})
+	uploadSync(t, gdrive, root.Id, src, t.TempDir())
+
+	assertLocalFile(t, filepath.Join(backendDir, "sync", "a.txt"), []byte("a"))
+	assertLocalFile(t, filepath.Join(backendDir, "sync", "dir", "b.txt"), []byte("b"))
+
+	// Changes made directly on disk are picked up when the backend is opened again
+	writeTestFiles(t, filepath.Join(backendDir, "sync"), map[string]string{
+		"dir/b.txt": "changed",
+		"c.txt":     "c",
+	})
+	gdrive = newLocalTestDrive(t, backendDir)
+
+	dst := t.TempDir()
+	downloadSync(t, gdrive, root.Id, dst, t.TempDir())
+
+	assertLocalFile(t, filepath.Join(dst, "a.txt"), []byte("a"))
+	assertLocalFile(t, filepath.Join(dst, "dir", "b.txt"), []byte("changed"))
+	assertLocalFile(t, filepath.Join(dst, "c.txt"), []byte("c"))
+}
+
+func TestLocalBackendList(t *testing.T) {
+	backendDir := t.TempDir()
+	writeTestFiles(t, backendDir, map[string]string{
+		"a.txt":     "a",
+		"dir/b.txt": "bb",
+	})
+	gdrive := newLocalTestDrive(t, backendDir)
+
+	files, err := gdrive.listAllFiles(listAllFilesArgs{
+		query:     "'root' in parents and trashed = false and 'me' in owners",
+		sortOrder: "name",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 2 || files[0].Name != "a.txt" || files[1].Name != "dir" {
+		t.Fatalf("Unexpected files in root: %v", files)
+	}
+
+	if files[0].Md5Checksum != "0cc175b9c0f1b6a831c399e269772661" {
+		t.Fatalf("Unexpected md5 of a.txt: %s", files[0].Md5Checksum)
+	}
+
+	// The index is not part of the file tree
+	if _, err := ioutil.ReadFile(filepath.Join(backendDir, LocalIndexFilename)); err != nil {
+		t.Fatalf("Expected the index to be saved: %s", err)
+	}
+
+	if err := gdrive.Share(ShareArgs{Out: ioutil.Discard, FileId: files[0].Id, Role: "reader", Type: "anyone"}); err == nil {
+		t.Fatal("Expected sharing to be unsupported")
+	}
+}
+
+func TestLocalBackendDeleteNested(t *testing.T) {
+	backendDir := t.TempDir()
+	writeTestFiles(t, backendDir, map[string]string{
+		"top/a/b/c/d.txt": "d",
+		"top/e.txt":       "e",
+		"trash/f/g/h.txt": "h",
+	})
+	backend, err := NewLocalBackend(backendDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	find := func(name string) *drive.File {
+		t.Helper()
+		var found *drive.File
+		err := backend.ListFiles(FileQuery{Query: fmt.Sprintf("name = '%s'", name)}, func(list *drive.FileList) error {
+			if len(list.Files) > 0 {
+				found = list.Files[0]
+			}
+			return nil
+		})
+		if err != nil || found == nil {
+			t.Fatalf("Failed to find %s: %v", name, err)
+		}
+		return found
+	}
+
+	if err := backend.DeleteFile(find("top").Id); err != nil {
+		t.Fatal(err)
+	}
+
+	// Trash a directory and empty the trash
+	trashId := find("trash").Id
+	if _, err := backend.UpdateFile(trashId, &drive.File{Trashed: true}, FileUpdate{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.EmptyTrash(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"top", "trash"} {
+		if _, err := os.Stat(filepath.Join(backendDir, name)); !os.IsNotExist(err) {
+			t.Fatalf("Expected %s to be deleted from disk, got %v", name, err)
+		}
+	}
+
+	if _, err := backend.GetFile(trashId); err == nil {
+		t.Fatal("Expected the trashed directory to be removed from the index")
+	}
+
+	// Nothing but the root is left in the index
+	if n := len(backend.index.Files); n != 1 {
+		t.Fatalf("Expected only the root in the index, got %d files", n)
+	}
+}
+
+func TestLocalBackendDropsOldChanges(t *testing.T) {
+	maxChanges := localMaxChanges
+	localMaxChanges = 3
+	defer func() { localMaxChanges = maxChanges }()
+
+	backendDir := t.TempDir()
+	gdrive := newLocalTestDrive(t, backendDir)
+
+	root, err := gdrive.backend.CreateFile(&drive.File{Name: "sync", MimeType: DirectoryMimeType}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := t.TempDir()
+	writeTestFiles(t, src, map[string]string{"a.txt": "a"})
+	uploadSync(t, gdrive, root.Id, src, t.TempDir())
+
+	dst := t.TempDir()
+	stateDir := t.TempDir()
+	downloadSync(t, gdrive, root.Id, dst, stateDir)
+
+	token, err := gdrive.backend.GetStartPageToken("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestFiles(t, src, map[string]string{"b.txt": "b", "c.txt": "c", "d.txt": "d", "e.txt": "e"})
+	uploadSync(t, gdrive, root.Id, src, t.TempDir())
+
+	if _, err := gdrive.backend.ListChanges(ChangeQuery{PageToken: token}); err == nil || !strings.Contains(err.Error(), "Invalid page token") {
+		t.Fatalf("Expected the token of a dropped change to be invalid, got %v", err)
+	}
+
+	// Tokens stay valid when the backend is opened again
+	latest, err := gdrive.backend.GetStartPageToken("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gdrive = newLocalTestDrive(t, backendDir)
+	if reopened, _ := gdrive.backend.GetStartPageToken(""); reopened != latest {
+		t.Fatalf("Expected start page token %s after reopening, got %s", latest, reopened)
+	}
+
+	backend := gdrive.backend.(*LocalBackend)
+	if n := len(backend.index.Changes); n > localMaxChanges {
+		t.Fatalf("Expected at most %d changes in the index, got %d", localMaxChanges, n)
+	}
+
+	// The sync falls back to listing all files
+	downloadSync(t, gdrive, root.Id, dst, stateDir)
+	for _, name := range []string{"b.txt", "c.txt", "d.txt", "e.txt"} {
+		assertLocalFile(t, filepath.Join(dst, name), []byte(strings.TrimSuffix(name, ".txt")))
+	}
+}
+
+// Calls fn on the first read, before any content is returned
+type firstReadHook struct {
+	reader io.Reader
+	fn     func()
+}
+
+func (self *firstReadHook) Read(p []byte) (int, error) {
+	if self.fn != nil {
+		self.fn()
+		self.fn = nil
+	}
+	return self.reader.Read(p)
+}
+
+func TestLocalBackendWritesContentWithoutLock(t *testing.T) {
+	backendDir := t.TempDir()
+	backend, err := NewLocalBackend(backendDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Other operations are not blocked while the content is transferred
+	blocked := false
+	waitForGetFile := func() {
+		done := make(chan struct{})
+		go func() {
+			backend.GetFile(localRootId)
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			blocked = true
+		}
+	}
+
+	f, err := backend.CreateFile(&drive.File{Name: "a.txt"}, &Media{Reader: &firstReadHook{strings.NewReader("a"), waitForGetFile}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = backend.UpdateFile(f.Id, &drive.File{}, FileUpdate{Media: &Media{Reader: &firstReadHook{strings.NewReader("b"), waitForGetFile}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if blocked {
+		t.Fatal("Expected the lock to be released while the content is written")
+	}
+
+	assertLocalFile(t, filepath.Join(backendDir, "a.txt"), []byte("b"))
+
+	// No tmp files are left behind
+	tmpFiles, _ := ioutil.ReadDir(filepath.Join(backendDir, localUploadDir))
+	if len(tmpFiles) != 0 {
+		t.Fatalf("Expected no tmp files, got %d", len(tmpFiles))
+	}
+}
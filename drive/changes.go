@@ -3,6 +3,7 @@ package drive
 import (
 	"fmt"
 	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
 	"io"
 	"text/tabwriter"
 )
@@ -18,37 +19,93 @@ type ListChangesArgs struct {
 
 func (self *Drive) ListChanges(args ListChangesArgs) error {
 	if args.Now {
-		pageToken, err := self.GetChangesStartPageToken()
+		pageToken, err := self.GetChangesStartPageToken("")
 		if err != nil {
 			return err
 		}
 
-		fmt.Fprintf(args.Out, "Page token: %s\n", pageToken)
-		return nil
+		return self.render(args.Out, Result{
+			Value:  PageTokenRecord{pageToken},
+			Header: []string{"PageToken"},
+			Rows:   [][]string{{pageToken}},
+			Table: func(w io.Writer) {
+				fmt.Fprintf(w, "Page token: %s\n", pageToken)
+			},
+		})
 	}
 
-	changeList, err := self.service.Changes.List(args.PageToken).PageSize(args.MaxChanges).RestrictToMyDrive(true).Fields("newStartPageToken", "nextPageToken", "changes(fileId,removed,time,file(id,name,md5Checksum,mimeType,createdTime,modifiedTime))").Do()
+	changeList, err := self.backend.ListChanges(ChangeQuery{
+		PageToken:         args.PageToken,
+		PageSize:          args.MaxChanges,
+		Fields:            []googleapi.Field{"newStartPageToken", "nextPageToken", "changes(fileId,removed,time,file(id,name,md5Checksum,mimeType,createdTime,modifiedTime))"},
+		RestrictToMyDrive: true,
+	})
 	if err != nil {
 		return fmt.Errorf("Failed listing changes: %s", err)
 	}
 
-	PrintChanges(PrintChangesArgs{
-		Out:        args.Out,
-		ChangeList: changeList,
-		NameWidth:  int(args.NameWidth),
+	pageToken, hasMore := nextChangesPageToken(changeList)
+	record := ChangeListRecord{
+		Changes:   make([]ChangeRecord, 0, len(changeList.Changes)),
+		PageToken: pageToken,
+		More:      hasMore,
+	}
+
+	var rows [][]string
+	for _, c := range changeList.Changes {
+		cr := newChangeRecord(c)
+		record.Changes = append(record.Changes, cr)
+		rows = append(rows, []string{cr.FileId, cr.Name, cr.Action, cr.Time})
+	}
+
+	return self.render(args.Out, Result{
+		Value:      record,
+		Header:     []string{"Id", "Name", "Action", "Time"},
+		Rows:       rows,
 		SkipHeader: args.SkipHeader,
+		Table: func(w io.Writer) {
+			PrintChanges(PrintChangesArgs{
+				Out:        w,
+				ChangeList: changeList,
+				NameWidth:  int(args.NameWidth),
+				SkipHeader: args.SkipHeader,
+			})
+		},
 	})
+}
 
-	return nil
+type PageTokenRecord struct {
+	PageToken string `json:"pageToken"`
+}
+
+type ChangeListRecord struct {
+	Changes   []ChangeRecord `json:"changes"`
+	PageToken string         `json:"pageToken"`
+	More      bool           `json:"more"`
+}
+
+type ChangeRecord struct {
+	FileId string `json:"fileId"`
+	Name   string `json:"name,omitempty"`
+	Action string `json:"action"`
+	Time   string `json:"time"`
+}
+
+func newChangeRecord(c *drive.Change) ChangeRecord {
+	if c.Removed {
+		return ChangeRecord{FileId: c.FileId, Action: "remove", Time: c.Time}
+	}
+	return ChangeRecord{FileId: c.FileId, Name: c.File.Name, Action: "update", Time: c.Time}
 }
 
-func (self *Drive) GetChangesStartPageToken() (string, error) {
-	res, err := self.service.Changes.GetStartPageToken().Do()
+// An empty drive id gives the token of my drive, or of the drive given to SetDriveId
+func (self *Drive) GetChangesStartPageToken(driveId string) (string, error) {
+	pageToken, err := self.backend.GetStartPageToken(driveId)
 	if err != nil {
 		return "", fmt.Errorf("Failed getting start page token: %s", err)
 	}
 
-	return res.StartPageToken, nil
+	return pageToken, nil
 }
 
 type PrintChangesArgs struct {
@@ -0,0 +1,141 @@
+package drive
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ConflictResolution decides what to do with a file that
+// has changed both locally and remotely since the last sync
+type ConflictResolution interface {
+	Resolve(local *LocalFile, remote *RemoteFile) (ConflictAction, string)
+}
+
+type ConflictAction int
+
+const (
+	SkipConflict ConflictAction = iota
+	UseLocal
+	UseRemote
+	UseBoth
+)
+
+var (
+	KeepLocal   ConflictResolution = keepLocal{}
+	KeepRemote  ConflictResolution = keepRemote{}
+	KeepLargest ConflictResolution = keepLargest{}
+	KeepNewest  ConflictResolution = keepNewest{}
+	KeepBoth    ConflictResolution = keepBoth{}
+)
+
+type keepLocal struct{}
+
+func (self keepLocal) Resolve(local *LocalFile, remote *RemoteFile) (ConflictAction, string) {
+	return UseLocal, "keeping local file"
+}
+
+type keepRemote struct{}
+
+func (self keepRemote) Resolve(local *LocalFile, remote *RemoteFile) (ConflictAction, string) {
+	return UseRemote, "keeping remote file"
+}
+
+type keepLargest struct{}
+
+func (self keepLargest) Resolve(local *LocalFile, remote *RemoteFile) (ConflictAction, string) {
+	if local.Size() > remote.Size() {
+		return UseLocal, "local file is largest, keeping local"
+	}
+
+	if remote.Size() > local.Size() {
+		return UseRemote, "remote file is largest, keeping remote"
+	}
+
+	return SkipConflict, "file sizes are equal"
+}
+
+type keepNewest struct{}
+
+func (self keepNewest) Resolve(local *LocalFile, remote *RemoteFile) (ConflictAction, string) {
+	// Drive keeps modification times with millisecond precision
+	localTime := local.Modified().Truncate(time.Millisecond)
+
+	if localTime.After(remote.Modified()) {
+		return UseLocal, "local file is newest, keeping local"
+	}
+
+	if remote.Modified().After(localTime) {
+		return UseRemote, "remote file is newest, keeping remote"
+	}
+
+	return SkipConflict, "modification times are equal"
+}
+
+// The copy that would have been overwritten is renamed
+// and both copies are synced
+type keepBoth struct{}
+
+func (self keepBoth) Resolve(local *LocalFile, remote *RemoteFile) (ConflictAction, string) {
+	return UseBoth, "keeping both files"
+}
+
+type interactiveResolution struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// Returns a resolution that asks the user what to do with every conflict,
+// conflicts are skipped if the input ends
+func NewInteractiveResolution(in io.Reader, out io.Writer) ConflictResolution {
+	return &interactiveResolution{in: bufio.NewReader(in), out: out}
+}
+
+func (self *interactiveResolution) Resolve(local *LocalFile, remote *RemoteFile) (ConflictAction, string) {
+	fmt.Fprintf(self.out, "\nConflict: %s has changed both locally and remotely\n", local.relPath)
+
+	w := new(tabwriter.Writer)
+	w.Init(self.out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "\tSize\tModified")
+	fmt.Fprintf(w, "Local\t%s\t%s\n", formatSize(local.Size(), false), local.Modified().Local().Format("Jan _2 2006 15:04:05.000"))
+	fmt.Fprintf(w, "Remote\t%s\t%s\n", formatSize(remote.Size(), false), remote.Modified().Local().Format("Jan _2 2006 15:04:05.000"))
+	w.Flush()
+
+	for {
+		fmt.Fprint(self.out, "Keep [l]ocal, [r]emote, [b]oth or [s]kip? ")
+
+		line, err := self.in.ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Fprintln(self.out)
+			return SkipConflict, "no answer was given"
+		}
+
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "l", "local":
+			return UseLocal, "local file was chosen"
+		case "r", "remote":
+			return UseRemote, "remote file was chosen"
+		case "b", "both":
+			return UseBoth, "both files were chosen"
+		case "s", "skip":
+			return SkipConflict, "skipped by user"
+		}
+	}
+}
+
+// Returns the name the losing copy of a conflict is renamed to,
+// i.e. report (conflict myhost 2016-01-02 15.04.05).txt
+func conflictName(name string, t time.Time) string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	ext := filepath.Ext(name)
+	return fmt.Sprintf("%s (conflict %s %s)%s", strings.TrimSuffix(name, ext), host, t.Format("2006-01-02 15.04.05"), ext)
+}
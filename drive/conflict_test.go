@@ -0,0 +1,75 @@
+package drive
+
+import (
+	"github.com/prasmussen/gdrive/drive/drivetest"
+	"google.golang.org/api/drive/v3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInteractiveResolution(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"a.txt": "a"})
+
+	info, err := os.Stat(filepath.Join(dir, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local := &LocalFile{absPath: filepath.Join(dir, "a.txt"), relPath: "a.txt", info: info}
+	remote := &RemoteFile{relPath: "a.txt", file: &drive.File{Size: 1, ModifiedTime: "2016-01-02T15:04:05Z"}}
+
+	// Unknown answers are asked again
+	resolution := NewInteractiveResolution(strings.NewReader("x\nboth\n"), ioutil.Discard)
+	if action, _ := resolution.Resolve(local, remote); action != UseBoth {
+		t.Fatalf("Expected both files to be kept, got %d", action)
+	}
+
+	resolution = NewInteractiveResolution(strings.NewReader(""), ioutil.Discard)
+	if action, _ := resolution.Resolve(local, remote); action != SkipConflict {
+		t.Fatalf("Expected the conflict to be skipped without input, got %d", action)
+	}
+}
+
+func TestDownloadSyncKeepBoth(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	rootId := server.AddFolder(drivetest.RootId, "sync")
+	srcDir := t.TempDir()
+	writeTestFiles(t, srcDir, map[string]string{"a.txt": "a"})
+	uploadSync(t, gdrive, rootId, srcDir, "")
+
+	dir := t.TempDir()
+	stateDir := t.TempDir()
+	downloadSync(t, gdrive, rootId, dir, stateDir)
+
+	// Change the file on both sides
+	server.SetContent(server.Find(rootId, "a.txt").Id, []byte("remote"))
+	writeTestFiles(t, dir, map[string]string{"a.txt": "local"})
+
+	err := gdrive.DownloadSync(DownloadSyncArgs{
+		Out:        ioutil.Discard,
+		Path:       dir,
+		RootId:     rootId,
+		Resolution: KeepBoth,
+		Comparer:   testComparer{},
+		StateDir:   stateDir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertLocalFile(t, filepath.Join(dir, "a.txt"), []byte("remote"))
+
+	matches, err := filepath.Glob(filepath.Join(dir, "a (conflict *).txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(matches) != 1 {
+		t.Fatalf("Expected one conflicting copy, got %v", matches)
+	}
+	assertLocalFile(t, matches[0], []byte("local"))
+}
@@ -0,0 +1,216 @@
+package drive
+
+import (
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"io"
+)
+
+// Fields needed to copy or move a file and keep sync roots consistent
+var copyFileFields = []googleapi.Field{"id", "name", "mimeType", "description", "parents", "properties", "appProperties"}
+
+type CopyArgs struct {
+	Out       io.Writer
+	Id        string
+	Parent    string
+	Name      string
+	Recursive bool
+}
+
+func (self *Drive) Copy(args CopyArgs) error {
+	src, err := self.backend.GetFile(args.Id, copyFileFields...)
+	if err != nil {
+		return fmt.Errorf("Failed to get file: %s", err)
+	}
+
+	if isDir(src) && !args.Recursive {
+		return fmt.Errorf("'%s' is a directory, use the 'recursive' flag to copy directories", src.Name)
+	}
+
+	parentId := args.Parent
+	if parentId == "" {
+		parentId = self.rootId()
+		if len(src.Parents) > 0 {
+			parentId = src.Parents[0]
+		}
+	}
+
+	parent, err := self.getDestinationDir(parentId)
+	if err != nil {
+		return err
+	}
+
+	name := args.Name
+	if name == "" {
+		name = src.Name
+	}
+
+	props, err := self.copySyncProperties(src, parent, name)
+	if err != nil {
+		return err
+	}
+
+	if isDir(src) {
+		inside, err := self.isInside(parent, src.Id)
+		if err != nil {
+			return err
+		}
+		if inside {
+			return fmt.Errorf("Can not copy '%s' into itself", src.Name)
+		}
+	}
+
+	f, err := self.copyTree(args.Out, src, parent.Id, name, props)
+	if err != nil {
+		return err
+	}
+
+	self.event(args.Out, Event{Action: "copy", Path: f.Name, Id: f.Id, Message: fmt.Sprintf("Copied '%s' to %s", src.Name, f.Id)})
+	return nil
+}
+
+// Returns the sync appProperties the copy of src needs in the given parent,
+// nil if the copy is not part of a sync
+func (self *Drive) copySyncProperties(src, parent *drive.File, name string) (map[string]string, error) {
+	dstProps := inheritedSyncProperties(parent)
+
+	// A copy of a sync root becomes a new sync root, its content is
+	// moved over to the new root when the tree is recreated
+	if _, ok := src.AppProperties["syncRoot"]; ok {
+		if dstProps != nil {
+			return nil, fmt.Errorf("Can not copy sync root '%s' into another sync root", src.Name)
+		}
+		return map[string]string{"sync": "true", "syncRoot": "true"}, nil
+	}
+
+	// The sync properties are copied along with the file and can not be removed
+	if _, ok := src.AppProperties["sync"]; ok && dstProps == nil {
+		return nil, fmt.Errorf("Can not copy '%s' out of its sync root", src.Name)
+	}
+
+	if dstProps != nil {
+		if err := self.checkNameAvailable(parent, name); err != nil {
+			return nil, err
+		}
+	}
+
+	return dstProps, nil
+}
+
+// Recreates src below the given parent, directories are created and
+// their content is copied server-side file by file
+func (self *Drive) copyTree(out io.Writer, src *drive.File, parentId, name string, props map[string]string) (*drive.File, error) {
+	dstFile := &drive.File{
+		Name:          name,
+		Parents:       []string{parentId},
+		AppProperties: props,
+	}
+
+	if !isDir(src) {
+		f, err := self.backend.CopyFile(src.Id, dstFile, "id", "name")
+		if err != nil {
+			return nil, fmt.Errorf("Failed to copy '%s': %s", src.Name, err)
+		}
+		return f, nil
+	}
+
+	// List the content before the directory is created to make sure the copy is never included
+	children, err := self.listAllFiles(listAllFilesArgs{
+		query:  fmt.Sprintf("'%s' in parents and trashed = false", src.Id),
+		fields: []googleapi.Field{"nextPageToken", "files(id,name,mimeType,description,parents,properties,appProperties)"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list files: %s", err)
+	}
+
+	dstFile.MimeType = DirectoryMimeType
+	dstFile.Description = src.Description
+	dstFile.Properties = src.Properties
+	dstFile.AppProperties = mergeSyncProperties(src.AppProperties, props)
+
+	dir, err := self.backend.CreateFile(dstFile, nil, "id", "name", "appProperties")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create directory: %s", err)
+	}
+
+	childProps := inheritedSyncProperties(dir)
+	for _, child := range children {
+		f, err := self.copyTree(out, child, dir.Id, child.Name, childProps)
+		if err != nil {
+			return nil, err
+		}
+
+		self.event(out, Event{Action: "copy", Path: f.Name, Id: f.Id, Message: fmt.Sprintf("Copied '%s' to %s", child.Name, f.Id)})
+	}
+
+	return dir, nil
+}
+
+// Returns the given appProperties with the sync properties replaced
+func mergeSyncProperties(appProperties, props map[string]string) map[string]string {
+	merged := map[string]string{}
+	for key, value := range appProperties {
+		merged[key] = value
+	}
+
+	if props != nil {
+		delete(merged, "syncRoot")
+		delete(merged, "syncRootId")
+	}
+
+	for key, value := range props {
+		merged[key] = value
+	}
+
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+func (self *Drive) getDestinationDir(id string) (*drive.File, error) {
+	f, err := self.backend.GetFile(id, "id", "name", "mimeType", "appProperties")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get parent directory: %s", err)
+	}
+
+	if !isDir(f) {
+		return nil, fmt.Errorf("'%s' is not a directory", f.Name)
+	}
+
+	return f, nil
+}
+
+// Returns true if dir is the directory with the given id or one of its descendants
+func (self *Drive) isInside(dir *drive.File, id string) (bool, error) {
+	pathfinder := self.newPathfinder()
+
+	for current := dir.Id; current != id; {
+		f, err := pathfinder.getParent(current)
+		if err != nil {
+			return false, err
+		}
+
+		if len(f.Parents) == 0 {
+			return false, nil
+		}
+		current = f.Parents[0]
+	}
+
+	return true, nil
+}
+
+// Sync directories can not contain several files with the same name
+func (self *Drive) checkNameAvailable(parent *drive.File, name string) error {
+	files, err := self.newPathfinder().getChildren(parent.Id, name)
+	if err != nil {
+		return err
+	}
+
+	if len(files) > 0 {
+		return fmt.Errorf("'%s' already exists in sync directory '%s'", name, parent.Name)
+	}
+
+	return nil
+}
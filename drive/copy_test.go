@@ -0,0 +1,89 @@
+package drive
+
+import (
+	"github.com/prasmussen/gdrive/drive/drivetest"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyDirectory(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	dirId := server.AddFolder(drivetest.RootId, "dir")
+	server.AddFile(server.AddFolder(dirId, "sub"), "a.txt", []byte("a"))
+	dstId := server.AddFolder(drivetest.RootId, "dst")
+
+	if err := gdrive.Copy(CopyArgs{Out: ioutil.Discard, Id: dirId, Parent: dstId}); err == nil {
+		t.Fatal("Expected directories to require the recursive flag")
+	}
+
+	err := gdrive.Copy(CopyArgs{Out: ioutil.Discard, Id: dirId, Parent: dstId, Name: "copy", Recursive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertRemoteFile(t, server, dstId, "copy/sub/a.txt", []byte("a"))
+	assertRemoteFile(t, server, dirId, "sub/a.txt", []byte("a"))
+
+	if err := gdrive.Copy(CopyArgs{Out: ioutil.Discard, Id: dirId, Parent: server.Find(dirId, "sub").Id, Recursive: true}); err == nil {
+		t.Fatal("Expected copying a directory into itself to fail")
+	}
+}
+
+func TestCopySyncRoot(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	rootId := server.AddFolder(drivetest.RootId, "sync")
+
+	src := t.TempDir()
+	writeTestFiles(t, src, map[string]string{"dir/a.txt": "a"})
+	uploadSync(t, gdrive, rootId, src, t.TempDir())
+
+	err := gdrive.Copy(CopyArgs{Out: ioutil.Discard, Id: rootId, Parent: drivetest.RootId, Name: "copy", Recursive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The copy is a sync root of its own
+	dst := t.TempDir()
+	downloadSync(t, gdrive, server.Find(drivetest.RootId, "copy").Id, dst, t.TempDir())
+	assertLocalFile(t, filepath.Join(dst, "dir", "a.txt"), []byte("a"))
+
+	if err := gdrive.Copy(CopyArgs{Out: ioutil.Discard, Id: server.Find(rootId, "dir/a.txt").Id, Parent: drivetest.RootId}); err == nil {
+		t.Fatal("Expected copying out of a sync root to fail")
+	}
+}
+
+func TestMoveIntoSyncRoot(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	rootId := server.AddFolder(drivetest.RootId, "sync")
+	dirId := server.AddFolder(drivetest.RootId, "dir")
+	server.AddFile(dirId, "a.txt", []byte("a"))
+
+	src := t.TempDir()
+	writeTestFiles(t, src, map[string]string{"b.txt": "b"})
+	uploadSync(t, gdrive, rootId, src, t.TempDir())
+
+	err := gdrive.Move(MoveArgs{Out: ioutil.Discard, Id: dirId, Parent: rootId, Recursive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := t.TempDir()
+	downloadSync(t, gdrive, rootId, dst, t.TempDir())
+	assertLocalFile(t, filepath.Join(dst, "b.txt"), []byte("b"))
+	assertLocalFile(t, filepath.Join(dst, "dir", "a.txt"), []byte("a"))
+
+	if err := gdrive.Move(MoveArgs{Out: ioutil.Discard, Id: dirId, Parent: drivetest.RootId, Recursive: true}); err == nil {
+		t.Fatal("Expected moving out of a sync root to fail")
+	}
+
+	otherId := server.AddFolder(drivetest.RootId, "other")
+	server.AddFile(otherId, "b.txt", []byte("other"))
+	if err := gdrive.Move(MoveArgs{Out: ioutil.Discard, Id: otherId, Parent: rootId}); err == nil {
+		t.Fatal("Expected directories to require the recursive flag")
+	}
+
+	if err := gdrive.Move(MoveArgs{Out: ioutil.Discard, Id: server.Find(otherId, "b.txt").Id, Parent: rootId}); err == nil {
+		t.Fatal("Expected name collisions in sync directories to fail")
+	}
+}
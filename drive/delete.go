@@ -2,6 +2,8 @@ package drive
 
 import (
 	"fmt"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
 	"io"
 )
 
@@ -9,10 +11,11 @@ type DeleteArgs struct {
 	Out       io.Writer
 	Id        string
 	Recursive bool
+	Permanent bool
 }
 
 func (self *Drive) Delete(args DeleteArgs) error {
-	f, err := self.service.Files.Get(args.Id).Fields("name", "mimeType").Do()
+	f, err := self.backend.GetFile(args.Id, "id", "name", "mimeType")
 	if err != nil {
 		return fmt.Errorf("Failed to get file: %s", err)
 	}
@@ -21,17 +24,37 @@ func (self *Drive) Delete(args DeleteArgs) error {
 		return fmt.Errorf("'%s' is a directory, use the 'recursive' flag to delete directories", f.Name)
 	}
 
-	err = self.service.Files.Delete(args.Id).Do()
+	if !args.Permanent {
+		if _, err := self.trashFile(args.Id); err != nil {
+			return err
+		}
+
+		self.event(args.Out, Event{Action: "trash", Path: f.Name, Id: f.Id, Message: fmt.Sprintf("Moved '%s' to trash", f.Name)})
+		return nil
+	}
+
+	err = self.backend.DeleteFile(args.Id)
 	if err != nil {
 		return fmt.Errorf("Failed to delete file: %s", err)
 	}
 
-	fmt.Fprintf(args.Out, "Deleted '%s'\n", f.Name)
+	self.event(args.Out, Event{Action: "delete", Path: f.Name, Id: f.Id, Message: fmt.Sprintf("Deleted '%s'", f.Name)})
 	return nil
 }
 
+// Moves the file to trash unless it should be deleted permanently. Errors
+// are returned as is, so that callers can retry backend errors
+func (self *Drive) removeFile(fileId string, permanent bool) error {
+	if permanent {
+		return self.backend.DeleteFile(fileId)
+	}
+
+	_, err := self.backend.UpdateFile(fileId, &drive.File{Trashed: true}, FileUpdate{Fields: []googleapi.Field{"id"}})
+	return err
+}
+
 func (self *Drive) deleteFile(fileId string) error {
-	err := self.service.Files.Delete(fileId).Do()
+	err := self.backend.DeleteFile(fileId)
 	if err != nil {
 		return fmt.Errorf("Failed to delete file: %s", err)
 	}
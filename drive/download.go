@@ -1,6 +1,7 @@
 package drive
 
 import (
+	"crypto/md5"
 	"fmt"
 	"io"
 	"os"
@@ -20,16 +21,33 @@ type DownloadArgs struct {
 	Skip      bool
 	Recursive bool
 	Delete    bool
+	Permanent bool
 	Stdout    bool
 	Timeout   time.Duration
+	Parallel  int
+	Resume    bool
+	Verify    VerifyPolicy
+	verified  *verifyStats
+
+	// Export google documents instead of skipping them, ExportMimes
+	// overrides DefaultExportMime by document type, i.e. document or
+	// application/vnd.google-apps.document
+	Export      bool
+	ExportMimes map[string]string
 }
 
 func (self *Drive) Download(args DownloadArgs) error {
+	args.verified = &verifyStats{}
+
 	if args.Recursive {
-		return self.downloadRecursive(args)
+		err := self.downloadRecursive(args)
+		if !args.Stdout {
+			self.printVerifyStats(args.Out, args.verified)
+		}
+		return err
 	}
 
-	f, err := self.service.Files.Get(args.Id).Fields("id", "name", "size", "mimeType", "md5Checksum").Do()
+	f, err := self.backend.GetFile(args.Id, "id", "name", "size", "mimeType", "md5Checksum")
 	if err != nil {
 		return fmt.Errorf("Failed to get file: %s", err)
 	}
@@ -38,40 +56,53 @@ func (self *Drive) Download(args DownloadArgs) error {
 		return fmt.Errorf("'%s' is a directory, use --recursive to download directories", f.Name)
 	}
 
-	if !isBinary(f) {
-		return fmt.Errorf("'%s' is a google document and must be exported, see the export command", f.Name)
+	if !isBinary(f) && !args.Export {
+		return fmt.Errorf("'%s' is a google document and must be exported, use --export or see the export command", f.Name)
 	}
 
-	bytes, rate, err := self.downloadBinary(f, args)
+	bytes, rate, err := self.downloadFile(f, args)
+	if err == errFileSkipped {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
 	if !args.Stdout {
-		fmt.Fprintf(args.Out, "Downloaded %s at %s/s, total %s\n", f.Id, formatSize(rate, false), formatSize(bytes, false))
+		self.event(args.Out, Event{
+			Action:  "downloaded",
+			Id:      f.Id,
+			Size:    bytes,
+			Message: fmt.Sprintf("Downloaded %s at %s/s, total %s", f.Id, formatSize(rate, false), formatSize(bytes, false)),
+		})
+		self.printVerifyStats(args.Out, args.verified)
 	}
 
 	if args.Delete {
-		err = self.deleteFile(args.Id)
+		err = self.removeFile(args.Id, args.Permanent)
 		if err != nil {
-			return fmt.Errorf("Failed to delete file: %s", err)
+			return fmt.Errorf("Failed to remove file: %s", err)
 		}
 
 		if !args.Stdout {
-			fmt.Fprintf(args.Out, "Removed %s\n", args.Id)
+			self.event(args.Out, Event{Action: "delete", Id: args.Id, Message: fmt.Sprintf("Removed %s", args.Id)})
 		}
 	}
 	return err
 }
 
 type DownloadQueryArgs struct {
-	Out       io.Writer
-	Progress  io.Writer
-	Query     string
-	Path      string
-	Force     bool
-	Skip      bool
-	Recursive bool
+	Out         io.Writer
+	Progress    io.Writer
+	Query       string
+	Path        string
+	Force       bool
+	Skip        bool
+	Recursive   bool
+	Parallel    int
+	Verify      VerifyPolicy
+	Export      bool
+	ExportMimes map[string]string
 }
 
 func (self *Drive) DownloadQuery(args DownloadQueryArgs) error {
@@ -85,49 +116,76 @@ func (self *Drive) DownloadQuery(args DownloadQueryArgs) error {
 	}
 
 	downloadArgs := DownloadArgs{
-		Out:      args.Out,
-		Progress: args.Progress,
-		Path:     args.Path,
-		Force:    args.Force,
-		Skip:     args.Skip,
+		Out:         args.Out,
+		Progress:    args.Progress,
+		Path:        args.Path,
+		Force:       args.Force,
+		Skip:        args.Skip,
+		Parallel:    args.Parallel,
+		Verify:      args.Verify,
+		verified:    &verifyStats{},
+		Export:      args.Export,
+		ExportMimes: args.ExportMimes,
 	}
 
 	for _, f := range files {
 		if isDir(f) && args.Recursive {
 			err = self.downloadDirectory(f, downloadArgs)
-		} else if isBinary(f) {
-			_, _, err = self.downloadBinary(f, downloadArgs)
+		} else if isBinary(f) || args.Export {
+			_, _, err = self.downloadFile(f, downloadArgs)
 		}
 
-		if err != nil {
+		if err != nil && err != errFileSkipped {
 			return err
 		}
 	}
 
+	self.printVerifyStats(args.Out, downloadArgs.verified)
 	return nil
 }
 
 func (self *Drive) downloadRecursive(args DownloadArgs) error {
-	f, err := self.service.Files.Get(args.Id).Fields("id", "name", "size", "mimeType", "md5Checksum").Do()
+	f, err := self.backend.GetFile(args.Id, "id", "name", "size", "mimeType", "md5Checksum")
 	if err != nil {
 		return fmt.Errorf("Failed to get file: %s", err)
 	}
 
 	if isDir(f) {
 		return self.downloadDirectory(f, args)
-	} else if isBinary(f) {
-		_, _, err = self.downloadBinary(f, args)
+	} else if isBinary(f) || args.Export {
+		_, _, err = self.downloadFile(f, args)
+		if err == errFileSkipped {
+			return nil
+		}
 		return err
 	}
 
 	return nil
 }
 
+// Downloads binary files and exports google documents, errFileSkipped
+// is returned if the file already exists and should be skipped
+func (self *Drive) downloadFile(f *drive.File, args DownloadArgs) (int64, int64, error) {
+	if isBinary(f) {
+		return self.downloadBinary(f, args)
+	}
+	return self.downloadExport(f, args)
+}
+
 func (self *Drive) downloadBinary(f *drive.File, args DownloadArgs) (int64, int64, error) {
+	// Path to file
+	fpath := filepath.Join(args.Path, f.Name)
+
+	// Continue from where a previous download was interrupted
+	var offset int64
+	if args.Resume && !args.Stdout {
+		offset = incompleteOffset(fpath+".incomplete", f.Size)
+	}
+
 	// Get timeout reader wrapper and context
 	timeoutReaderWrapper, ctx := getTimeoutReaderWrapperContext(args.Timeout)
 
-	res, err := self.service.Files.Get(f.Id).Context(ctx).Download()
+	body, offset, err := self.backend.Download(ctx, f.Id, offset)
 	if err != nil {
 		if isTimeoutError(err) {
 			return 0, 0, fmt.Errorf("Failed to download file: timeout, no data was transferred for %v", args.Timeout)
@@ -136,27 +194,57 @@ func (self *Drive) downloadBinary(f *drive.File, args DownloadArgs) (int64, int6
 	}
 
 	// Close body on function exit
-	defer res.Body.Close()
-
-	// Path to file
-	fpath := filepath.Join(args.Path, f.Name)
+	defer body.Close()
 
 	if !args.Stdout {
-		fmt.Fprintf(args.Out, "Downloading %s -> %s\n", f.Name, fpath)
+		if offset > 0 {
+			self.event(args.Out, Event{
+				Action:  "resume",
+				Path:    f.Name,
+				Target:  fpath,
+				Id:      f.Id,
+				Size:    offset,
+				Message: fmt.Sprintf("Resuming download of %s at %s -> %s", f.Name, formatSize(offset, false), fpath),
+			})
+		} else {
+			self.event(args.Out, Event{
+				Action:  "download",
+				Path:    f.Name,
+				Target:  fpath,
+				Id:      f.Id,
+				Message: fmt.Sprintf("Downloading %s -> %s", f.Name, fpath),
+			})
+		}
 	}
 
-	return self.saveFile(saveFileArgs{
+	saveArgs := saveFileArgs{
 		out:           args.Out,
-		body:          timeoutReaderWrapper(res.Body),
-		contentLength: res.ContentLength,
+		body:          timeoutReaderWrapper(body),
+		contentLength: f.Size - offset,
 		fpath:         fpath,
 		force:         args.Force,
 		skip:          args.Skip,
 		stdout:        args.Stdout,
 		progress:      args.Progress,
-	})
+		resume:        args.Resume,
+		offset:        offset,
+		md5:           f.Md5Checksum,
+		verify:        args.Verify,
+		verified:      args.verified,
+	}
+
+	bytes, rate, err := self.saveFile(saveArgs)
+	if err == errMd5Mismatch {
+		// The incomplete file was probably from an older version of the file
+		self.message(args.Out, "Resumed download of %s was corrupt, starting over", f.Name)
+		return self.downloadBinary(f, args)
+	}
+
+	return bytes, rate, err
 }
 
+var errFileSkipped = fmt.Errorf("File already exists and was skipped")
+
 type saveFileArgs struct {
 	out           io.Writer
 	body          io.Reader
@@ -166,6 +254,11 @@ type saveFileArgs struct {
 	skip          bool
 	stdout        bool
 	progress      io.Writer
+	resume        bool
+	offset        int64
+	md5           string
+	verify        VerifyPolicy
+	verified      *verifyStats
 }
 
 func (self *Drive) saveFile(args saveFileArgs) (int64, int64, error) {
@@ -174,8 +267,17 @@ func (self *Drive) saveFile(args saveFileArgs) (int64, int64, error) {
 
 	if args.stdout {
 		// Write file content to stdout
-		_, err := io.Copy(args.out, srcReader)
-		return 0, 0, err
+		hasher := md5.New()
+		_, err := io.Copy(io.MultiWriter(args.out, hasher), srcReader)
+		if err != nil || args.verify == VerifyOff || args.md5 == "" {
+			return 0, 0, err
+		}
+
+		// Nothing can be removed, but we can still tell that it went wrong
+		if actualMd5 := formatMd5(hasher); actualMd5 != args.md5 {
+			return 0, 0, fmt.Errorf("Checksum mismatch, expected md5 %s got %s", args.md5, actualMd5)
+		}
+		return 0, 0, nil
 	}
 
 	// Check if file exists to force
@@ -185,8 +287,12 @@ func (self *Drive) saveFile(args saveFileArgs) (int64, int64, error) {
 
 	//Check if file exists to skip
 	if args.skip && fileExists(args.fpath) {
-		fmt.Printf("File '%s' already exists, skipping\n", args.fpath)
-		return 0, 0, nil
+		self.event(args.out, Event{
+			Action:  "skip",
+			Path:    args.fpath,
+			Message: fmt.Sprintf("File '%s' already exists, skipping", args.fpath),
+		})
+		return 0, 0, errFileSkipped
 	}
 
 	// Ensure any parent directories exists
@@ -197,18 +303,21 @@ func (self *Drive) saveFile(args saveFileArgs) (int64, int64, error) {
 	// Download to tmp file
 	tmpPath := args.fpath + ".incomplete"
 
-	// Create new file
-	outFile, err := os.Create(tmpPath)
+	// Create new file or continue on the incomplete one
+	outFile, hasher, err := openIncompleteFile(tmpPath, args.offset)
 	if err != nil {
-		return 0, 0, fmt.Errorf("Unable to create new file: %s", err)
+		return 0, 0, err
 	}
 
 	started := time.Now()
 
 	// Save file to disk
-	bytes, err := io.Copy(outFile, srcReader)
+	bytes, err := io.Copy(io.MultiWriter(outFile, hasher), srcReader)
 	if err != nil {
 		outFile.Close()
+		if args.resume {
+			return 0, 0, fmt.Errorf("Failed saving file: %s, use --resume to continue the download", err)
+		}
 		os.Remove(tmpPath)
 		return 0, 0, fmt.Errorf("Failed saving file: %s", err)
 	}
@@ -219,36 +328,80 @@ func (self *Drive) saveFile(args saveFileArgs) (int64, int64, error) {
 	// Close File
 	outFile.Close()
 
-	// Rename tmp file to proper filename
-	return bytes, rate, os.Rename(tmpPath, args.fpath)
+	// A resumed download that fails verification is started over
+	if args.offset > 0 && args.md5 != "" && formatMd5(hasher) != args.md5 {
+		os.Remove(tmpPath)
+		return 0, 0, errMd5Mismatch
+	}
+
+	// Verify and rename tmp file to proper filename
+	return bytes, rate, finishDownload(tmpPath, args.fpath, hasher, args.md5, args.verify, args.verified)
 }
 
 func (self *Drive) downloadDirectory(parent *drive.File, args DownloadArgs) error {
+	downloads, err := self.prepareDirectoryDownloads(parent, filepath.Join(args.Path, parent.Name), args)
+	if err != nil {
+		return err
+	}
+
+	out, progress, done := prepareParallelOutput(args.Parallel, args.Out, args.Progress, 0)
+	defer done()
+
+	return runParallel(args.Parallel, len(downloads), func(i int) error {
+		// Copy args and update changed fields
+		newArgs := args
+		newArgs.Out = out
+		newArgs.Progress = progress
+		newArgs.Path = downloads[i].path
+		newArgs.Id = downloads[i].file.Id
+		newArgs.Stdout = false
+
+		_, _, err := self.downloadFile(downloads[i].file, newArgs)
+		if err == errFileSkipped {
+			return nil
+		}
+		return err
+	})
+}
+
+type directoryDownload struct {
+	file *drive.File
+	path string
+}
+
+// Walks the directory tree and returns all binary files and documents
+// to export in it together with the local directory they should be saved to
+func (self *Drive) prepareDirectoryDownloads(parent *drive.File, path string, args DownloadArgs) ([]directoryDownload, error) {
 	listArgs := listAllFilesArgs{
 		query:  fmt.Sprintf("'%s' in parents", parent.Id),
-		fields: []googleapi.Field{"nextPageToken", "files(id,name)"},
+		fields: []googleapi.Field{"nextPageToken", "files(id,name,size,mimeType,md5Checksum)"},
 	}
 	files, err := self.listAllFiles(listArgs)
 	if err != nil {
-		return fmt.Errorf("Failed listing files: %s", err)
+		return nil, fmt.Errorf("Failed listing files: %s", err)
 	}
 
-	newPath := filepath.Join(args.Path, parent.Name)
+	var downloads []directoryDownload
 
 	for _, f := range files {
-		// Copy args and update changed fields
-		newArgs := args
-		newArgs.Path = newPath
-		newArgs.Id = f.Id
-		newArgs.Stdout = false
-
-		err = self.downloadRecursive(newArgs)
-		if err != nil {
-			return err
+		if isDir(f) {
+			children, err := self.prepareDirectoryDownloads(f, filepath.Join(path, f.Name), args)
+			if err != nil {
+				return nil, err
+			}
+			downloads = append(downloads, children...)
+		} else if isBinary(f) {
+			downloads = append(downloads, directoryDownload{file: f, path: path})
+		} else if !args.Export {
+			self.message(args.Out, "Skipping google document %s, use --export to export it", filepath.Join(path, f.Name))
+		} else if _, err := args.exportMime(f); err != nil {
+			self.message(args.Out, "Skipping %s: %s", filepath.Join(path, f.Name), err)
+		} else {
+			downloads = append(downloads, directoryDownload{file: f, path: path})
 		}
 	}
 
-	return nil
+	return downloads, nil
 }
 
 func isDir(f *drive.File) bool {
@@ -0,0 +1,53 @@
+package drive
+
+import (
+	"crypto/md5"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+var errMd5Mismatch = fmt.Errorf("md5 checksum does not match the remote file")
+
+// Returns the size of a previously interrupted download, or zero
+// if there is nothing to resume
+func incompleteOffset(tmpPath string, size int64) int64 {
+	info, err := os.Stat(tmpPath)
+	if err != nil || !info.Mode().IsRegular() || info.Size() >= size {
+		return 0
+	}
+	return info.Size()
+}
+
+// Opens the incomplete file for writing at offset. The returned hash
+// already includes the content before offset
+func openIncompleteFile(tmpPath string, offset int64) (*os.File, hash.Hash, error) {
+	hasher := md5.New()
+
+	if offset == 0 {
+		f, err := os.Create(tmpPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Unable to create new file: %s", err)
+		}
+		return f, hasher, nil
+	}
+
+	f, err := os.OpenFile(tmpPath, os.O_RDWR, 0666)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to open incomplete file: %s", err)
+	}
+
+	if _, err := io.CopyN(hasher, f, offset); err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("Failed reading incomplete file: %s", err)
+	}
+
+	// Discard anything after offset
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("Failed truncating incomplete file: %s", err)
+	}
+
+	return f, hasher, nil
+}
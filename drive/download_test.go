@@ -0,0 +1,215 @@
+package drive
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/prasmussen/gdrive/drive/drivetest"
+	"google.golang.org/api/drive/v3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDownloadFile(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	id := server.AddFile(drivetest.RootId, "hello.txt", []byte("hello world"))
+	dir := t.TempDir()
+
+	err := gdrive.Download(DownloadArgs{
+		Out:  ioutil.Discard,
+		Id:   id,
+		Path: dir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertLocalFile(t, filepath.Join(dir, "hello.txt"), []byte("hello world"))
+}
+
+func TestDownloadRecursive(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	folderId := server.AddFolder(drivetest.RootId, "docs")
+	subId := server.AddFolder(folderId, "sub")
+	server.AddFile(folderId, "a.txt", []byte("a"))
+	server.AddFile(subId, "b.txt", []byte("b"))
+	dir := t.TempDir()
+
+	err := gdrive.Download(DownloadArgs{
+		Out:       ioutil.Discard,
+		Id:        folderId,
+		Path:      dir,
+		Recursive: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertLocalFile(t, filepath.Join(dir, "docs", "a.txt"), []byte("a"))
+	assertLocalFile(t, filepath.Join(dir, "docs", "sub", "b.txt"), []byte("b"))
+}
+
+func TestDownloadRecursiveExport(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	folderId := server.AddFolder(drivetest.RootId, "docs")
+	subId := server.AddFolder(folderId, "sub")
+	server.AddFile(folderId, "a.txt", []byte("a"))
+	server.Insert(&drive.File{Name: "report", Parents: []string{subId}, MimeType: "application/vnd.google-apps.document"}, []byte("report"))
+	server.Insert(&drive.File{Name: "budget", Parents: []string{subId}, MimeType: "application/vnd.google-apps.spreadsheet"}, []byte("budget"))
+
+	// Documents are skipped unless they should be exported
+	dir := t.TempDir()
+	err := gdrive.Download(DownloadArgs{Out: ioutil.Discard, Id: folderId, Path: dir, Recursive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "docs", "sub", "report.pdf")); err == nil {
+		t.Fatal("Expected documents to be skipped without --export")
+	}
+
+	err = gdrive.Download(DownloadArgs{
+		Out:         ioutil.Discard,
+		Id:          folderId,
+		Path:        dir,
+		Recursive:   true,
+		Skip:        true,
+		Export:      true,
+		ExportMimes: map[string]string{"spreadsheet": "text/tab-separated-values"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertLocalFile(t, filepath.Join(dir, "docs", "a.txt"), []byte("a"))
+	assertLocalFile(t, filepath.Join(dir, "docs", "sub", "report.pdf"), []byte("report"))
+	assertLocalFile(t, filepath.Join(dir, "docs", "sub", "budget.tsv"), []byte("budget"))
+}
+
+func TestDownloadSkipsExistingFiles(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	id := server.AddFile(drivetest.RootId, "a.txt", []byte("remote"))
+
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"a.txt": "local"})
+
+	gdrive.SetRenderer(jsonRenderer{lines: true})
+	out := &bytes.Buffer{}
+	err := gdrive.Download(DownloadArgs{Out: out, Id: id, Path: dir, Skip: true, Delete: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertLocalFile(t, filepath.Join(dir, "a.txt"), []byte("local"))
+
+	var skipped []string
+	downloaded := false
+	dec := json.NewDecoder(out)
+	for dec.More() {
+		event := Event{}
+		if err := dec.Decode(&event); err != nil {
+			t.Fatal(err)
+		}
+		if event.Action == "skip" {
+			skipped = append(skipped, event.Path)
+		}
+		if event.Action == "downloaded" {
+			downloaded = true
+		}
+	}
+
+	if len(skipped) != 1 || skipped[0] != filepath.Join(dir, "a.txt") {
+		t.Fatalf("Expected a skip event for a.txt, got %v", skipped)
+	}
+
+	// Skipped files are not reported as downloaded or removed from drive
+	if downloaded {
+		t.Fatal("Expected the skipped file not to be reported as downloaded")
+	}
+
+	if f := server.File(id); f == nil || f.Trashed {
+		t.Fatal("Expected the skipped file to be kept on drive")
+	}
+}
+
+func TestDownloadResume(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	content := largeContent(64 * 1024)
+	id := server.AddFile(drivetest.RootId, "large.bin", content)
+
+	// Leave the first half from an interrupted download
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"large.bin.incomplete": string(content[:32*1024])})
+
+	err := gdrive.Download(DownloadArgs{
+		Out:    ioutil.Discard,
+		Id:     id,
+		Path:   dir,
+		Resume: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertLocalFile(t, filepath.Join(dir, "large.bin"), content)
+}
+
+func TestDownloadRejectsCorruptResume(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	content := largeContent(64 * 1024)
+	id := server.AddFile(drivetest.RootId, "large.bin", content)
+
+	// The incomplete file belongs to an older version of the file
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"large.bin.incomplete": "stale content"})
+
+	err := gdrive.Download(DownloadArgs{
+		Out:    ioutil.Discard,
+		Id:     id,
+		Path:   dir,
+		Resume: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertLocalFile(t, filepath.Join(dir, "large.bin"), content)
+}
+
+func TestDownloadNotFound(t *testing.T) {
+	gdrive, _ := newTestDrive(t)
+
+	err := gdrive.Download(DownloadArgs{
+		Out:  ioutil.Discard,
+		Id:   "missing",
+		Path: t.TempDir(),
+	})
+	if err == nil {
+		t.Fatal("Expected download of a missing file to fail")
+	}
+}
+
+func TestDownloadQuery(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	server.AddFile(drivetest.RootId, "a.txt", []byte("a"))
+	server.AddFile(drivetest.RootId, "b.txt", []byte("b"))
+	server.AddFile(drivetest.RootId, "c.bin", []byte("c"))
+	dir := t.TempDir()
+
+	err := gdrive.DownloadQuery(DownloadQueryArgs{
+		Out:   ioutil.Discard,
+		Query: "name contains '.txt' and trashed = false",
+		Path:  dir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertLocalFile(t, filepath.Join(dir, "a.txt"), []byte("a"))
+	assertLocalFile(t, filepath.Join(dir, "b.txt"), []byte("b"))
+
+	if _, err := os.Stat(filepath.Join(dir, "c.bin")); !os.IsNotExist(err) {
+		t.Fatal("Expected c.bin to not match the query")
+	}
+}
@@ -1,19 +1,43 @@
 package drive
 
 import (
-	"google.golang.org/api/drive/v3"
 	"net/http"
 )
 
+const DefaultBaseUrl = "https://www.googleapis.com/"
+
 type Drive struct {
-	service *drive.Service
+	backend  Backend
+	renderer Renderer
+
+	// Set if the backend is google drive, which supports a few more features
+	google *googleBackend
+
+	// Shared drive of each sync root by root id, roots in my drive are left out
+	syncDrives map[string]string
 }
 
 func New(client *http.Client) (*Drive, error) {
-	service, err := drive.New(client)
+	return NewWithBaseUrl(client, DefaultBaseUrl)
+}
+
+// Same as New, but talks to the drive api at baseUrl instead of google,
+// i.e. a fake server used for testing
+func NewWithBaseUrl(client *http.Client, baseUrl string) (*Drive, error) {
+	backend, err := newGoogleBackend(client, baseUrl)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Drive{service}, nil
+	return &Drive{backend: backend, google: backend, renderer: tableRenderer{}}, nil
+}
+
+// Operates on the given backend instead of google drive
+func NewWithBackend(backend Backend) *Drive {
+	return &Drive{backend: backend, renderer: tableRenderer{}}
+}
+
+// Sets how command results are written, the default is a human readable table
+func (self *Drive) SetRenderer(renderer Renderer) {
+	self.renderer = renderer
 }
@@ -0,0 +1,104 @@
+package drive
+
+import (
+	"bytes"
+	"github.com/prasmussen/gdrive/drive/drivetest"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestDrive(t *testing.T) (*Drive, *drivetest.Server) {
+	server := drivetest.NewServer()
+	t.Cleanup(server.Close)
+
+	gdrive, err := NewWithBaseUrl(server.Client(), server.BaseUrl())
+	if err != nil {
+		t.Fatalf("Failed to create drive: %s", err)
+	}
+
+	return gdrive, server
+}
+
+// Compares files by md5, like the comparer used by the cli
+type testComparer struct{}
+
+func (self testComparer) Changed(local *LocalFile, remote *RemoteFile) bool {
+	hasher, err := md5File(local.AbsPath())
+	if err != nil {
+		return true
+	}
+	return formatMd5(hasher) != remote.Md5()
+}
+
+// Writes files relative to dir, creating parent directories as needed
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func assertLocalFile(t *testing.T, path string, expected []byte) {
+	t.Helper()
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %s", path, err)
+	}
+
+	if !bytes.Equal(content, expected) {
+		t.Fatalf("Unexpected content of %s, expected %q got %q", path, expected, content)
+	}
+}
+
+func assertRemoteFile(t *testing.T, server *drivetest.Server, parentId, path string, expected []byte) {
+	t.Helper()
+
+	f := server.Find(parentId, path)
+	if f == nil {
+		t.Fatalf("Remote file %s does not exist", path)
+	}
+
+	if content := server.Content(f.Id); !bytes.Equal(content, expected) {
+		t.Fatalf("Unexpected content of remote file %s, expected %q got %q", path, expected, content)
+	}
+}
+
+// Returns content that needs several upload chunks
+func largeContent(size int) []byte {
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	return content
+}
+
+// Counts requests starting with prefix, i.e. 'PUT ' or 'GET /drive/v3/about'
+func countRequests(server *drivetest.Server, prefix string) int {
+	count := 0
+	for _, r := range server.Requests() {
+		if strings.HasPrefix(r, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+func assertRequested(t *testing.T, server *drivetest.Server, request string) {
+	t.Helper()
+
+	for _, r := range server.Requests() {
+		if r == request {
+			return
+		}
+	}
+	t.Fatalf("Expected a '%s' request", request)
+}
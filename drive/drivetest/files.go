@@ -0,0 +1,729 @@
+package drivetest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"github.com/prasmussen/gdrive/drive/query"
+	"google.golang.org/api/drive/v3"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type apiError struct {
+	status  int
+	reason  string
+	message string
+}
+
+func (self *apiError) write(w http.ResponseWriter) {
+	writeError(w, self.status, self.reason, self.message)
+}
+
+func badRequest(format string, a ...interface{}) *apiError {
+	return &apiError{http.StatusBadRequest, "invalid", fmt.Sprintf(format, a...)}
+}
+
+func fileNotFound(id string) *apiError {
+	return &apiError{http.StatusNotFound, "notFound", fmt.Sprintf("File not found: %s.", id)}
+}
+
+func isGoogleType(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "application/vnd.google-apps.")
+}
+
+func (self *file) isDir() bool {
+	return self.meta.MimeType == FolderMimeType
+}
+
+func (self *Server) lookup(id string) *file {
+	if id == "root" {
+		id = RootId
+	}
+	return self.files[id]
+}
+
+func (self *Server) isRoot(f *file) bool {
+	return f.meta.Id == RootId || f.meta.Id == f.driveId
+}
+
+// Files are trashed if they, or any of their ancestors, are trashed
+func (self *Server) isTrashed(f *file) bool {
+	seen := map[string]bool{}
+	for f != nil && !seen[f.meta.Id] {
+		if f.meta.Trashed {
+			return true
+		}
+		seen[f.meta.Id] = true
+
+		if len(f.meta.Parents) == 0 {
+			return false
+		}
+		f = self.files[f.meta.Parents[0]]
+	}
+	return false
+}
+
+// Returns a copy of the file metadata as it is returned by the api
+func (self *Server) resource(f *file) *drive.File {
+	meta := *f.meta
+	meta.Kind = "drive#file"
+	meta.Trashed = self.isTrashed(f)
+	meta.ExplicitlyTrashed = f.meta.Trashed
+	if f.driveId == "" {
+		meta.OwnedByMe = true
+		meta.Owners = []*drive.User{{DisplayName: UserName, EmailAddress: UserEmail, Me: true}}
+	}
+	return &meta
+}
+
+// Same as resource, but includes the driveId of files in shared
+// drives which the generated client has no field for
+func (self *Server) resourceJson(f *file) interface{} {
+	meta := self.resource(f)
+	if f.driveId == "" {
+		return meta
+	}
+
+	data, _ := json.Marshal(meta)
+	res := map[string]interface{}{}
+	json.Unmarshal(data, &res)
+	res["driveId"] = f.driveId
+	return res
+}
+
+// Returns all descendants of the given folder, depth first
+func (self *Server) descendants(id string) []*file {
+	var files []*file
+	for _, f := range self.sortedFiles() {
+		for _, parent := range f.meta.Parents {
+			if parent == id {
+				files = append(files, f)
+				files = append(files, self.descendants(f.meta.Id)...)
+				break
+			}
+		}
+	}
+	return files
+}
+
+// Returns all files in creation order
+func (self *Server) sortedFiles() []*file {
+	var files []*file
+	for _, f := range self.files {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].seq < files[j].seq
+	})
+	return files
+}
+
+func (self *Server) recordChange(f *file, removed bool) {
+	self.changes = append(self.changes, &change{
+		fileId:  f.meta.Id,
+		driveId: f.driveId,
+		removed: removed,
+		time:    self.now(),
+	})
+}
+
+func (self *Server) insert(raw map[string]json.RawMessage, content []byte, contentType string) (*file, *apiError) {
+	now := self.now()
+	self.seq++
+
+	f := &file{
+		seq: self.seq,
+		meta: &drive.File{
+			Id:           self.nextId("file"),
+			Name:         "Untitled",
+			CreatedTime:  now,
+			ModifiedTime: now,
+			Parents:      []string{RootId},
+		},
+	}
+
+	if err := self.applyMetadata(f, raw, true); err != nil {
+		return nil, err
+	}
+
+	// The new file belongs to the same drive as its parent
+	parent := self.files[f.meta.Parents[0]]
+	f.driveId = parent.driveId
+
+	if f.meta.MimeType == "" {
+		f.meta.MimeType = contentType
+	}
+	if f.meta.MimeType == "" {
+		f.meta.MimeType = "application/octet-stream"
+	}
+
+	if f.isDir() && content != nil {
+		return nil, badRequest("Folders can not have content")
+	}
+
+	if !f.isDir() {
+		self.setContent(f, content, raw)
+	}
+
+	self.files[f.meta.Id] = f
+	self.recordChange(f, false)
+	return f, nil
+}
+
+func (self *Server) update(f *file, raw map[string]json.RawMessage, r *http.Request, content []byte) *apiError {
+	if err := self.applyMetadata(f, raw, false); err != nil {
+		return err
+	}
+
+	params := r.URL.Query()
+	if err := self.moveFile(f, splitIds(params.Get("addParents")), splitIds(params.Get("removeParents"))); err != nil {
+		return err
+	}
+
+	if content != nil {
+		if f.isDir() {
+			return badRequest("Folders can not have content")
+		}
+		self.setContent(f, content, raw)
+	}
+
+	if _, ok := raw["modifiedTime"]; !ok && content != nil {
+		f.meta.ModifiedTime = self.now()
+	}
+
+	f.meta.Version++
+	self.recordChange(f, false)
+	return nil
+}
+
+func (self *Server) moveFile(f *file, add, remove []string) *apiError {
+	for _, id := range add {
+		parent := self.lookup(id)
+		if parent == nil {
+			return fileNotFound(id)
+		}
+		if !parent.isDir() {
+			return badRequest("Parent %s is not a folder", id)
+		}
+		if parent.meta.Id == f.meta.Id || self.isAncestor(f, parent) {
+			return badRequest("A folder can not be moved into itself")
+		}
+		f.meta.Parents = append(f.meta.Parents, parent.meta.Id)
+	}
+
+	for _, id := range remove {
+		if p := self.lookup(id); p != nil {
+			id = p.meta.Id
+		}
+
+		var parents []string
+		for _, parent := range f.meta.Parents {
+			if parent != id {
+				parents = append(parents, parent)
+			}
+		}
+		f.meta.Parents = parents
+	}
+
+	return nil
+}
+
+// Returns true if f is an ancestor of other
+func (self *Server) isAncestor(f, other *file) bool {
+	for _, d := range self.descendants(f.meta.Id) {
+		if d == other {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *Server) applyMetadata(f *file, raw map[string]json.RawMessage, create bool) *apiError {
+	for key, value := range raw {
+		var err error
+
+		switch key {
+		case "name":
+			err = json.Unmarshal(value, &f.meta.Name)
+		case "description":
+			err = json.Unmarshal(value, &f.meta.Description)
+		case "mimeType":
+			err = json.Unmarshal(value, &f.meta.MimeType)
+		case "starred":
+			err = json.Unmarshal(value, &f.meta.Starred)
+		case "trashed":
+			err = json.Unmarshal(value, &f.meta.Trashed)
+		case "originalFilename":
+			err = json.Unmarshal(value, &f.meta.OriginalFilename)
+		case "modifiedTime":
+			err = json.Unmarshal(value, &f.meta.ModifiedTime)
+			if err == nil {
+				_, err = time.Parse(time.RFC3339, f.meta.ModifiedTime)
+			}
+		case "appProperties":
+			f.meta.AppProperties, err = mergeProperties(f.meta.AppProperties, value)
+		case "properties":
+			f.meta.Properties, err = mergeProperties(f.meta.Properties, value)
+		case "parents":
+			if !create {
+				return badRequest("The parents field is not directly writable in update requests")
+			}
+
+			var parents []string
+			if err = json.Unmarshal(value, &parents); err == nil && len(parents) > 0 {
+				f.meta.Parents = nil
+				if apiErr := self.moveFile(f, parents, nil); apiErr != nil {
+					return apiErr
+				}
+			}
+		}
+
+		if err != nil {
+			return badRequest("Invalid value for field %s: %s", key, err)
+		}
+	}
+
+	return nil
+}
+
+// Properties are merged with the existing ones, null values removes a property
+func mergeProperties(props map[string]string, value json.RawMessage) (map[string]string, error) {
+	var updates map[string]*string
+	if err := json.Unmarshal(value, &updates); err != nil {
+		return nil, err
+	}
+
+	merged := map[string]string{}
+	for k, v := range props {
+		merged[k] = v
+	}
+
+	for k, v := range updates {
+		if v == nil {
+			delete(merged, k)
+		} else {
+			merged[k] = *v
+		}
+	}
+
+	if len(merged) == 0 {
+		return nil, nil
+	}
+	return merged, nil
+}
+
+// Stores new content, binary files get a checksum and a new revision
+func (self *Server) setContent(f *file, content []byte, raw map[string]json.RawMessage) {
+	if content == nil {
+		content = []byte{}
+	}
+	f.content = append([]byte{}, content...)
+
+	if isGoogleType(f.meta.MimeType) {
+		return
+	}
+
+	f.meta.Md5Checksum = fmt.Sprintf("%x", md5.Sum(content))
+	f.meta.Size = int64(len(content))
+
+	if f.meta.OriginalFilename == "" {
+		f.meta.OriginalFilename = f.meta.Name
+	}
+
+	f.nextRevision++
+	rev := &revision{
+		meta: &drive.Revision{
+			Id:               strconv.Itoa(f.nextRevision),
+			Kind:             "drive#revision",
+			MimeType:         f.meta.MimeType,
+			ModifiedTime:     self.now(),
+			Md5Checksum:      f.meta.Md5Checksum,
+			Size:             f.meta.Size,
+			OriginalFilename: f.meta.Name,
+		},
+		content: f.content,
+	}
+	f.revisions = append(f.revisions, rev)
+	f.meta.HeadRevisionId = rev.meta.Id
+}
+
+func splitIds(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func decodeMetadata(r *http.Request) (map[string]json.RawMessage, *apiError) {
+	raw := map[string]json.RawMessage{}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, badRequest("Failed to read body: %s", err)
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		return raw, nil
+	}
+
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, badRequest("Invalid json: %s", err)
+	}
+
+	return raw, nil
+}
+
+func (self *Server) createFile(w http.ResponseWriter, r *http.Request, upload bool) {
+	if upload {
+		self.handleUpload(w, r, "")
+		return
+	}
+
+	raw, err := decodeMetadata(r)
+	if err != nil {
+		err.write(w)
+		return
+	}
+
+	f, err := self.insert(raw, nil, "")
+	if err != nil {
+		err.write(w)
+		return
+	}
+
+	writeJson(w, http.StatusOK, self.resource(f))
+}
+
+// Copies the content and metadata of a file, metadata in the request overrides the copied values
+func (self *Server) copyFile(w http.ResponseWriter, r *http.Request, id string) {
+	src := self.lookup(id)
+	if src == nil {
+		notFound(w, id)
+		return
+	}
+
+	if src.isDir() {
+		writeError(w, http.StatusForbidden, "cannotCopyFile", "Folders can not be copied")
+		return
+	}
+
+	overrides, apiErr := decodeMetadata(r)
+	if apiErr != nil {
+		apiErr.write(w)
+		return
+	}
+
+	data, err := json.Marshal(&drive.File{
+		Name:          src.meta.Name,
+		MimeType:      src.meta.MimeType,
+		Description:   src.meta.Description,
+		Parents:       src.meta.Parents,
+		Properties:    src.meta.Properties,
+		AppProperties: src.meta.AppProperties,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		panic(err)
+	}
+	for key, value := range overrides {
+		raw[key] = value
+	}
+
+	f, apiErr := self.insert(raw, append([]byte{}, src.content...), "")
+	if apiErr != nil {
+		apiErr.write(w)
+		return
+	}
+
+	writeJson(w, http.StatusOK, self.resource(f))
+}
+
+func (self *Server) updateFile(w http.ResponseWriter, r *http.Request, id string, upload bool) {
+	f := self.lookup(id)
+	if f == nil {
+		notFound(w, id)
+		return
+	}
+
+	if upload {
+		self.handleUpload(w, r, f.meta.Id)
+		return
+	}
+
+	raw, err := decodeMetadata(r)
+	if err != nil {
+		err.write(w)
+		return
+	}
+
+	if err := self.update(f, raw, r, nil); err != nil {
+		err.write(w)
+		return
+	}
+
+	writeJson(w, http.StatusOK, self.resource(f))
+}
+
+func (self *Server) getFile(w http.ResponseWriter, r *http.Request, id string) {
+	f := self.lookup(id)
+	if f == nil {
+		notFound(w, id)
+		return
+	}
+
+	if r.URL.Query().Get("alt") != "media" {
+		writeJson(w, http.StatusOK, self.resourceJson(f))
+		return
+	}
+
+	if f.isDir() || isGoogleType(f.meta.MimeType) {
+		writeError(w, http.StatusForbidden, "fileNotDownloadable", "Only files with binary content can be downloaded. Use Export with Docs Editors files.")
+		return
+	}
+
+	writeContent(w, r, f.meta.MimeType, f.content)
+}
+
+// Writes content, honoring a range header
+func writeContent(w http.ResponseWriter, r *http.Request, mimeType string, content []byte) {
+	w.Header().Set("Content-Type", mimeType)
+
+	start, end, ok := parseRange(r.Header.Get("Range"), int64(len(content)))
+	if !ok {
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(content)
+		return
+	}
+
+	if start >= int64(len(content)) {
+		writeError(w, http.StatusRequestedRangeNotSatisfiable, "requestedRangeNotSatisfiable", "Request range not satisfiable")
+		return
+	}
+
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(content)))
+	w.Header().Set("Content-Length", strconv.FormatInt(end-start+1, 10))
+	w.WriteHeader(http.StatusPartialContent)
+	w.Write(content[start : end+1])
+}
+
+// Parses ranges on the form 'bytes=N-' and 'bytes=N-M'
+func parseRange(header string, size int64) (int64, int64, bool) {
+	if !strings.HasPrefix(header, "bytes=") {
+		return 0, 0, false
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(header, "bytes="), "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+
+	start, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	end := size - 1
+	if parts[1] != "" {
+		end, err = strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		if end > size-1 {
+			end = size - 1
+		}
+	}
+
+	return start, end, true
+}
+
+func (self *Server) deleteFile(w http.ResponseWriter, r *http.Request, id string) {
+	f := self.lookup(id)
+	if f == nil {
+		notFound(w, id)
+		return
+	}
+
+	if self.isRoot(f) {
+		writeError(w, http.StatusForbidden, "insufficientFilePermissions", "The root folder can not be deleted")
+		return
+	}
+
+	self.remove(f)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Permanently removes all trashed files in my drive, or in the given shared drive
+func (self *Server) emptyTrash(w http.ResponseWriter, r *http.Request) {
+	driveId := r.URL.Query().Get("driveId")
+
+	for _, f := range self.sortedFiles() {
+		// Files below a removed folder are already gone
+		if self.files[f.meta.Id] == nil {
+			continue
+		}
+
+		if f.meta.Trashed && f.driveId == driveId {
+			self.remove(f)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Permanently removes the file and everything below it
+func (self *Server) remove(f *file) {
+	for _, d := range self.descendants(f.meta.Id) {
+		delete(self.files, d.meta.Id)
+		self.recordChange(d, true)
+	}
+	delete(self.files, f.meta.Id)
+	self.recordChange(f, true)
+}
+
+func (self *Server) exportFile(w http.ResponseWriter, r *http.Request, id string) {
+	f := self.lookup(id)
+	if f == nil {
+		notFound(w, id)
+		return
+	}
+
+	mimeType := r.URL.Query().Get("mimeType")
+	if mimeType == "" {
+		writeError(w, http.StatusBadRequest, "required", "Required parameter: mimeType")
+		return
+	}
+
+	if f.isDir() || !isGoogleType(f.meta.MimeType) {
+		writeError(w, http.StatusForbidden, "fileNotExportable", "Export only supports Docs Editors files.")
+		return
+	}
+
+	// Content is exported as is, regardless of the requested format
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(f.content)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(f.content)
+}
+
+func (self *Server) listFiles(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	match, err := query.Parse(params.Get("q"), serverEnv{self})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid", fmt.Sprintf("Invalid Value: %s", err))
+		return
+	}
+
+	driveId := params.Get("driveId")
+	allDrives := params.Get("corpora") == "allDrives" && params.Get("includeItemsFromAllDrives") == "true"
+
+	var files []*drive.File
+	for _, f := range self.sortedFiles() {
+		if self.isRoot(f) {
+			continue
+		}
+
+		if !allDrives && f.driveId != driveId {
+			continue
+		}
+
+		if match(f.meta) {
+			files = append(files, f.meta)
+		}
+	}
+
+	if err := query.Sort(files, params.Get("orderBy")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid", fmt.Sprintf("Invalid Value: %s", err))
+		return
+	}
+
+	start, end, nextPageToken, apiErr := paginate(len(files), params.Get("pageSize"), params.Get("pageToken"), 100)
+	if apiErr != nil {
+		apiErr.write(w)
+		return
+	}
+
+	list := &drive.FileList{Kind: "drive#fileList", NextPageToken: nextPageToken, Files: []*drive.File{}}
+	for _, meta := range files[start:end] {
+		list.Files = append(list.Files, self.resource(self.files[meta.Id]))
+	}
+
+	writeJson(w, http.StatusOK, list)
+}
+
+// Returns the slice bounds of the requested page and the token of the next page
+func paginate(count int, pageSize, pageToken string, defaultSize int) (int, int, string, *apiError) {
+	size := defaultSize
+	if pageSize != "" {
+		n, err := strconv.Atoi(pageSize)
+		if err != nil || n < 1 || n > 1000 {
+			return 0, 0, "", badRequest("Invalid page size: %s", pageSize)
+		}
+		size = n
+	}
+
+	start := 0
+	if pageToken != "" {
+		n, err := strconv.Atoi(pageToken)
+		if err != nil || n < 0 || n > count {
+			return 0, 0, "", badRequest("Invalid page token: %s", pageToken)
+		}
+		start = n
+	}
+
+	end := start + size
+	if end >= count {
+		return start, count, "", nil
+	}
+
+	return start, end, strconv.Itoa(end), nil
+}
+
+// Evaluates the parts of search queries that depends on the server state
+type serverEnv struct {
+	server *Server
+}
+
+func (self serverEnv) IsTrashed(meta *drive.File) bool {
+	return self.server.isTrashed(self.server.files[meta.Id])
+}
+
+func (self serverEnv) IsMember(meta *drive.File, collection, value string) bool {
+	f := self.server.files[meta.Id]
+
+	switch collection {
+	case "parents":
+		if value == "root" {
+			value = RootId
+		}
+		for _, parent := range meta.Parents {
+			if parent == value {
+				return true
+			}
+		}
+	case "owners":
+		return f.driveId == "" && (value == "me" || value == UserEmail)
+	case "writers", "readers":
+		if f.driveId == "" && (value == "me" || value == UserEmail) {
+			return true
+		}
+		for _, p := range f.permissions {
+			if p.EmailAddress == value && (collection == "readers" || p.Role == "writer" || p.Role == "owner") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,196 @@
+package drivetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"path"
+	"strings"
+)
+
+// Helpers for setting up and inspecting the server state directly, without going through the api
+
+// Creates a file from the given metadata and returns its id, content is
+// ignored for folders. Panics if the metadata is invalid
+func (self *Server) Insert(meta *drive.File, content []byte) string {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	data, err := json.Marshal(meta)
+	if err != nil {
+		panic(err)
+	}
+
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		panic(err)
+	}
+
+	if meta.MimeType == FolderMimeType {
+		content = nil
+	}
+
+	f, apiErr := self.insert(raw, content, "")
+	if apiErr != nil {
+		panic(apiErr.message)
+	}
+
+	return f.meta.Id
+}
+
+func (self *Server) AddFolder(parentId, name string) string {
+	return self.Insert(&drive.File{Name: name, Parents: []string{parentId}, MimeType: FolderMimeType}, nil)
+}
+
+func (self *Server) AddFile(parentId, name string, content []byte) string {
+	return self.Insert(&drive.File{Name: name, Parents: []string{parentId}}, content)
+}
+
+// Adds a shared drive and returns its id, which is also the id of its root folder
+func (self *Server) AddDrive(name string) string {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	id := self.nextId("drive")
+	now := self.now()
+
+	self.seq++
+	self.files[id] = &file{
+		seq:     self.seq,
+		driveId: id,
+		meta: &drive.File{
+			Id:           id,
+			Name:         name,
+			MimeType:     FolderMimeType,
+			CreatedTime:  now,
+			ModifiedTime: now,
+		},
+	}
+	self.drives = append(self.drives, &sharedDrive{Id: id, Name: name, CreatedTime: now})
+
+	return id
+}
+
+// Replaces the content of a file as if it was changed by another client
+func (self *Server) SetContent(id string, content []byte) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	f := self.mustLookup(id)
+	self.setContent(f, content, nil)
+	f.meta.ModifiedTime = self.now()
+	self.recordChange(f, false)
+}
+
+// Changes the metadata of a file as if it was changed by another client
+func (self *Server) Modify(id string, fn func(meta *drive.File)) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	f := self.mustLookup(id)
+	fn(f.meta)
+	self.recordChange(f, false)
+}
+
+// Permanently removes a file and all its descendants
+func (self *Server) Remove(id string) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.remove(self.mustLookup(id))
+}
+
+// Returns a copy of the file metadata, or nil if the file does not exist
+func (self *Server) File(id string) *drive.File {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	f := self.lookup(id)
+	if f == nil {
+		return nil
+	}
+	return self.resource(f)
+}
+
+func (self *Server) Content(id string) []byte {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	f := self.mustLookup(id)
+	return append([]byte{}, f.content...)
+}
+
+// Returns the files directly below the given folder, in creation order
+func (self *Server) Children(parentId string) []*drive.File {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	parent := self.mustLookup(parentId)
+
+	var files []*drive.File
+	for _, f := range self.sortedFiles() {
+		for _, p := range f.meta.Parents {
+			if p == parent.meta.Id {
+				files = append(files, self.resource(f))
+				break
+			}
+		}
+	}
+	return files
+}
+
+// Finds a file by a slash separated path relative to the given folder,
+// returns nil if it does not exist
+func (self *Server) Find(parentId, p string) *drive.File {
+	id := parentId
+	var found *drive.File
+
+	for _, name := range strings.Split(path.Clean(p), "/") {
+		found = nil
+		for _, f := range self.Children(id) {
+			if f.Name == name {
+				found = f
+				break
+			}
+		}
+
+		if found == nil {
+			return nil
+		}
+		id = found.Id
+	}
+
+	return found
+}
+
+func (self *Server) Revisions(id string) []*drive.Revision {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	var revisions []*drive.Revision
+	for _, rev := range self.mustLookup(id).revisions {
+		meta := *rev.meta
+		revisions = append(revisions, &meta)
+	}
+	return revisions
+}
+
+func (self *Server) Permissions(id string) []*drive.Permission {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	var permissions []*drive.Permission
+	for _, p := range self.mustLookup(id).permissions {
+		perm := *p
+		permissions = append(permissions, &perm)
+	}
+	return permissions
+}
+
+func (self *Server) mustLookup(id string) *file {
+	f := self.lookup(id)
+	if f == nil {
+		panic(fmt.Sprintf("File not found: %s", id))
+	}
+	return f
+}
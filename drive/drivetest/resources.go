@@ -0,0 +1,241 @@
+package drivetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"net/http"
+	"strconv"
+)
+
+func (self *Server) listRevisions(w http.ResponseWriter, r *http.Request, fileId string) {
+	f := self.lookup(fileId)
+	if f == nil {
+		notFound(w, fileId)
+		return
+	}
+
+	list := &drive.RevisionList{Kind: "drive#revisionList", Revisions: []*drive.Revision{}}
+	for _, rev := range f.revisions {
+		list.Revisions = append(list.Revisions, rev.meta)
+	}
+
+	writeJson(w, http.StatusOK, list)
+}
+
+func (self *Server) findRevision(w http.ResponseWriter, fileId, revId string) (*file, int) {
+	f := self.lookup(fileId)
+	if f == nil {
+		notFound(w, fileId)
+		return nil, -1
+	}
+
+	for i, rev := range f.revisions {
+		if rev.meta.Id == revId {
+			return f, i
+		}
+	}
+
+	writeError(w, http.StatusNotFound, "notFound", fmt.Sprintf("Revision not found: %s.", revId))
+	return nil, -1
+}
+
+func (self *Server) getRevision(w http.ResponseWriter, r *http.Request, fileId, revId string) {
+	f, i := self.findRevision(w, fileId, revId)
+	if f == nil {
+		return
+	}
+
+	rev := f.revisions[i]
+	if r.URL.Query().Get("alt") == "media" {
+		writeContent(w, r, rev.meta.MimeType, rev.content)
+		return
+	}
+
+	writeJson(w, http.StatusOK, rev.meta)
+}
+
+func (self *Server) deleteRevision(w http.ResponseWriter, r *http.Request, fileId, revId string) {
+	f, i := self.findRevision(w, fileId, revId)
+	if f == nil {
+		return
+	}
+
+	// The head revision holds the current content
+	if i == len(f.revisions)-1 {
+		writeError(w, http.StatusBadRequest, "cannotDeleteOnlyRevision", "The head revision can not be deleted")
+		return
+	}
+
+	f.revisions = append(f.revisions[:i], f.revisions[i+1:]...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (self *Server) listPermissions(w http.ResponseWriter, r *http.Request, fileId string) {
+	f := self.lookup(fileId)
+	if f == nil {
+		notFound(w, fileId)
+		return
+	}
+
+	list := &drive.PermissionList{Kind: "drive#permissionList", Permissions: []*drive.Permission{}}
+	list.Permissions = append(list.Permissions, f.permissions...)
+	writeJson(w, http.StatusOK, list)
+}
+
+func (self *Server) createPermission(w http.ResponseWriter, r *http.Request, fileId string) {
+	f := self.lookup(fileId)
+	if f == nil {
+		notFound(w, fileId)
+		return
+	}
+
+	p := &drive.Permission{}
+	if err := json.NewDecoder(r.Body).Decode(p); err != nil {
+		badRequest("Invalid json: %s", err).write(w)
+		return
+	}
+
+	switch {
+	case p.Role == "" || p.Type == "":
+		badRequest("Permission role and type are required").write(w)
+		return
+	case (p.Type == "user" || p.Type == "group") && p.EmailAddress == "":
+		badRequest("Permission of type %s requires an email address", p.Type).write(w)
+		return
+	case p.Type == "domain" && p.Domain == "":
+		badRequest("Permission of type domain requires a domain").write(w)
+		return
+	}
+
+	p.Kind = "drive#permission"
+	if p.Type == "anyone" {
+		p.Id = "anyoneWithLink"
+	} else {
+		p.Id = self.nextId("perm")
+	}
+
+	f.permissions = append(f.permissions, p)
+	f.meta.Shared = true
+	self.recordChange(f, false)
+
+	writeJson(w, http.StatusOK, p)
+}
+
+func (self *Server) deletePermission(w http.ResponseWriter, r *http.Request, fileId, permId string) {
+	f := self.lookup(fileId)
+	if f == nil {
+		notFound(w, fileId)
+		return
+	}
+
+	for i, p := range f.permissions {
+		if p.Id == permId {
+			f.permissions = append(f.permissions[:i], f.permissions[i+1:]...)
+			f.meta.Shared = len(f.permissions) > 0
+			self.recordChange(f, false)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, "notFound", fmt.Sprintf("Permission not found: %s.", permId))
+}
+
+// Page tokens are positions in the change log, starting at one
+func (self *Server) getStartPageToken(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, http.StatusOK, &drive.StartPageToken{
+		Kind:           "drive#startPageToken",
+		StartPageToken: strconv.Itoa(len(self.changes) + 1),
+	})
+}
+
+func (self *Server) listChanges(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	start, err := strconv.Atoi(params.Get("pageToken"))
+	if err != nil || start < 1 || start > len(self.changes)+1 {
+		badRequest("Invalid page token: %s", params.Get("pageToken")).write(w)
+		return
+	}
+
+	pageSize := 100
+	if s := params.Get("pageSize"); s != "" {
+		pageSize, err = strconv.Atoi(s)
+		if err != nil || pageSize < 1 || pageSize > 1000 {
+			badRequest("Invalid page size: %s", s).write(w)
+			return
+		}
+	}
+
+	driveId := params.Get("driveId")
+	list := &drive.ChangeList{Kind: "drive#changeList", Changes: []*drive.Change{}}
+
+	i := start - 1
+	for ; i < len(self.changes) && len(list.Changes) < pageSize; i++ {
+		c := self.changes[i]
+		if c.driveId != driveId {
+			continue
+		}
+
+		// Changes always reflect the current state of the file
+		entry := &drive.Change{Kind: "drive#change", FileId: c.fileId, Time: c.time, Removed: true}
+		if f := self.files[c.fileId]; f != nil && !c.removed {
+			entry.Removed = false
+			entry.File = self.resource(f)
+		}
+		list.Changes = append(list.Changes, entry)
+	}
+
+	if i >= len(self.changes) {
+		list.NewStartPageToken = strconv.Itoa(len(self.changes) + 1)
+	} else {
+		list.NextPageToken = strconv.Itoa(i + 1)
+	}
+
+	writeJson(w, http.StatusOK, list)
+}
+
+func (self *Server) getAbout(w http.ResponseWriter, r *http.Request) {
+	var usage int64
+	for _, f := range self.files {
+		usage += f.meta.Size
+	}
+
+	writeJson(w, http.StatusOK, &drive.About{
+		Kind: "drive#about",
+		User: &drive.User{DisplayName: UserName, EmailAddress: UserEmail, Me: true},
+		StorageQuota: &drive.AboutStorageQuota{
+			Limit: self.quota,
+			Usage: usage,
+		},
+		MaxUploadSize:  5 * 1024 * 1024 * 1024 * 1024,
+		MaxImportSizes: map[string]string{"application/vnd.google-apps.document": "10485760"},
+		ImportFormats: map[string][]string{
+			"text/plain": {"application/vnd.google-apps.document"},
+			"text/csv":   {"application/vnd.google-apps.spreadsheet"},
+		},
+		ExportFormats: map[string][]string{
+			"application/vnd.google-apps.document":     {"application/pdf", "text/plain", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+			"application/vnd.google-apps.spreadsheet":  {"application/pdf", "text/csv", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+			"application/vnd.google-apps.presentation": {"application/pdf", "application/vnd.openxmlformats-officedocument.presentationml.presentation"},
+			"application/vnd.google-apps.drawing":      {"application/pdf", "image/png"},
+		},
+	})
+}
+
+func (self *Server) listDrives(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	start, end, nextPageToken, err := paginate(len(self.drives), params.Get("pageSize"), params.Get("pageToken"), 10)
+	if err != nil {
+		err.write(w)
+		return
+	}
+
+	writeJson(w, http.StatusOK, map[string]interface{}{
+		"kind":          "drive#driveList",
+		"nextPageToken": nextPageToken,
+		"drives":        self.drives[start:end],
+	})
+}
@@ -0,0 +1,293 @@
+// Package drivetest provides an in-memory stand-in for the drive v3 api,
+// served over httptest, for hermetic end-to-end tests
+package drivetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RootId         = "root-folder"
+	FolderMimeType = "application/vnd.google-apps.folder"
+	UserEmail      = "user@example.com"
+	UserName       = "Test User"
+)
+
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	seq      int
+	files    map[string]*file
+	drives   []*sharedDrive
+	changes  []*change
+	uploads  map[string]*upload
+	faults   []*Fault
+	requests []string
+	quota    int64
+
+	// Bytes committed of every resumable upload chunk, negative means all
+	chunkLimit int64
+}
+
+type file struct {
+	meta         *drive.File
+	driveId      string
+	seq          int
+	content      []byte
+	revisions    []*revision
+	permissions  []*drive.Permission
+	nextRevision int
+}
+
+type revision struct {
+	meta    *drive.Revision
+	content []byte
+}
+
+type sharedDrive struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	CreatedTime string `json:"createdTime"`
+}
+
+type change struct {
+	fileId  string
+	driveId string
+	removed bool
+	time    string
+}
+
+// Fault makes matching requests fail with the given status code
+type Fault struct {
+	// Http method to match, any method if empty
+	Method string
+
+	// Prefix of the request path, i.e. /upload/drive/v3/files
+	Path string
+
+	// Status code and error reason, the reason defaults to what
+	// drive returns for rate limit and backend errors
+	Status int
+	Reason string
+
+	// Number of requests to fail, defaults to one
+	Times int
+}
+
+func NewServer() *Server {
+	self := &Server{
+		files:      map[string]*file{},
+		uploads:    map[string]*upload{},
+		chunkLimit: -1,
+	}
+
+	now := self.now()
+	self.files[RootId] = &file{meta: &drive.File{
+		Id:           RootId,
+		Name:         "My Drive",
+		MimeType:     FolderMimeType,
+		CreatedTime:  now,
+		ModifiedTime: now,
+	}}
+
+	self.Server = httptest.NewServer(self)
+	return self
+}
+
+// Url to pass to drive.NewWithBaseUrl
+func (self *Server) BaseUrl() string {
+	return self.URL + "/"
+}
+
+// Makes the next matching requests fail
+func (self *Server) Fail(fault Fault) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if fault.Times == 0 {
+		fault.Times = 1
+	}
+	self.faults = append(self.faults, &fault)
+}
+
+// Sets the storage quota reported by about, zero means unlimited
+func (self *Server) SetQuota(limit int64) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.quota = limit
+}
+
+// Makes resumable uploads commit at most limit bytes of every chunk, like
+// when drive only receives part of a chunk. A negative limit commits all
+func (self *Server) SetChunkLimit(limit int64) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.chunkLimit = limit
+}
+
+// Returns all requests made so far, formatted as 'METHOD /path'
+func (self *Server) Requests() []string {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return append([]string{}, self.requests...)
+}
+
+func (self *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.requests = append(self.requests, r.Method+" "+r.URL.Path)
+
+	if fault := self.matchFault(r); fault != nil {
+		writeError(w, fault.Status, fault.Reason, fmt.Sprintf("Injected error for %s %s", r.Method, r.URL.Path))
+		return
+	}
+
+	if r.URL.Query().Get("upload_id") != "" {
+		self.handleUploadChunk(w, r)
+		return
+	}
+
+	path := r.URL.Path
+	upload := strings.HasPrefix(path, "/upload/drive/v3/")
+	if upload {
+		path = strings.TrimPrefix(path, "/upload/drive/v3/")
+	} else if strings.HasPrefix(path, "/drive/v3/") {
+		path = strings.TrimPrefix(path, "/drive/v3/")
+	} else {
+		writeError(w, http.StatusNotFound, "notFound", "Not found")
+		return
+	}
+
+	// The generated client does not rewrite upload urls for other hosts
+	// than google, so uploads are accepted on the regular path as well
+	if r.URL.Query().Get("uploadType") != "" {
+		upload = true
+	}
+
+	self.route(w, r, strings.Split(path, "/"), upload)
+}
+
+func (self *Server) route(w http.ResponseWriter, r *http.Request, parts []string, upload bool) {
+	method := r.Method
+
+	switch {
+	case len(parts) == 1 && parts[0] == "files" && method == "GET":
+		self.listFiles(w, r)
+	case len(parts) == 1 && parts[0] == "files" && method == "POST":
+		self.createFile(w, r, upload)
+	case len(parts) == 2 && parts[0] == "files" && method == "GET":
+		self.getFile(w, r, parts[1])
+	case len(parts) == 2 && parts[0] == "files" && method == "PATCH":
+		self.updateFile(w, r, parts[1], upload)
+	case len(parts) == 2 && parts[0] == "files" && parts[1] == "trash" && method == "DELETE":
+		self.emptyTrash(w, r)
+	case len(parts) == 2 && parts[0] == "files" && method == "DELETE":
+		self.deleteFile(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "files" && parts[2] == "copy" && method == "POST":
+		self.copyFile(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "files" && parts[2] == "export" && method == "GET":
+		self.exportFile(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "files" && parts[2] == "revisions" && method == "GET":
+		self.listRevisions(w, r, parts[1])
+	case len(parts) == 4 && parts[0] == "files" && parts[2] == "revisions" && method == "GET":
+		self.getRevision(w, r, parts[1], parts[3])
+	case len(parts) == 4 && parts[0] == "files" && parts[2] == "revisions" && method == "DELETE":
+		self.deleteRevision(w, r, parts[1], parts[3])
+	case len(parts) == 3 && parts[0] == "files" && parts[2] == "permissions" && method == "GET":
+		self.listPermissions(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "files" && parts[2] == "permissions" && method == "POST":
+		self.createPermission(w, r, parts[1])
+	case len(parts) == 4 && parts[0] == "files" && parts[2] == "permissions" && method == "DELETE":
+		self.deletePermission(w, r, parts[1], parts[3])
+	case len(parts) == 1 && parts[0] == "changes" && method == "GET":
+		self.listChanges(w, r)
+	case len(parts) == 2 && parts[0] == "changes" && parts[1] == "startPageToken" && method == "GET":
+		self.getStartPageToken(w, r)
+	case len(parts) == 1 && parts[0] == "about" && method == "GET":
+		self.getAbout(w, r)
+	case len(parts) == 1 && parts[0] == "drives" && method == "GET":
+		self.listDrives(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "notFound", fmt.Sprintf("No handler for %s %s", r.Method, r.URL.Path))
+	}
+}
+
+func (self *Server) matchFault(r *http.Request) *Fault {
+	for i, fault := range self.faults {
+		if fault.Method != "" && fault.Method != r.Method {
+			continue
+		}
+
+		if !strings.HasPrefix(r.URL.Path, fault.Path) {
+			continue
+		}
+
+		fault.Times--
+		if fault.Times == 0 {
+			self.faults = append(self.faults[:i], self.faults[i+1:]...)
+		}
+
+		if fault.Reason == "" {
+			fault.Reason = defaultReason(fault.Status)
+		}
+		return fault
+	}
+
+	return nil
+}
+
+func defaultReason(status int) string {
+	if status == http.StatusForbidden {
+		return "userRateLimitExceeded"
+	}
+	if status >= 500 {
+		return "backendError"
+	}
+	return "invalid"
+}
+
+func (self *Server) nextId(prefix string) string {
+	self.seq++
+	return fmt.Sprintf("%s%06d", prefix, self.seq)
+}
+
+// Timestamps have millisecond precision, like on drive
+func (self *Server) now() string {
+	return time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+// Writes an error in the format returned by google apis
+func writeError(w http.ResponseWriter, status int, reason, message string) {
+	body := map[string]interface{}{
+		"error": map[string]interface{}{
+			"errors": []map[string]string{
+				{"domain": "global", "reason": reason, "message": message},
+			},
+			"code":    status,
+			"message": message,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeJson(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func notFound(w http.ResponseWriter, id string) {
+	writeError(w, http.StatusNotFound, "notFound", fmt.Sprintf("File not found: %s.", id))
+}
@@ -0,0 +1,238 @@
+package drivetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Resumable upload session
+type upload struct {
+	fileId      string
+	raw         map[string]json.RawMessage
+	contentType string
+	data        []byte
+	size        int64
+
+	// Id of the file once the upload has completed
+	completedId string
+}
+
+// Handles multipart uploads and starts resumable uploads. A new file
+// is created if fileId is empty, otherwise the file is updated
+func (self *Server) handleUpload(w http.ResponseWriter, r *http.Request, fileId string) {
+	switch r.URL.Query().Get("uploadType") {
+	case "multipart":
+		raw, content, contentType, err := readMultipart(r)
+		if err != nil {
+			err.write(w)
+			return
+		}
+		self.finishUpload(w, r, fileId, raw, content, contentType)
+	case "resumable":
+		self.startUpload(w, r, fileId)
+	case "media":
+		content, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			badRequest("Failed to read body: %s", err).write(w)
+			return
+		}
+		self.finishUpload(w, r, fileId, map[string]json.RawMessage{}, content, r.Header.Get("Content-Type"))
+	default:
+		badRequest("Unsupported upload type '%s'", r.URL.Query().Get("uploadType")).write(w)
+	}
+}
+
+func (self *Server) finishUpload(w http.ResponseWriter, r *http.Request, fileId string, raw map[string]json.RawMessage, content []byte, contentType string) {
+	f, err := self.completeUpload(r, fileId, raw, content, contentType)
+	if err != nil {
+		err.write(w)
+		return
+	}
+	writeJson(w, http.StatusOK, self.resource(f))
+}
+
+func (self *Server) completeUpload(r *http.Request, fileId string, raw map[string]json.RawMessage, content []byte, contentType string) (*file, *apiError) {
+	if fileId == "" {
+		return self.insert(raw, content, contentType)
+	}
+
+	f := self.files[fileId]
+	if f == nil {
+		return nil, fileNotFound(fileId)
+	}
+
+	if err := self.update(f, raw, r, content); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Reads a multipart/related body with json metadata followed by the content
+func readMultipart(r *http.Request) (map[string]json.RawMessage, []byte, string, *apiError) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, nil, "", badRequest("Expected a multipart body")
+	}
+
+	reader := multipart.NewReader(r.Body, params["boundary"])
+
+	metaPart, err := reader.NextPart()
+	if err != nil {
+		return nil, nil, "", badRequest("Missing metadata part: %s", err)
+	}
+
+	raw := map[string]json.RawMessage{}
+	if err := json.NewDecoder(metaPart).Decode(&raw); err != nil {
+		return nil, nil, "", badRequest("Invalid metadata: %s", err)
+	}
+
+	mediaPart, err := reader.NextPart()
+	if err != nil {
+		return nil, nil, "", badRequest("Missing media part: %s", err)
+	}
+
+	content, err := ioutil.ReadAll(mediaPart)
+	if err != nil {
+		return nil, nil, "", badRequest("Failed to read media: %s", err)
+	}
+
+	return raw, content, mediaPart.Header.Get("Content-Type"), nil
+}
+
+func (self *Server) startUpload(w http.ResponseWriter, r *http.Request, fileId string) {
+	raw, err := decodeMetadata(r)
+	if err != nil {
+		err.write(w)
+		return
+	}
+
+	session := &upload{
+		fileId:      fileId,
+		raw:         raw,
+		contentType: r.Header.Get("X-Upload-Content-Type"),
+		size:        -1,
+	}
+
+	if length := r.Header.Get("X-Upload-Content-Length"); length != "" {
+		session.size, _ = strconv.ParseInt(length, 10, 64)
+	}
+
+	id := self.nextId("upload")
+	self.uploads[id] = session
+
+	params := r.URL.Query()
+	params.Set("upload_id", id)
+	w.Header().Set("Location", fmt.Sprintf("%s%s?%s", self.URL, r.URL.Path, params.Encode()))
+	w.WriteHeader(http.StatusOK)
+}
+
+// Handles a chunk of a resumable upload, or a status query if the body is empty
+func (self *Server) handleUploadChunk(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("upload_id")
+	session, ok := self.uploads[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "notFound", "Upload session not found")
+		return
+	}
+
+	if r.Method != "PUT" && r.Method != "POST" {
+		badRequest("Unsupported method %s for upload session", r.Method).write(w)
+		return
+	}
+
+	// Completed sessions keep returning the file, like on drive
+	if session.completedId != "" {
+		f := self.files[session.completedId]
+		if f == nil {
+			notFound(w, session.completedId)
+			return
+		}
+		writeJson(w, http.StatusOK, self.resource(f))
+		return
+	}
+
+	start, total, err := parseContentRange(r.Header.Get("Content-Range"))
+	if err != nil {
+		err.write(w)
+		return
+	}
+
+	body, readErr := ioutil.ReadAll(r.Body)
+	if readErr != nil {
+		badRequest("Failed to read body: %s", readErr).write(w)
+		return
+	}
+
+	if self.chunkLimit >= 0 && int64(len(body)) > self.chunkLimit {
+		body = body[:self.chunkLimit]
+	}
+
+	if len(body) > 0 {
+		if start > int64(len(session.data)) {
+			badRequest("Chunk starts at %d, but only %d bytes has been received", start, len(session.data)).write(w)
+			return
+		}
+		session.data = append(session.data[:start], body...)
+	}
+
+	if total >= 0 {
+		session.size = total
+	}
+
+	if session.size < 0 || int64(len(session.data)) < session.size {
+		if len(session.data) > 0 {
+			w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(session.data)-1))
+		}
+		w.WriteHeader(http.StatusPermanentRedirect)
+		return
+	}
+
+	f, apiErr := self.completeUpload(r, session.fileId, session.raw, session.data, session.contentType)
+	if apiErr != nil {
+		apiErr.write(w)
+		return
+	}
+
+	session.completedId = f.meta.Id
+	writeJson(w, http.StatusOK, self.resource(f))
+}
+
+// Parses 'bytes a-b/total', 'bytes a-b/*' and 'bytes */total',
+// the total is -1 if unknown
+func parseContentRange(header string) (int64, int64, *apiError) {
+	if !strings.HasPrefix(header, "bytes ") {
+		return 0, 0, badRequest("Invalid Content-Range '%s'", header)
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(header, "bytes "), "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, badRequest("Invalid Content-Range '%s'", header)
+	}
+
+	total := int64(-1)
+	if parts[1] != "*" {
+		n, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return 0, 0, badRequest("Invalid Content-Range '%s'", header)
+		}
+		total = n
+	}
+
+	if parts[0] == "*" {
+		return 0, total, nil
+	}
+
+	bounds := strings.SplitN(parts[0], "-", 2)
+	start, err := strconv.ParseInt(bounds[0], 10, 64)
+	if err != nil {
+		return 0, 0, badRequest("Invalid Content-Range '%s'", header)
+	}
+
+	return start, total, nil
+}
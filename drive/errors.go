@@ -3,6 +3,7 @@ package drive
 import (
 	"golang.org/x/net/context"
 	"google.golang.org/api/googleapi"
+	"sync"
 	"time"
 )
 
@@ -34,7 +35,35 @@ func isTimeoutError(err error) bool {
 	return err == context.Canceled
 }
 
+// Backoff is shared by all goroutines, so that a rate limit error seen by
+// one transfer also pauses the others
+var backoff struct {
+	sync.Mutex
+	until time.Time
+}
+
+// Length of the first backoff, doubled for every retry
+var backoffUnit = time.Second
+
 func exponentialBackoffSleep(try int) {
-	seconds := pow(2, try)
-	time.Sleep(time.Duration(seconds) * time.Second)
+	factor := pow(2, try)
+
+	backoff.Lock()
+	until := time.Now().Add(time.Duration(factor) * backoffUnit)
+	if until.After(backoff.until) {
+		backoff.until = until
+	}
+	backoff.Unlock()
+
+	waitForBackoff()
+}
+
+func waitForBackoff() {
+	backoff.Lock()
+	wait := backoff.until.Sub(time.Now())
+	backoff.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
 }
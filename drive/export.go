@@ -2,9 +2,13 @@ package drive
 
 import (
 	"fmt"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
 	"io"
 	"mime"
-	"os"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
 var DefaultExportMime = map[string]string{
@@ -14,67 +18,166 @@ var DefaultExportMime = map[string]string{
 	"application/vnd.google-apps.spreadsheet":  "text/csv",
 	"application/vnd.google-apps.script":       "application/vnd.google-apps.script+json",
 	"application/vnd.google-apps.presentation": "application/pdf",
+	"application/vnd.google-apps.jam":          "application/pdf",
 }
 
+// Extensions of the export formats, the system mime database
+// does not know all of them
+var exportExtensions = map[string]string{
+	"application/pdf":                                                           ".pdf",
+	"application/zip":                                                           ".zip",
+	"application/rtf":                                                           ".rtf",
+	"application/epub+zip":                                                      ".epub",
+	"application/vnd.google-apps.script+json":                                   ".json",
+	"application/vnd.oasis.opendocument.text":                                   ".odt",
+	"application/vnd.oasis.opendocument.spreadsheet":                            ".ods",
+	"application/x-vnd.oasis.opendocument.spreadsheet":                          ".ods",
+	"application/vnd.oasis.opendocument.presentation":                           ".odp",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   ".docx",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         ".xlsx",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": ".pptx",
+	"image/jpeg":                ".jpg",
+	"image/png":                 ".png",
+	"image/svg+xml":             ".svg",
+	"text/csv":                  ".csv",
+	"text/html":                 ".html",
+	"text/markdown":             ".md",
+	"text/plain":                ".txt",
+	"text/tab-separated-values": ".tsv",
+}
+
+// Prefix of google document mime types, i.e. application/vnd.google-apps.document
+const googleMimePrefix = "application/vnd.google-apps."
+
 type ExportArgs struct {
 	Out        io.Writer
+	Progress   io.Writer
 	Id         string
+	Path       string
 	PrintMimes bool
 	Mime       string
 	Force      bool
+	Skip       bool
+	Stdout     bool
+	Timeout    time.Duration
 }
 
 func (self *Drive) Export(args ExportArgs) error {
-	f, err := self.service.Files.Get(args.Id).Fields("name", "mimeType").Do()
+	google, err := self.googleOnly("export")
+	if err != nil {
+		return err
+	}
+
+	f, err := self.backend.GetFile(args.Id, "id", "name", "mimeType")
 	if err != nil {
 		return fmt.Errorf("Failed to get file: %s", err)
 	}
 
 	if args.PrintMimes {
-		return self.printMimes(args.Out, f.MimeType)
+		return self.printMimes(google, args.Out, f.MimeType)
 	}
 
-	exportMime, err := getExportMime(args.Mime, f.MimeType)
-	if err != nil {
-		return err
+	downloadArgs := DownloadArgs{
+		Out:      args.Out,
+		Progress: args.Progress,
+		Path:     args.Path,
+		Force:    args.Force,
+		Skip:     args.Skip,
+		Stdout:   args.Stdout,
+		Timeout:  args.Timeout,
+		verified: &verifyStats{},
+	}
+	if args.Mime != "" {
+		downloadArgs.ExportMimes = map[string]string{f.MimeType: args.Mime}
 	}
 
-	filename := getExportFilename(f.Name, exportMime)
+	return self.exportFile(f, downloadArgs)
+}
 
-	res, err := self.service.Files.Export(args.Id, exportMime).Download()
-	if err != nil {
-		return fmt.Errorf("Failed to download file: %s", err)
+type ExportQueryArgs struct {
+	Out      io.Writer
+	Progress io.Writer
+	Query    string
+	Path     string
+	Mime     string
+	Force    bool
+	Skip     bool
+	Timeout  time.Duration
+}
+
+// Exports every document matching the query, files that can not be
+// exported are skipped
+func (self *Drive) ExportQuery(args ExportQueryArgs) error {
+	if _, err := self.googleOnly("export"); err != nil {
+		return err
 	}
 
-	// Close body on function exit
-	defer res.Body.Close()
+	files, err := self.listAllFiles(listAllFilesArgs{
+		query:  args.Query,
+		fields: []googleapi.Field{"nextPageToken", "files(id,name,mimeType,md5Checksum)"},
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to list files: %s", err)
+	}
 
-	// Check if file exists
-	if !args.Force && fileExists(filename) {
-		return fmt.Errorf("File '%s' already exists, use --force to overwrite", filename)
+	downloadArgs := DownloadArgs{
+		Out:      args.Out,
+		Progress: args.Progress,
+		Path:     args.Path,
+		Force:    args.Force,
+		Skip:     args.Skip,
+		Timeout:  args.Timeout,
+		verified: &verifyStats{},
 	}
 
-	// Create new file
-	outFile, err := os.Create(filename)
-	if err != nil {
-		return fmt.Errorf("Unable to create new file '%s': %s", filename, err)
+	for _, f := range files {
+		if isDir(f) || isBinary(f) {
+			continue
+		}
+
+		fileArgs := downloadArgs
+		if args.Mime != "" {
+			fileArgs.ExportMimes = map[string]string{f.MimeType: args.Mime}
+		}
+
+		if _, err := fileArgs.exportMime(f); err != nil {
+			self.message(args.Out, "Skipping %s: %s", f.Name, err)
+			continue
+		}
+
+		if err := self.exportFile(f, fileArgs); err != nil {
+			return err
+		}
 	}
 
-	// Close file on function exit
-	defer outFile.Close()
+	return nil
+}
 
-	// Save file to disk
-	_, err = io.Copy(outFile, res.Body)
+func (self *Drive) exportFile(f *drive.File, args DownloadArgs) error {
+	exportMime, err := args.exportMime(f)
 	if err != nil {
-		return fmt.Errorf("Failed saving file: %s", err)
+		return err
+	}
+
+	bytes, rate, err := self.downloadExport(f, args)
+	if err == errFileSkipped {
+		return nil
+	}
+	if err != nil || args.Stdout {
+		return err
 	}
 
-	fmt.Fprintf(args.Out, "Exported '%s' with mime type: '%s'\n", filename, exportMime)
+	self.event(args.Out, Event{
+		Action:  "exported",
+		Id:      f.Id,
+		Size:    bytes,
+		Message: fmt.Sprintf("Exported %s with mime type: '%s' at %s/s, total %s", f.Id, exportMime, formatSize(rate, false), formatSize(bytes, false)),
+	})
 	return nil
 }
 
-func (self *Drive) printMimes(out io.Writer, mimeType string) error {
-	about, err := self.service.About.Get().Fields("exportFormats").Do()
+func (self *Drive) printMimes(google *googleBackend, out io.Writer, mimeType string) error {
+	about, err := google.service.About.Get().Fields("exportFormats").Do()
 	if err != nil {
 		return fmt.Errorf("Failed to get about: %s", err)
 	}
@@ -84,7 +187,7 @@ func (self *Drive) printMimes(out io.Writer, mimeType string) error {
 		return fmt.Errorf("File with type '%s' cannot be exported", mimeType)
 	}
 
-	fmt.Fprintf(out, "Available mime types: %s\n", formatList(mimes))
+	self.message(out, "Available mime types: %s", formatList(mimes))
 	return nil
 }
 
@@ -102,6 +205,10 @@ func getExportMime(userMime, fileMime string) (string, error) {
 }
 
 func getExportFilename(name, mimeType string) string {
+	if ext, ok := exportExtensions[mimeType]; ok {
+		return name + ext
+	}
+
 	extensions, err := mime.ExtensionsByType(mimeType)
 	if err != nil || len(extensions) == 0 {
 		return name
@@ -109,3 +216,73 @@ func getExportFilename(name, mimeType string) string {
 
 	return name + extensions[0]
 }
+
+// Returns the mime type the document is exported as, mimes given by
+// the user takes precedence over the defaults
+func (self DownloadArgs) exportMime(f *drive.File) (string, error) {
+	return lookupExportMime(self.ExportMimes, f)
+}
+
+// Export mimes can be keyed by the full document mime type or by
+// the short name of the document type, i.e. document or spreadsheet
+func lookupExportMime(exportMimes map[string]string, f *drive.File) (string, error) {
+	userMime, ok := exportMimes[f.MimeType]
+	if !ok {
+		userMime = exportMimes[strings.TrimPrefix(f.MimeType, googleMimePrefix)]
+	}
+	return getExportMime(userMime, f.MimeType)
+}
+
+// Exports the google document into args.Path, the exported file is named
+// after the document with the extension of the export format
+func (self *Drive) downloadExport(f *drive.File, args DownloadArgs) (int64, int64, error) {
+	google, err := self.googleOnly("export")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	exportMime, err := args.exportMime(f)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	fpath := filepath.Join(args.Path, getExportFilename(f.Name, exportMime))
+
+	// Get timeout reader wrapper and context
+	timeoutReaderWrapper, ctx := getTimeoutReaderWrapperContext(args.Timeout)
+
+	res, err := google.service.Files.Export(f.Id, exportMime).Context(ctx).Download(google.fileOptions()...)
+	if err != nil {
+		if isTimeoutError(err) {
+			return 0, 0, fmt.Errorf("Failed to export file: timeout, no data was transferred for %v", args.Timeout)
+		}
+		return 0, 0, fmt.Errorf("Failed to export file: %s", err)
+	}
+
+	// Close body on function exit
+	defer res.Body.Close()
+
+	if !args.Stdout {
+		self.event(args.Out, Event{
+			Action:  "export",
+			Path:    f.Name,
+			Target:  fpath,
+			Id:      f.Id,
+			Message: fmt.Sprintf("Exporting %s as %s -> %s", f.Name, exportMime, fpath),
+		})
+	}
+
+	// Exports have no checksum and can not be resumed
+	return self.saveFile(saveFileArgs{
+		out:           args.Out,
+		body:          timeoutReaderWrapper(res.Body),
+		contentLength: res.ContentLength,
+		fpath:         fpath,
+		force:         args.Force,
+		skip:          args.Skip,
+		stdout:        args.Stdout,
+		progress:      args.Progress,
+		verify:        args.Verify,
+		verified:      args.verified,
+	})
+}
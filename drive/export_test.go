@@ -0,0 +1,77 @@
+package drive
+
+import (
+	"bytes"
+	"github.com/prasmussen/gdrive/drive/drivetest"
+	"google.golang.org/api/drive/v3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	id := server.Insert(&drive.File{Name: "report", Parents: []string{drivetest.RootId}, MimeType: "application/vnd.google-apps.document"}, []byte("report"))
+	dir := t.TempDir()
+
+	if err := gdrive.Export(ExportArgs{Out: ioutil.Discard, Id: id, Path: dir}); err != nil {
+		t.Fatal(err)
+	}
+
+	fpath := filepath.Join(dir, "report.pdf")
+	assertLocalFile(t, fpath, []byte("report"))
+
+	if _, err := os.Stat(fpath + ".incomplete"); err == nil {
+		t.Fatal("Expected the incomplete file to be renamed")
+	}
+
+	if err := gdrive.Export(ExportArgs{Out: ioutil.Discard, Id: id, Path: dir}); err == nil {
+		t.Fatal("Expected existing files to require --force")
+	}
+
+	// Skipped files are not reported as exported
+	out := &bytes.Buffer{}
+	if err := gdrive.Export(ExportArgs{Out: out, Id: id, Path: dir, Skip: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out.String(), "already exists, skipping") || strings.Contains(out.String(), "Exported") {
+		t.Fatalf("Expected only a skip message, got %q", out.String())
+	}
+
+	out = &bytes.Buffer{}
+	if err := gdrive.Export(ExportArgs{Out: out, Id: id, Mime: "text/plain", Stdout: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != "report" {
+		t.Fatalf("Unexpected content written to stdout: %q", out.String())
+	}
+}
+
+func TestExportQuery(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	spreadsheet := "application/vnd.google-apps.spreadsheet"
+	server.Insert(&drive.File{Name: "a", Parents: []string{drivetest.RootId}, MimeType: spreadsheet}, []byte("a"))
+	server.Insert(&drive.File{Name: "b", Parents: []string{drivetest.RootId}, MimeType: spreadsheet}, []byte("b"))
+	server.AddFile(drivetest.RootId, "c.txt", []byte("c"))
+	dir := t.TempDir()
+
+	err := gdrive.ExportQuery(ExportQueryArgs{
+		Out:   ioutil.Discard,
+		Query: "trashed = false",
+		Path:  dir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertLocalFile(t, filepath.Join(dir, "a.csv"), []byte("a"))
+	assertLocalFile(t, filepath.Join(dir, "b.csv"), []byte("b"))
+
+	if _, err := os.Stat(filepath.Join(dir, "c.txt")); err == nil {
+		t.Fatal("Expected binary files to be left out of the export")
+	}
+}
@@ -26,7 +26,12 @@ func (self *Drive) Import(args ImportArgs) error {
 		return fmt.Errorf("Could not determine mime type of file, use --mime")
 	}
 
-	about, err := self.service.About.Get().Fields("importFormats").Do()
+	google, err := self.googleOnly("import")
+	if err != nil {
+		return err
+	}
+
+	about, err := google.service.About.Get().Fields("importFormats").Do()
 	if err != nil {
 		return fmt.Errorf("Failed to get about: %s", err)
 	}
@@ -40,14 +45,19 @@ func (self *Drive) Import(args ImportArgs) error {
 		Out:      ioutil.Discard,
 		Progress: args.Progress,
 		Path:     args.Path,
-		Parents:  args.Parents,
+		Parents:  self.parentsOrRoot(args.Parents),
 		Mime:     toMimes[0],
 	})
 	if err != nil {
 		return err
 	}
 
-	fmt.Fprintf(args.Out, "Imported %s with mime type: '%s'\n", f.Id, toMimes[0])
+	self.event(args.Out, Event{
+		Action:  "import",
+		Path:    args.Path,
+		Id:      f.Id,
+		Message: fmt.Sprintf("Imported %s with mime type: '%s'", f.Id, toMimes[0]),
+	})
 	return nil
 }
 
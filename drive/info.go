@@ -13,7 +13,7 @@ type FileInfoArgs struct {
 }
 
 func (self *Drive) Info(args FileInfoArgs) error {
-	f, err := self.service.Files.Get(args.Id).Fields("id", "name", "size", "createdTime", "modifiedTime", "md5Checksum", "mimeType", "parents", "shared", "description", "webContentLink", "webViewLink").Do()
+	f, err := self.backend.GetFile(args.Id, "id", "name", "size", "createdTime", "modifiedTime", "md5Checksum", "mimeType", "parents", "shared", "description", "webContentLink", "webViewLink", "properties", "appProperties")
 	if err != nil {
 		return fmt.Errorf("Failed to get file: %s", err)
 	}
@@ -24,14 +24,21 @@ func (self *Drive) Info(args FileInfoArgs) error {
 		return err
 	}
 
-	PrintFileInfo(PrintFileInfoArgs{
-		Out:         args.Out,
-		File:        f,
-		Path:        absPath,
-		SizeInBytes: args.SizeInBytes,
-	})
+	record := newFileRecord(f, absPath)
 
-	return nil
+	return self.render(args.Out, Result{
+		Value:  record,
+		Header: fileRecordHeader,
+		Rows:   [][]string{record.row()},
+		Table: func(w io.Writer) {
+			PrintFileInfo(PrintFileInfoArgs{
+				Out:         w,
+				File:        f,
+				Path:        absPath,
+				SizeInBytes: args.SizeInBytes,
+			})
+		},
+	})
 }
 
 type PrintFileInfoArgs struct {
@@ -58,6 +65,8 @@ func PrintFileInfo(args PrintFileInfoArgs) {
 		kv{"Parents", formatList(f.Parents)},
 		kv{"ViewUrl", f.WebViewLink},
 		kv{"DownloadUrl", f.WebContentLink},
+		kv{"Properties", formatProperties(f.Properties)},
+		kv{"AppProperties", formatProperties(f.AppProperties)},
 	}
 
 	for _, item := range items {
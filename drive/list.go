@@ -2,10 +2,11 @@ package drive
 
 import (
 	"fmt"
-	"golang.org/x/net/context"
 	"google.golang.org/api/drive/v3"
 	"google.golang.org/api/googleapi"
 	"io"
+	"strconv"
+	"strings"
 	"text/tabwriter"
 )
 
@@ -32,6 +33,10 @@ func (self *Drive) List(args ListFilesArgs) (err error) {
 		return fmt.Errorf("Failed to list files: %s", err)
 	}
 
+	return self.printFiles(args, files)
+}
+
+func (self *Drive) printFiles(args ListFilesArgs, files []*drive.File) (err error) {
 	pathfinder := self.newPathfinder()
 
 	if args.AbsPath {
@@ -44,15 +49,97 @@ func (self *Drive) List(args ListFilesArgs) (err error) {
 		}
 	}
 
-	PrintFileList(PrintFileListArgs{
-		Out:         args.Out,
-		Files:       files,
-		NameWidth:   int(args.NameWidth),
-		SkipHeader:  args.SkipHeader,
-		SizeInBytes: args.SizeInBytes,
+	records := make([]FileRecord, 0, len(files))
+	for _, f := range files {
+		records = append(records, newFileRecord(f, ""))
+	}
+
+	return self.render(args.Out, Result{
+		Value:      records,
+		Header:     fileRecordHeader,
+		Rows:       fileRecordRows(records),
+		SkipHeader: args.SkipHeader,
+		Table: func(w io.Writer) {
+			PrintFileList(PrintFileListArgs{
+				Out:         w,
+				Files:       files,
+				NameWidth:   int(args.NameWidth),
+				SkipHeader:  args.SkipHeader,
+				SizeInBytes: args.SizeInBytes,
+			})
+		},
 	})
+}
+
+type FileRecord struct {
+	Id            string            `json:"id"`
+	Name          string            `json:"name"`
+	Path          string            `json:"path,omitempty"`
+	Type          string            `json:"type"`
+	Mime          string            `json:"mimeType"`
+	Size          int64             `json:"size"`
+	Md5           string            `json:"md5Checksum,omitempty"`
+	Created       string            `json:"createdTime,omitempty"`
+	Modified      string            `json:"modifiedTime,omitempty"`
+	Description   string            `json:"description,omitempty"`
+	Shared        bool              `json:"shared"`
+	Parents       []string          `json:"parents,omitempty"`
+	ViewUrl       string            `json:"viewUrl,omitempty"`
+	DownloadUrl   string            `json:"downloadUrl,omitempty"`
+	Properties    map[string]string `json:"properties,omitempty"`
+	AppProperties map[string]string `json:"appProperties,omitempty"`
+}
+
+var fileRecordHeader = []string{"Id", "Name", "Path", "Type", "Mime", "Size", "Md5sum", "Created", "Modified", "Description", "Shared", "Parents", "ViewUrl", "DownloadUrl", "Properties", "AppProperties"}
+
+func newFileRecord(f *drive.File, path string) FileRecord {
+	return FileRecord{
+		Id:            f.Id,
+		Name:          f.Name,
+		Path:          path,
+		Type:          filetype(f),
+		Mime:          f.MimeType,
+		Size:          f.Size,
+		Md5:           f.Md5Checksum,
+		Created:       f.CreatedTime,
+		Modified:      f.ModifiedTime,
+		Description:   f.Description,
+		Shared:        f.Shared,
+		Parents:       f.Parents,
+		ViewUrl:       f.WebViewLink,
+		DownloadUrl:   f.WebContentLink,
+		Properties:    f.Properties,
+		AppProperties: f.AppProperties,
+	}
+}
+
+func (self FileRecord) row() []string {
+	return []string{
+		self.Id,
+		self.Name,
+		self.Path,
+		self.Type,
+		self.Mime,
+		strconv.FormatInt(self.Size, 10),
+		self.Md5,
+		self.Created,
+		self.Modified,
+		self.Description,
+		strconv.FormatBool(self.Shared),
+		strings.Join(self.Parents, " "),
+		self.ViewUrl,
+		self.DownloadUrl,
+		formatProperties(self.Properties),
+		formatProperties(self.AppProperties),
+	}
+}
 
-	return
+func fileRecordRows(records []FileRecord) [][]string {
+	var rows [][]string
+	for _, r := range records {
+		rows = append(rows, r.row())
+	}
+	return rows
 }
 
 type listAllFilesArgs struct {
@@ -60,6 +147,7 @@ type listAllFilesArgs struct {
 	fields    []googleapi.Field
 	sortOrder string
 	maxFiles  int64
+	driveId   string
 }
 
 func (self *Drive) listAllFiles(args listAllFilesArgs) ([]*drive.File, error) {
@@ -72,20 +160,25 @@ func (self *Drive) listAllFiles(args listAllFilesArgs) ([]*drive.File, error) {
 		pageSize = 1000
 	}
 
-	controlledStop := fmt.Errorf("Controlled stop")
-
-	err := self.service.Files.List().Q(args.query).Fields(args.fields...).OrderBy(args.sortOrder).PageSize(pageSize).Pages(context.TODO(), func(fl *drive.FileList) error {
+	query := FileQuery{
+		Query:     args.query,
+		Fields:    args.fields,
+		SortOrder: args.sortOrder,
+		PageSize:  pageSize,
+		DriveId:   args.driveId,
+	}
+	err := self.backend.ListFiles(query, func(fl *drive.FileList) error {
 		files = append(files, fl.Files...)
 
 		// Stop when we have all the files we need
 		if args.maxFiles > 0 && len(files) >= int(args.maxFiles) {
-			return controlledStop
+			return errStopListing
 		}
 
 		return nil
 	})
 
-	if err != nil && err != controlledStop {
+	if err != nil && err != errStopListing {
 		return nil, err
 	}
 
@@ -0,0 +1,135 @@
+package drive
+
+import (
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"io"
+	"time"
+)
+
+type RenameArgs struct {
+	Out  io.Writer
+	Id   string
+	Name string
+}
+
+func (self *Drive) Rename(args RenameArgs) error {
+	if args.Name == "" {
+		return fmt.Errorf("The new name can not be empty")
+	}
+
+	f, err := self.updateMetadata(args.Id, &drive.File{Name: args.Name})
+	if err != nil {
+		return err
+	}
+
+	self.event(args.Out, Event{Action: "rename", Path: f.Name, Id: f.Id, Message: fmt.Sprintf("Renamed %s to '%s'", f.Id, f.Name)})
+	return nil
+}
+
+type MetaSetArgs struct {
+	Out           io.Writer
+	Id            string
+	Name          string
+	Description   string
+	Starred       *bool
+	Mime          string
+	Properties    map[string]string
+	AppProperties map[string]string
+	ModifiedTime  string
+}
+
+func (self *Drive) MetaSet(args MetaSetArgs) error {
+	dstFile := &drive.File{
+		Name:          args.Name,
+		Description:   args.Description,
+		MimeType:      args.Mime,
+		Properties:    args.Properties,
+		AppProperties: args.AppProperties,
+	}
+
+	for key := range args.AppProperties {
+		if err := checkPropertyKey(key, true); err != nil {
+			return err
+		}
+	}
+
+	if args.Starred != nil {
+		// Starred is false by default and has to be sent explicitly
+		dstFile.Starred = *args.Starred
+		dstFile.ForceSendFields = []string{"Starred"}
+	}
+
+	if args.ModifiedTime != "" {
+		modified, err := time.Parse(time.RFC3339, args.ModifiedTime)
+		if err != nil {
+			return fmt.Errorf("Invalid modified time '%s', expected a RFC 3339 timestamp like 2016-01-02T15:04:05Z", args.ModifiedTime)
+		}
+		dstFile.ModifiedTime = modified.UTC().Format(time.RFC3339Nano)
+	}
+
+	if args.Name == "" && args.Description == "" && args.Starred == nil && args.Mime == "" &&
+		len(args.Properties) == 0 && len(args.AppProperties) == 0 && args.ModifiedTime == "" {
+		return fmt.Errorf("Nothing to update, specify at least one field to set")
+	}
+
+	if args.Mime != "" {
+		f, err := self.backend.GetFile(args.Id, "id", "name", "mimeType")
+		if err != nil {
+			return fmt.Errorf("Failed to get file: %s", err)
+		}
+
+		if isDir(f) {
+			return fmt.Errorf("'%s' is a directory, the mime type of directories can not be changed", f.Name)
+		}
+	}
+
+	f, err := self.updateMetadata(args.Id, dstFile)
+	if err != nil {
+		return err
+	}
+
+	self.event(args.Out, Event{Action: "meta", Path: f.Name, Id: f.Id, Message: fmt.Sprintf("Updated metadata of '%s'", f.Name)})
+	return nil
+}
+
+// Updates the metadata of a file without touching its content
+func (self *Drive) updateMetadata(id string, dstFile *drive.File) (*drive.File, error) {
+	if dstFile.Name != "" {
+		if err := self.checkSyncRename(id, dstFile.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := self.backend.UpdateFile(id, dstFile, FileUpdate{Fields: []googleapi.Field{"id", "name"}})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to update file: %s", err)
+	}
+	return f, nil
+}
+
+// Files in sync directories can not be renamed to a name that is already taken
+func (self *Drive) checkSyncRename(id, name string) error {
+	f, err := self.backend.GetFile(id, "id", "name", "parents", "appProperties")
+	if err != nil {
+		return fmt.Errorf("Failed to get file: %s", err)
+	}
+
+	if _, ok := f.AppProperties["syncRootId"]; !ok || f.Name == name {
+		return nil
+	}
+
+	for _, parentId := range f.Parents {
+		parent, err := self.backend.GetFile(parentId, "id", "name")
+		if err != nil {
+			return fmt.Errorf("Failed to get parent directory: %s", err)
+		}
+
+		if err := self.checkNameAvailable(parent, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
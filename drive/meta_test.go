@@ -0,0 +1,88 @@
+package drive
+
+import (
+	"github.com/prasmussen/gdrive/drive/drivetest"
+	"io/ioutil"
+	"testing"
+)
+
+func TestMetaSet(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	fileId := server.AddFile(drivetest.RootId, "a.txt", []byte("a"))
+
+	starred := true
+	err := gdrive.MetaSet(MetaSetArgs{
+		Out:          ioutil.Discard,
+		Id:           fileId,
+		Name:         "b.txt",
+		Description:  "desc",
+		Starred:      &starred,
+		Mime:         "text/csv",
+		Properties:   map[string]string{"project": "x"},
+		ModifiedTime: "2016-01-02T15:04:05Z",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := server.File(fileId)
+	if f.Name != "b.txt" || f.Description != "desc" || !f.Starred || f.MimeType != "text/csv" || f.Properties["project"] != "x" {
+		t.Fatalf("Unexpected metadata: %+v", f)
+	}
+
+	if f.ModifiedTime != "2016-01-02T15:04:05Z" {
+		t.Fatalf("Unexpected modified time: %s", f.ModifiedTime)
+	}
+
+	assertRemoteFile(t, server, drivetest.RootId, "b.txt", []byte("a"))
+
+	starred = false
+	if err := gdrive.MetaSet(MetaSetArgs{Out: ioutil.Discard, Id: fileId, Starred: &starred}); err != nil {
+		t.Fatal(err)
+	}
+
+	if server.File(fileId).Starred {
+		t.Fatal("Expected the file to be unstarred")
+	}
+
+	if err := gdrive.MetaSet(MetaSetArgs{Out: ioutil.Discard, Id: fileId, ModifiedTime: "yesterday"}); err == nil {
+		t.Fatal("Expected invalid modified times to fail")
+	}
+}
+
+func TestMetaSetRejectsMimeOfDirectories(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	dirId := server.AddFolder(drivetest.RootId, "docs")
+
+	err := gdrive.MetaSet(MetaSetArgs{Out: ioutil.Discard, Id: dirId, Mime: "text/plain"})
+	if err == nil {
+		t.Fatal("Expected changing the mime type of a directory to fail")
+	}
+
+	if f := server.File(dirId); f.MimeType != DirectoryMimeType {
+		t.Fatalf("Expected the directory to keep its mime type, got %s", f.MimeType)
+	}
+}
+
+func TestRenameInSyncDirectory(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	rootId := server.AddFolder(drivetest.RootId, "sync")
+
+	src := t.TempDir()
+	writeTestFiles(t, src, map[string]string{
+		"a.txt": "a",
+		"b.txt": "b",
+	})
+	uploadSync(t, gdrive, rootId, src, t.TempDir())
+
+	fileId := server.Find(rootId, "a.txt").Id
+	if err := gdrive.Rename(RenameArgs{Out: ioutil.Discard, Id: fileId, Name: "b.txt"}); err == nil {
+		t.Fatal("Expected name collisions in sync directories to fail")
+	}
+
+	if err := gdrive.Rename(RenameArgs{Out: ioutil.Discard, Id: fileId, Name: "c.txt"}); err != nil {
+		t.Fatal(err)
+	}
+
+	assertRemoteFile(t, server, rootId, "c.txt", []byte("a"))
+}
@@ -13,6 +13,7 @@ type MkdirArgs struct {
 	Name        string
 	Description string
 	Parents     []string
+	Properties  map[string]string
 }
 
 func (self *Drive) Mkdir(args MkdirArgs) error {
@@ -20,7 +21,7 @@ func (self *Drive) Mkdir(args MkdirArgs) error {
 	if err != nil {
 		return err
 	}
-	fmt.Fprintf(args.Out, "Directory %s created\n", f.Id)
+	self.event(args.Out, Event{Action: "mkdir", Path: f.Name, Id: f.Id, Message: fmt.Sprintf("Directory %s created", f.Id)})
 	return nil
 }
 
@@ -29,13 +30,14 @@ func (self *Drive) mkdir(args MkdirArgs) (*drive.File, error) {
 		Name:        args.Name,
 		Description: args.Description,
 		MimeType:    DirectoryMimeType,
+		Properties:  args.Properties,
 	}
 
 	// Set parent folders
-	dstFile.Parents = args.Parents
+	dstFile.Parents = self.parentsOrRoot(args.Parents)
 
 	// Create directory
-	f, err := self.service.Files.Create(dstFile).Do()
+	f, err := self.backend.CreateFile(dstFile, nil)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to create directory: %s", err)
 	}
@@ -0,0 +1,134 @@
+package drive
+
+import (
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"io"
+)
+
+type MoveArgs struct {
+	Out       io.Writer
+	Id        string
+	Parent    string
+	Recursive bool
+}
+
+func (self *Drive) Move(args MoveArgs) error {
+	src, err := self.backend.GetFile(args.Id, copyFileFields...)
+	if err != nil {
+		return fmt.Errorf("Failed to get file: %s", err)
+	}
+
+	if isDir(src) && !args.Recursive {
+		return fmt.Errorf("'%s' is a directory, use the 'recursive' flag to move directories", src.Name)
+	}
+
+	if args.Parent == "" {
+		return fmt.Errorf("Missing destination, use the 'parent' flag to choose the new directory")
+	}
+
+	parent, err := self.getDestinationDir(args.Parent)
+	if err != nil {
+		return err
+	}
+
+	props, err := self.moveSyncProperties(src, parent)
+	if err != nil {
+		return err
+	}
+
+	var removeParents []string
+	for _, id := range src.Parents {
+		if id != parent.Id {
+			removeParents = append(removeParents, id)
+		}
+	}
+
+	dstFile := &drive.File{AppProperties: props}
+	update := FileUpdate{
+		AddParents:    []string{parent.Id},
+		RemoveParents: removeParents,
+		Fields:        []googleapi.Field{"id", "name"},
+	}
+
+	f, err := self.backend.UpdateFile(src.Id, dstFile, update)
+	if err != nil {
+		return fmt.Errorf("Failed to move file: %s", err)
+	}
+
+	// The content of a directory follows it to the new sync root
+	if props != nil && isDir(src) {
+		if err := self.setSyncRootId(src.Id, props); err != nil {
+			return err
+		}
+	}
+
+	self.event(args.Out, Event{Action: "move", Path: f.Name, Id: f.Id, Message: fmt.Sprintf("Moved '%s' to '%s'", f.Name, parent.Name)})
+	return nil
+}
+
+// Returns the sync appProperties src needs in the new parent,
+// nil if they are already correct
+func (self *Drive) moveSyncProperties(src, parent *drive.File) (map[string]string, error) {
+	dstProps := inheritedSyncProperties(parent)
+
+	if _, ok := src.AppProperties["syncRoot"]; ok {
+		if dstProps != nil {
+			return nil, fmt.Errorf("Can not move sync root '%s' into another sync root", src.Name)
+		}
+		return nil, nil
+	}
+
+	srcRootId := src.AppProperties["syncRootId"]
+	if srcRootId != "" && dstProps == nil {
+		return nil, fmt.Errorf("Can not move '%s' out of its sync root", src.Name)
+	}
+
+	if dstProps == nil {
+		return nil, nil
+	}
+
+	// Moving within the same directory does not change anything
+	for _, id := range src.Parents {
+		if id == parent.Id {
+			return nil, nil
+		}
+	}
+
+	if err := self.checkNameAvailable(parent, src.Name); err != nil {
+		return nil, err
+	}
+
+	if srcRootId == dstProps["syncRootId"] {
+		return nil, nil
+	}
+
+	return dstProps, nil
+}
+
+// Sets the sync appProperties of everything below the given directory
+func (self *Drive) setSyncRootId(dirId string, props map[string]string) error {
+	files, err := self.listAllFiles(listAllFilesArgs{
+		query:  fmt.Sprintf("'%s' in parents and trashed = false", dirId),
+		fields: []googleapi.Field{"nextPageToken", "files(id,name,mimeType)"},
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to list files: %s", err)
+	}
+
+	for _, f := range files {
+		_, err := self.backend.UpdateFile(f.Id, &drive.File{AppProperties: props}, FileUpdate{Fields: []googleapi.Field{"id"}})
+		if err != nil {
+			return fmt.Errorf("Failed to update sync properties of '%s': %s", f.Name, err)
+		}
+
+		if isDir(f) {
+			if err := self.setSyncRootId(f.Id, props); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
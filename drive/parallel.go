@@ -0,0 +1,137 @@
+package drive
+
+import (
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// Runs fn for every index in [0, count) using the given number of workers.
+// No new jobs are started after the first error, which is returned
+// when all running jobs have finished
+func runParallel(workers, count int, fn func(i int) error) error {
+	if workers <= 1 {
+		for i := 0; i < count; i++ {
+			if err := fn(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if workers > count {
+		workers = count
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return firstErr != nil
+	}
+
+	jobs := make(chan int)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				// Don't start new requests while backing off
+				waitForBackoff()
+
+				if err := fn(i); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < count && !failed(); i++ {
+		jobs <- i
+	}
+	close(jobs)
+
+	wg.Wait()
+	return firstErr
+}
+
+// Returns output and progress writers that can be shared between workers.
+// Output is serialized and the progress of all transfers is combined into
+// a single progress line. The returned function must be called when all
+// workers are done
+func prepareParallelOutput(workers int, out, progress io.Writer, size int64) (io.Writer, io.Writer, func()) {
+	if workers <= 1 {
+		return out, progress, func() {}
+	}
+
+	out = &syncWriter{writer: out}
+
+	if progress == nil || progress == ioutil.Discard {
+		return out, progress, func() {}
+	}
+
+	shared := &sharedProgress{
+		progress: &Progress{
+			Writer: &syncWriter{writer: progress},
+			Size:   size,
+		},
+	}
+
+	return out, shared, shared.finish
+}
+
+type syncWriter struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+func (self *syncWriter) Write(p []byte) (int, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.writer.Write(p)
+}
+
+// sharedProgress aggregates the progress of concurrent transfers
+type sharedProgress struct {
+	mu       sync.Mutex
+	progress *Progress
+}
+
+func (self *sharedProgress) Write(p []byte) (int, error) {
+	return self.progress.Writer.Write(p)
+}
+
+func (self *sharedProgress) reader(r io.Reader) io.Reader {
+	return &sharedProgressReader{reader: r, shared: self}
+}
+
+func (self *sharedProgress) add(n int64) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.progress.update(n, false)
+}
+
+func (self *sharedProgress) finish() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.progress.update(0, true)
+}
+
+type sharedProgressReader struct {
+	reader io.Reader
+	shared *sharedProgress
+}
+
+func (self *sharedProgressReader) Read(p []byte) (int, error) {
+	n, err := self.reader.Read(p)
+	self.shared.add(int64(n))
+	return n, err
+}
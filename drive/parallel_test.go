@@ -0,0 +1,189 @@
+package drive
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/prasmussen/gdrive/drive/drivetest"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Shortens the backoff between retries for the duration of the test
+func shortBackoff(t *testing.T) {
+	unit := backoffUnit
+	backoffUnit = time.Millisecond
+	t.Cleanup(func() {
+		backoffUnit = unit
+		backoff.Lock()
+		backoff.until = time.Time{}
+		backoff.Unlock()
+	})
+}
+
+func TestRunParallelRunsAllJobs(t *testing.T) {
+	var mu sync.Mutex
+	seen := map[int]int{}
+
+	err := runParallel(4, 100, func(i int) error {
+		mu.Lock()
+		seen[i]++
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100; i++ {
+		if seen[i] != 1 {
+			t.Fatalf("Expected job %d to run once, ran %d times", i, seen[i])
+		}
+	}
+}
+
+func TestRunParallelStopsAfterFirstError(t *testing.T) {
+	var started int32
+	failure := fmt.Errorf("job 3 failed")
+
+	err := runParallel(4, 1000, func(i int) error {
+		atomic.AddInt32(&started, 1)
+		if i == 3 {
+			return failure
+		}
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+	if err != failure {
+		t.Fatalf("Expected the first error to be returned, got %v", err)
+	}
+
+	if n := atomic.LoadInt32(&started); n >= 1000 {
+		t.Fatalf("Expected no new jobs after the first error, %d jobs were started", n)
+	}
+}
+
+func TestRunParallelWaitsForBackoff(t *testing.T) {
+	shortBackoff(t)
+
+	until := time.Now().Add(100 * time.Millisecond)
+	backoff.Lock()
+	backoff.until = until
+	backoff.Unlock()
+
+	var mu sync.Mutex
+	var early []int
+
+	err := runParallel(4, 8, func(i int) error {
+		if time.Now().Before(until) {
+			mu.Lock()
+			early = append(early, i)
+			mu.Unlock()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(early) > 0 {
+		t.Fatalf("Expected jobs to wait for the shared backoff, jobs %v started early", early)
+	}
+}
+
+func TestParallelUploadSyncRetriesRateLimitErrors(t *testing.T) {
+	shortBackoff(t)
+
+	gdrive, server := newTestDrive(t)
+	rootId := server.AddFolder(drivetest.RootId, "sync")
+	dir := t.TempDir()
+
+	files := map[string]string{}
+	for i := 0; i < 10; i++ {
+		files[fmt.Sprintf("file%d.txt", i)] = fmt.Sprintf("content %d", i)
+	}
+	writeTestFiles(t, dir, files)
+
+	server.Fail(drivetest.Fault{Method: "POST", Path: "/drive/v3/files", Status: http.StatusForbidden, Times: 3})
+
+	out := &bytes.Buffer{}
+	err := gdrive.UploadSync(UploadSyncArgs{
+		Out:      out,
+		Path:     dir,
+		RootId:   rootId,
+		Comparer: testComparer{},
+		Parallel: 4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range files {
+		assertRemoteFile(t, server, rootId, name, []byte(content))
+	}
+
+	// The failed uploads were retried
+	if n := countRequests(server, "POST /drive/v3/files"); n != 13 {
+		t.Fatalf("Expected 13 upload requests, got %d", n)
+	}
+
+	// Every line is written whole, with one counter per file
+	counter := regexp.MustCompile(`^\[(\d{4})/0010\] Uploading file\d\.txt -> sync/file\d\.txt$`)
+	seen := map[string]bool{}
+	for _, line := range strings.Split(out.String(), "\n") {
+		if !strings.HasPrefix(line, "[") {
+			continue
+		}
+		m := counter.FindStringSubmatch(line)
+		if m == nil {
+			t.Fatalf("Unexpected output line %q", line)
+		}
+		if seen[m[1]] {
+			t.Fatalf("Counter %s was printed twice", m[1])
+		}
+		seen[m[1]] = true
+	}
+	if len(seen) != 10 {
+		t.Fatalf("Expected 10 upload lines, got %d:\n%s", len(seen), out.String())
+	}
+}
+
+func TestParallelUploadSyncReturnsErrorWhenRetriesRunOut(t *testing.T) {
+	shortBackoff(t)
+
+	gdrive, server := newTestDrive(t)
+	rootId := server.AddFolder(drivetest.RootId, "sync")
+	dir := t.TempDir()
+
+	files := map[string]string{}
+	for i := 0; i < 10; i++ {
+		files[fmt.Sprintf("file%d.txt", i)] = fmt.Sprintf("content %d", i)
+	}
+	writeTestFiles(t, dir, files)
+
+	server.Fail(drivetest.Fault{Method: "POST", Path: "/drive/v3/files", Status: http.StatusForbidden, Times: 1000})
+
+	err := gdrive.UploadSync(UploadSyncArgs{
+		Out:      &bytes.Buffer{},
+		Path:     dir,
+		RootId:   rootId,
+		Comparer: testComparer{},
+		Parallel: 4,
+	})
+	if err == nil {
+		t.Fatal("Expected the upload to fail when retries run out")
+	}
+
+	if !strings.Contains(err.Error(), "403") {
+		t.Fatalf("Expected the rate limit error to be returned, got %s", err)
+	}
+
+	// No new uploads are started after the first failure
+	if n := countRequests(server, "POST /drive/v3/files"); n >= 10*(MaxErrorRetries+1) {
+		t.Fatalf("Expected uploads to stop after the first error, got %d requests", n)
+	}
+}
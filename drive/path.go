@@ -3,19 +3,160 @@ package drive
 import (
 	"fmt"
 	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"path"
 	"path/filepath"
+	"strings"
 )
 
+// Prefix used to address files by path instead of id, i.e. drive:/Projects/q3.pdf
+const PathPrefix = "drive:"
+
 func (self *Drive) newPathfinder() *remotePathfinder {
 	return &remotePathfinder{
-		service: self.service.Files,
-		files:   make(map[string]*drive.File),
+		backend:  self.backend,
+		rootId:   self.rootId(),
+		files:    make(map[string]*drive.File),
+		children: make(map[string][]*drive.File),
 	}
 }
 
 type remotePathfinder struct {
-	service *drive.FilesService
-	files   map[string]*drive.File
+	backend  Backend
+	rootId   string
+	files    map[string]*drive.File
+	children map[string][]*drive.File
+}
+
+// Returns true if s is a drive: path rather than a file id
+func IsPath(s string) bool {
+	return strings.HasPrefix(s, PathPrefix)
+}
+
+// Returns the id of the file at the given drive: path,
+// anything that is not a path is returned as is
+func (self *Drive) ResolveId(s string) (string, error) {
+	if !IsPath(s) {
+		return s, nil
+	}
+
+	f, err := self.newPathfinder().resolve(strings.TrimPrefix(s, PathPrefix))
+	if err != nil {
+		return "", err
+	}
+
+	return f.Id, nil
+}
+
+// Same as ResolveId for a list of ids and paths
+func (self *Drive) ResolveIds(ids []string) ([]string, error) {
+	pathfinder := self.newPathfinder()
+
+	var resolved []string
+	for _, s := range ids {
+		if !IsPath(s) {
+			resolved = append(resolved, s)
+			continue
+		}
+
+		f, err := pathfinder.resolve(strings.TrimPrefix(s, PathPrefix))
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, f.Id)
+	}
+
+	return resolved, nil
+}
+
+// Walks the path by name from the root directory
+func (self *remotePathfinder) resolve(p string) (*drive.File, error) {
+	p = path.Clean("/" + p)
+
+	f, err := self.getParent(self.rootId)
+	if err != nil {
+		return nil, err
+	}
+
+	if p == "/" {
+		return f, nil
+	}
+
+	names := strings.Split(strings.TrimPrefix(p, "/"), "/")
+	for i, name := range names {
+		isLast := i == len(names)-1
+
+		f, err = self.findChild(f, name, !isLast)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to resolve %s%s: %s", PathPrefix, p, err)
+		}
+	}
+
+	return f, nil
+}
+
+func (self *remotePathfinder) findChild(parent *drive.File, name string, dirOnly bool) (*drive.File, error) {
+	children, err := self.getChildren(parent.Id, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []*drive.File
+	for _, f := range children {
+		if dirOnly && !isDir(f) {
+			continue
+		}
+		matches = append(matches, f)
+	}
+
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("'%s' was not found", name)
+	}
+
+	if len(matches) > 1 {
+		var ids []string
+		for _, f := range matches {
+			ids = append(ids, f.Id)
+		}
+		return nil, fmt.Errorf("'%s' is ambiguous, %d files share that name: %s", name, len(matches), strings.Join(ids, ", "))
+	}
+
+	return matches[0], nil
+}
+
+func (self *remotePathfinder) getChildren(parentId, name string) ([]*drive.File, error) {
+	key := parentId + "/" + name
+
+	// Check cache
+	if files, ok := self.children[key]; ok {
+		return files, nil
+	}
+
+	// Fetch files from drive
+	var files []*drive.File
+	query := fmt.Sprintf("name = '%s' and '%s' in parents and trashed = false", escapeQuery(name), parentId)
+	fields := []googleapi.Field{"nextPageToken", "files(id,name,parents,mimeType)"}
+	err := self.backend.ListFiles(FileQuery{Query: query, Fields: fields}, func(fl *drive.FileList) error {
+		files = append(files, fl.Files...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list files: %s", err)
+	}
+
+	// Save in cache, the files are also useful when looking up paths by id
+	self.children[key] = files
+	for _, f := range files {
+		self.files[f.Id] = f
+	}
+
+	return files, nil
+}
+
+// Escapes a string to be used as a literal in a files query
+func escapeQuery(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return strings.Replace(s, "'", `\'`, -1)
 }
 
 func (self *remotePathfinder) absPath(f *drive.File) (string, error) {
@@ -53,7 +194,7 @@ func (self *remotePathfinder) getParent(id string) (*drive.File, error) {
 	}
 
 	// Fetch file from drive
-	f, err := self.service.Get(id).Fields("id", "name", "parents").Do()
+	f, err := self.backend.GetFile(id, "id", "name", "parents")
 	if err != nil {
 		return nil, fmt.Errorf("Failed to get file: %s", err)
 	}
@@ -0,0 +1,77 @@
+package drive
+
+import (
+	"github.com/prasmussen/gdrive/drive/drivetest"
+	"strings"
+	"testing"
+)
+
+func TestResolveIdByPath(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	dirId := server.AddFolder(drivetest.RootId, "Projects")
+	fileId := server.AddFile(dirId, "q3.pdf", []byte("q3"))
+
+	tests := map[string]string{
+		"drive:/":                drivetest.RootId,
+		"drive:/Projects":        dirId,
+		"drive:/Projects/q3.pdf": fileId,
+		"drive:Projects//q3.pdf": fileId,
+		fileId:                   fileId,
+	}
+
+	for s, expected := range tests {
+		id, err := gdrive.ResolveId(s)
+		if err != nil {
+			t.Fatalf("Failed to resolve %s: %s", s, err)
+		}
+		if id != expected {
+			t.Fatalf("Expected %s to resolve to %s, got %s", s, expected, id)
+		}
+	}
+}
+
+func TestResolveIdErrors(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	dirId := server.AddFolder(drivetest.RootId, "Projects")
+	firstId := server.AddFile(dirId, "report.pdf", []byte("a"))
+	secondId := server.AddFile(dirId, "report.pdf", []byte("b"))
+	server.AddFile(dirId, "notes.txt", []byte("notes"))
+
+	tests := []struct {
+		path     string
+		expected []string
+	}{
+		{"drive:/Projects/report.pdf", []string{"'report.pdf' is ambiguous", firstId, secondId}},
+		{"drive:/Projects/notes.txt/todo.txt", []string{"'notes.txt' was not found"}},
+		{"drive:/Projects/missing.pdf", []string{"'missing.pdf' was not found"}},
+		{"drive:/Missing/report.pdf", []string{"'Missing' was not found"}},
+	}
+
+	for _, test := range tests {
+		_, err := gdrive.ResolveId(test.path)
+		if err == nil {
+			t.Fatalf("Expected resolving %s to fail", test.path)
+		}
+
+		for _, s := range test.expected {
+			if !strings.Contains(err.Error(), s) {
+				t.Fatalf("Expected the error for %s to contain %q, got %s", test.path, s, err)
+			}
+		}
+	}
+}
+
+func TestResolveIdEscapesNames(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	dirId := server.AddFolder(drivetest.RootId, "Bob's files")
+	fileId := server.AddFile(dirId, `back\slash.txt`, []byte("x"))
+
+	ids, err := gdrive.ResolveIds([]string{`drive:/Bob's files`, `drive:/Bob's files/back\slash.txt`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids[0] != dirId || ids[1] != fileId {
+		t.Fatalf("Expected ids %s and %s, got %v", dirId, fileId, ids)
+	}
+}
@@ -11,8 +11,18 @@ const MaxDrawInterval = time.Second * 1
 const MaxRateInterval = time.Second * 3
 
 func getProgressReader(r io.Reader, w io.Writer, size int64) io.Reader {
-	// Don't wrap reader if output is discarded or size is too small
-	if w == ioutil.Discard || (size > 0 && size < 1024*1024) {
+	// Don't wrap reader if output is discarded or missing
+	if w == nil || w == ioutil.Discard {
+		return r
+	}
+
+	// Report to the combined progress of concurrent transfers
+	if shared, ok := w.(*sharedProgress); ok {
+		return shared.reader(r)
+	}
+
+	// Don't wrap reader if size is too small
+	if size > 0 && size < 1024*1024 {
 		return r
 	}
 
@@ -39,11 +49,16 @@ func (self *Progress) Read(p []byte) (int, error) {
 	// Read
 	n, err := self.Reader.Read(p)
 
+	self.update(int64(n), err != nil)
+
+	return n, err
+}
+
+func (self *Progress) update(n int64, isLast bool) {
 	now := time.Now()
-	isLast := err != nil
 
 	// Increment progress
-	newProgress := self.progress + int64(n)
+	newProgress := self.progress + n
 	self.progress = newProgress
 
 	// Initialize rate state
@@ -67,8 +82,6 @@ func (self *Progress) Read(p []byte) (int, error) {
 
 	// Mark as done if error occurs
 	self.done = isLast
-
-	return n, err
 }
 
 func (self *Progress) draw(isLast bool) {
@@ -0,0 +1,151 @@
+package drive
+
+import (
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// App properties used by sync to find the files of a sync root
+// and to restore the permissions and symlinks of downloaded files
+var syncAppProperties = []string{"sync", "syncRoot", "syncRootId", "syncMode", "syncSymlink"}
+
+type PropsGetArgs struct {
+	Out        io.Writer
+	Id         string
+	SkipHeader bool
+}
+
+type PropertyRecord struct {
+	Scope string `json:"scope"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+var propertyRecordHeader = []string{"Scope", "Key", "Value"}
+
+func (self *Drive) PropsGet(args PropsGetArgs) error {
+	f, err := self.backend.GetFile(args.Id, "id", "properties", "appProperties")
+	if err != nil {
+		return fmt.Errorf("Failed to get file: %s", err)
+	}
+
+	records := append(propertyRecords("property", f.Properties), propertyRecords("app", f.AppProperties)...)
+
+	var rows [][]string
+	for _, r := range records {
+		rows = append(rows, []string{r.Scope, r.Key, r.Value})
+	}
+
+	return self.render(args.Out, Result{
+		Value:      records,
+		Header:     propertyRecordHeader,
+		Rows:       rows,
+		SkipHeader: args.SkipHeader,
+		Table: func(w io.Writer) {
+			tw := new(tabwriter.Writer)
+			tw.Init(w, 0, 0, 3, ' ', 0)
+
+			if !args.SkipHeader {
+				fmt.Fprintln(tw, "Scope\tKey\tValue")
+			}
+
+			for _, r := range records {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Scope, r.Key, r.Value)
+			}
+
+			tw.Flush()
+		},
+	})
+}
+
+func propertyRecords(scope string, props map[string]string) []PropertyRecord {
+	var keys []string
+	for key := range props {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	records := []PropertyRecord{}
+	for _, key := range keys {
+		records = append(records, PropertyRecord{Scope: scope, Key: key, Value: props[key]})
+	}
+	return records
+}
+
+type PropsSetArgs struct {
+	Out   io.Writer
+	Id    string
+	Key   string
+	Value string
+	App   bool
+}
+
+func (self *Drive) PropsSet(args PropsSetArgs) error {
+	if err := checkPropertyKey(args.Key, args.App); err != nil {
+		return err
+	}
+
+	props := map[string]string{args.Key: args.Value}
+
+	dstFile := &drive.File{Properties: props}
+	if args.App {
+		dstFile = &drive.File{AppProperties: props}
+	}
+
+	f, err := self.backend.UpdateFile(args.Id, dstFile, FileUpdate{Fields: []googleapi.Field{"id", "name"}})
+	if err != nil {
+		return fmt.Errorf("Failed to set property: %s", err)
+	}
+
+	self.event(args.Out, Event{Action: "props", Path: f.Name, Id: f.Id, Message: fmt.Sprintf("Set %s=%s on '%s'", args.Key, args.Value, f.Name)})
+	return nil
+}
+
+type PropsDeleteArgs struct {
+	Out io.Writer
+	Id  string
+	Key string
+	App bool
+}
+
+func (self *Drive) PropsDelete(args PropsDeleteArgs) error {
+	if err := checkPropertyKey(args.Key, args.App); err != nil {
+		return err
+	}
+
+	var err error
+	if args.App {
+		err = self.backend.DeleteProperties(args.Id, nil, []string{args.Key})
+	} else {
+		err = self.backend.DeleteProperties(args.Id, []string{args.Key}, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to delete property: %s", err)
+	}
+
+	self.event(args.Out, Event{Action: "props", Id: args.Id, Message: fmt.Sprintf("Deleted %s from %s", args.Key, args.Id)})
+	return nil
+}
+
+// Changing the sync app properties by hand would break the sync root
+func checkPropertyKey(key string, app bool) error {
+	if key == "" {
+		return fmt.Errorf("The property key can not be empty")
+	}
+
+	if !app {
+		return nil
+	}
+
+	for _, k := range syncAppProperties {
+		if key == k {
+			return fmt.Errorf("The app property '%s' is managed by sync and can not be changed", key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package drive
+
+import (
+	"bytes"
+	"github.com/prasmussen/gdrive/drive/drivetest"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProps(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	fileId := server.AddFile(drivetest.RootId, "a.txt", []byte("a"))
+
+	for _, args := range []PropsSetArgs{
+		{Id: fileId, Key: "build", Value: "42"},
+		{Id: fileId, Key: "owner", Value: "ci", App: true},
+	} {
+		args.Out = ioutil.Discard
+		if err := gdrive.PropsSet(args); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := &bytes.Buffer{}
+	if err := gdrive.PropsGet(PropsGetArgs{Out: out, Id: fileId, SkipHeader: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if fields := strings.Fields(out.String()); strings.Join(fields, " ") != "property build 42 app owner ci" {
+		t.Fatalf("Unexpected properties: %q", out.String())
+	}
+
+	if err := gdrive.PropsDelete(PropsDeleteArgs{Out: ioutil.Discard, Id: fileId, Key: "build"}); err != nil {
+		t.Fatal(err)
+	}
+
+	f := server.File(fileId)
+	if _, ok := f.Properties["build"]; ok || f.AppProperties["owner"] != "ci" {
+		t.Fatalf("Unexpected properties after delete: %v %v", f.Properties, f.AppProperties)
+	}
+
+	if err := gdrive.PropsSet(PropsSetArgs{Out: ioutil.Discard, Id: fileId, Key: "syncRootId", Value: "x", App: true}); err == nil {
+		t.Fatal("Expected the sync app properties to be protected")
+	}
+}
+
+func TestUploadWithProperties(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"a.txt": "a"})
+
+	err := gdrive.Upload(UploadArgs{
+		Out:        ioutil.Discard,
+		Progress:   ioutil.Discard,
+		Path:       filepath.Join(dir, "a.txt"),
+		Properties: map[string]string{"build": "42"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := gdrive.listAllFiles(listAllFilesArgs{query: "properties has {key='build' and value='42'}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 || files[0].Id != server.Find(drivetest.RootId, "a.txt").Id {
+		t.Fatalf("Expected the uploaded file to be found by property, got %v", files)
+	}
+}
@@ -0,0 +1,447 @@
+// Package query evaluates drive search queries against file metadata,
+// for backends that don't have a search api of their own
+package query
+
+import (
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"sort"
+	"strings"
+	"time"
+)
+
+const folderMimeType = "application/vnd.google-apps.folder"
+
+// Env answers the parts of a query that can't be decided from the metadata of a single file
+type Env interface {
+	// Returns true if the file, or any of its ancestors, is trashed
+	IsTrashed(f *drive.File) bool
+
+	// Returns true if value is in the parents, owners, writers or readers of the file
+	IsMember(f *drive.File, collection, value string) bool
+}
+
+// Predicate compiled from a search query
+type Matcher func(f *drive.File) bool
+
+type token struct {
+	kind  string // string, ident, op or punct
+	value string
+}
+
+// Compiles the subset of the drive search syntax used by gdrive:
+// comparisons on name, mimeType, trashed, starred and times,
+// 'x' in parents/owners, properties has {...}, and, or, not and parentheses
+func Parse(q string, env Env) (Matcher, error) {
+	if strings.TrimSpace(q) == "" {
+		return func(*drive.File) bool { return true }, nil
+	}
+
+	tokens, err := tokenize(q)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &queryParser{env: env, tokens: tokens}
+	m, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.done() {
+		return nil, fmt.Errorf("Unexpected '%s'", p.peek().value)
+	}
+
+	return m, nil
+}
+
+func tokenize(q string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(q); {
+		c := q[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '\'':
+			var value []byte
+			i++
+			for ; i < len(q) && q[i] != '\''; i++ {
+				if q[i] == '\\' && i+1 < len(q) {
+					i++
+				}
+				value = append(value, q[i])
+			}
+			if i == len(q) {
+				return nil, fmt.Errorf("Unterminated string")
+			}
+			i++
+			tokens = append(tokens, token{"string", string(value)})
+		case strings.IndexByte("(){}", c) >= 0:
+			tokens = append(tokens, token{"punct", string(c)})
+			i++
+		case strings.IndexByte("=!<>", c) >= 0:
+			j := i + 1
+			if j < len(q) && q[j] == '=' {
+				j++
+			}
+			op := q[i:j]
+			if op == "!" {
+				return nil, fmt.Errorf("Invalid operator '!'")
+			}
+			tokens = append(tokens, token{"op", op})
+			i = j
+		case isIdentChar(c):
+			j := i
+			for j < len(q) && isIdentChar(q[j]) {
+				j++
+			}
+			tokens = append(tokens, token{"ident", q[i:j]})
+			i = j
+		default:
+			return nil, fmt.Errorf("Unexpected character '%c'", c)
+		}
+	}
+
+	return tokens, nil
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '.' || c == '-' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+type queryParser struct {
+	env    Env
+	tokens []token
+	pos    int
+}
+
+func (self *queryParser) done() bool {
+	return self.pos >= len(self.tokens)
+}
+
+func (self *queryParser) peek() token {
+	if self.done() {
+		return token{}
+	}
+	return self.tokens[self.pos]
+}
+
+func (self *queryParser) next() token {
+	t := self.peek()
+	self.pos++
+	return t
+}
+
+func (self *queryParser) accept(kind, value string) bool {
+	t := self.peek()
+	if t.kind == kind && t.value == value {
+		self.pos++
+		return true
+	}
+	return false
+}
+
+func (self *queryParser) expect(kind, value string) error {
+	if !self.accept(kind, value) {
+		return fmt.Errorf("Expected '%s' but got '%s'", value, self.peek().value)
+	}
+	return nil
+}
+
+func (self *queryParser) expectKind(kind string) (string, error) {
+	t := self.next()
+	if t.kind != kind {
+		return "", fmt.Errorf("Expected %s but got '%s'", kind, t.value)
+	}
+	return t.value, nil
+}
+
+func (self *queryParser) parseOr() (Matcher, error) {
+	left, err := self.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for self.accept("ident", "or") {
+		right, err := self.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(f *drive.File) bool { return l(f) || right(f) }
+	}
+
+	return left, nil
+}
+
+func (self *queryParser) parseAnd() (Matcher, error) {
+	left, err := self.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for self.accept("ident", "and") {
+		right, err := self.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(f *drive.File) bool { return l(f) && right(f) }
+	}
+
+	return left, nil
+}
+
+func (self *queryParser) parseUnary() (Matcher, error) {
+	if self.accept("ident", "not") {
+		m, err := self.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(f *drive.File) bool { return !m(f) }, nil
+	}
+
+	if self.accept("punct", "(") {
+		m, err := self.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return m, self.expect("punct", ")")
+	}
+
+	return self.parseTerm()
+}
+
+func (self *queryParser) parseTerm() (Matcher, error) {
+	t := self.next()
+
+	// 'value' in collection
+	if t.kind == "string" {
+		if err := self.expect("ident", "in"); err != nil {
+			return nil, err
+		}
+		collection, err := self.expectKind("ident")
+		if err != nil {
+			return nil, err
+		}
+		return self.membership(t.value, collection)
+	}
+
+	if t.kind != "ident" {
+		return nil, fmt.Errorf("Unexpected '%s'", t.value)
+	}
+	field := t.value
+
+	if self.accept("ident", "has") {
+		return self.parseHas(field)
+	}
+
+	if self.accept("ident", "contains") {
+		value, err := self.expectKind("string")
+		if err != nil {
+			return nil, err
+		}
+		return contains(field, value)
+	}
+
+	op, err := self.expectKind("op")
+	if err != nil {
+		return nil, err
+	}
+
+	value := self.next()
+	if value.kind != "string" && value.kind != "ident" {
+		return nil, fmt.Errorf("Expected a value but got '%s'", value.value)
+	}
+
+	return self.compare(field, op, value.value)
+}
+
+// Parses the rest of 'properties has {key='k' and value='v'}'
+func (self *queryParser) parseHas(field string) (Matcher, error) {
+	if field != "properties" && field != "appProperties" {
+		return nil, fmt.Errorf("Field %s does not support has", field)
+	}
+
+	var key, value string
+	err := firstError(
+		func() error { return self.expect("punct", "{") },
+		func() error { return self.expect("ident", "key") },
+		func() error { return self.expect("op", "=") },
+		func() (err error) { key, err = self.expectKind("string"); return },
+		func() error { return self.expect("ident", "and") },
+		func() error { return self.expect("ident", "value") },
+		func() error { return self.expect("op", "=") },
+		func() (err error) { value, err = self.expectKind("string"); return },
+		func() error { return self.expect("punct", "}") },
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(f *drive.File) bool {
+		props := f.Properties
+		if field == "appProperties" {
+			props = f.AppProperties
+		}
+		v, ok := props[key]
+		return ok && v == value
+	}, nil
+}
+
+func firstError(steps ...func() error) error {
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (self *queryParser) membership(value, collection string) (Matcher, error) {
+	switch collection {
+	case "parents", "owners", "writers", "readers":
+		env := self.env
+		return func(f *drive.File) bool {
+			return env.IsMember(f, collection, value)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("Unsupported collection '%s'", collection)
+}
+
+func contains(field, value string) (Matcher, error) {
+	switch field {
+	case "name":
+		return func(f *drive.File) bool { return strings.Contains(strings.ToLower(f.Name), strings.ToLower(value)) }, nil
+	case "mimeType":
+		return func(f *drive.File) bool { return strings.Contains(f.MimeType, value) }, nil
+	case "fullText":
+		return func(f *drive.File) bool {
+			return strings.Contains(f.Name, value) || strings.Contains(f.Description, value)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("Field %s does not support contains", field)
+}
+
+func (self *queryParser) compare(field, op, value string) (Matcher, error) {
+	switch field {
+	case "name", "mimeType":
+		if op != "=" && op != "!=" {
+			return nil, fmt.Errorf("Field %s does not support %s", field, op)
+		}
+		return func(f *drive.File) bool {
+			actual := f.Name
+			if field == "mimeType" {
+				actual = f.MimeType
+			}
+			return (actual == value) == (op == "=")
+		}, nil
+	case "trashed", "starred":
+		env := self.env
+		if (op != "=" && op != "!=") || (value != "true" && value != "false") {
+			return nil, fmt.Errorf("Invalid comparison %s %s %s", field, op, value)
+		}
+		return func(f *drive.File) bool {
+			actual := f.Starred
+			if field == "trashed" {
+				actual = env.IsTrashed(f)
+			}
+			return (actual == (value == "true")) == (op == "=")
+		}, nil
+	case "modifiedTime", "createdTime":
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid time '%s'", value)
+		}
+		return func(f *drive.File) bool {
+			actualValue := f.ModifiedTime
+			if field == "createdTime" {
+				actualValue = f.CreatedTime
+			}
+			actual, _ := time.Parse(time.RFC3339, actualValue)
+			return compareTime(actual, op, t)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("Unsupported field '%s'", field)
+}
+
+func compareTime(a time.Time, op string, b time.Time) bool {
+	switch op {
+	case "=":
+		return a.Equal(b)
+	case "!=":
+		return !a.Equal(b)
+	case "<":
+		return a.Before(b)
+	case "<=":
+		return !a.After(b)
+	case ">":
+		return a.After(b)
+	case ">=":
+		return !a.Before(b)
+	}
+	return false
+}
+
+// Sorts files by a comma separated list of keys, i.e. 'folder,name desc'
+func Sort(files []*drive.File, orderBy string) error {
+	if orderBy == "" {
+		return nil
+	}
+
+	type sortKey struct {
+		less func(a, b *drive.File) bool
+		desc bool
+	}
+
+	var keys []sortKey
+	for _, field := range strings.Split(orderBy, ",") {
+		parts := strings.Fields(field)
+		if len(parts) == 0 || len(parts) > 2 || (len(parts) == 2 && parts[1] != "desc") {
+			return fmt.Errorf("Invalid sort order '%s'", field)
+		}
+
+		key := sortKey{desc: len(parts) == 2}
+
+		switch parts[0] {
+		case "folder":
+			key.less = func(a, b *drive.File) bool { return a.MimeType == folderMimeType && b.MimeType != folderMimeType }
+		case "name", "name_natural":
+			key.less = func(a, b *drive.File) bool { return a.Name < b.Name }
+		case "modifiedTime":
+			key.less = func(a, b *drive.File) bool { return a.ModifiedTime < b.ModifiedTime }
+		case "createdTime":
+			key.less = func(a, b *drive.File) bool { return a.CreatedTime < b.CreatedTime }
+		case "quotaBytesUsed":
+			key.less = func(a, b *drive.File) bool { return a.Size < b.Size }
+		default:
+			return fmt.Errorf("Unsupported sort key '%s'", parts[0])
+		}
+
+		keys = append(keys, key)
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		for _, key := range keys {
+			a, b := files[i], files[j]
+			if key.desc {
+				a, b = b, a
+			}
+
+			if key.less(a, b) {
+				return true
+			}
+			if key.less(b, a) {
+				return false
+			}
+		}
+		return false
+	})
+
+	return nil
+}
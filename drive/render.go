@@ -0,0 +1,145 @@
+package drive
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	OutputTable = "table"
+	OutputJson  = "json"
+	OutputJsonl = "jsonl"
+	OutputCsv   = "csv"
+)
+
+// Result is the typed result of a command together with
+// everything the renderers need to encode it
+type Result struct {
+	// Typed value encoded by the json renderers,
+	// slices are written as one object per line in jsonl mode
+	Value interface{}
+
+	// Columns used by the csv renderer
+	Header     []string
+	Rows       [][]string
+	SkipHeader bool
+
+	// Writes the human readable table
+	Table func(w io.Writer)
+}
+
+// Event describes a single action taken by a sync or transfer command
+type Event struct {
+	Action  string `json:"action"`
+	Path    string `json:"path,omitempty"`
+	Target  string `json:"target,omitempty"`
+	Id      string `json:"id,omitempty"`
+	Size    int64  `json:"size,omitempty"`
+	Message string `json:"message"`
+}
+
+func (self Event) row() []string {
+	return []string{self.Action, self.Path, self.Target, self.Id, strconv.FormatInt(self.Size, 10), strings.TrimSpace(self.Message)}
+}
+
+type Renderer interface {
+	Render(out io.Writer, result Result) error
+	RenderEvent(out io.Writer, event Event) error
+}
+
+func NewRenderer(format string) (Renderer, error) {
+	switch format {
+	case "", OutputTable:
+		return tableRenderer{}, nil
+	case OutputJson:
+		return jsonRenderer{}, nil
+	case OutputJsonl:
+		return jsonRenderer{lines: true}, nil
+	case OutputCsv:
+		return csvRenderer{}, nil
+	}
+
+	return nil, fmt.Errorf("Unknown output format '%s', must be one of %s, %s, %s or %s", format, OutputTable, OutputJson, OutputJsonl, OutputCsv)
+}
+
+type tableRenderer struct{}
+
+func (self tableRenderer) Render(out io.Writer, result Result) error {
+	result.Table(out)
+	return nil
+}
+
+func (self tableRenderer) RenderEvent(out io.Writer, event Event) error {
+	_, err := fmt.Fprintln(out, event.Message)
+	return err
+}
+
+type jsonRenderer struct {
+	lines bool
+}
+
+func (self jsonRenderer) Render(out io.Writer, result Result) error {
+	enc := json.NewEncoder(out)
+
+	if !self.lines {
+		enc.SetIndent("", "  ")
+		return enc.Encode(result.Value)
+	}
+
+	v := reflect.ValueOf(result.Value)
+	if v.Kind() != reflect.Slice {
+		return enc.Encode(result.Value)
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		if err := enc.Encode(v.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Events are always written one per line to keep the output streamable
+func (self jsonRenderer) RenderEvent(out io.Writer, event Event) error {
+	event.Message = strings.TrimSpace(event.Message)
+	return json.NewEncoder(out).Encode(event)
+}
+
+type csvRenderer struct{}
+
+func (self csvRenderer) Render(out io.Writer, result Result) error {
+	w := csv.NewWriter(out)
+
+	if !result.SkipHeader {
+		w.Write(result.Header)
+	}
+	w.WriteAll(result.Rows)
+
+	return w.Error()
+}
+
+func (self csvRenderer) RenderEvent(out io.Writer, event Event) error {
+	w := csv.NewWriter(out)
+	w.Write(event.row())
+	w.Flush()
+	return w.Error()
+}
+
+func (self *Drive) render(out io.Writer, result Result) error {
+	return self.renderer.Render(out, result)
+}
+
+func (self *Drive) event(out io.Writer, event Event) {
+	self.renderer.RenderEvent(out, event)
+}
+
+// Writes an informational message that is not tied to a single file
+func (self *Drive) message(out io.Writer, format string, a ...interface{}) {
+	self.event(out, Event{Action: "message", Message: fmt.Sprintf(format, a...)})
+}
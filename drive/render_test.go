@@ -0,0 +1,154 @@
+package drive
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type renderTestRecord struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+func TestRenderResult(t *testing.T) {
+	result := Result{
+		Value:  []renderTestRecord{{"a, b.txt", 1}, {`say "hi".txt`, 2}},
+		Header: []string{"Name", "Size"},
+		Rows:   [][]string{{"a, b.txt", "1"}, {`say "hi".txt`, "2"}},
+	}
+
+	tests := []struct {
+		format   string
+		result   Result
+		expected string
+	}{
+		{
+			format:   OutputCsv,
+			result:   result,
+			expected: "Name,Size\n\"a, b.txt\",1\n\"say \"\"hi\"\".txt\",2\n",
+		},
+		{
+			format:   OutputCsv,
+			result:   Result{Header: result.Header, Rows: result.Rows, SkipHeader: true},
+			expected: "\"a, b.txt\",1\n\"say \"\"hi\"\".txt\",2\n",
+		},
+		{
+			format:   OutputJsonl,
+			result:   result,
+			expected: "{\"name\":\"a, b.txt\",\"size\":1}\n{\"name\":\"say \\\"hi\\\".txt\",\"size\":2}\n",
+		},
+		{
+			format:   OutputJsonl,
+			result:   Result{Value: renderTestRecord{"a.txt", 1}},
+			expected: "{\"name\":\"a.txt\",\"size\":1}\n",
+		},
+		{
+			format:   OutputJson,
+			result:   Result{Value: renderTestRecord{"a.txt", 1}},
+			expected: "{\n  \"name\": \"a.txt\",\n  \"size\": 1\n}\n",
+		},
+	}
+
+	for _, test := range tests {
+		renderer, err := NewRenderer(test.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out := &bytes.Buffer{}
+		if err := renderer.Render(out, test.result); err != nil {
+			t.Fatal(err)
+		}
+
+		if out.String() != test.expected {
+			t.Fatalf("Unexpected %s output, expected %q got %q", test.format, test.expected, out.String())
+		}
+	}
+}
+
+func TestRenderEvents(t *testing.T) {
+	events := []Event{
+		{Action: "upload", Path: "a, b.txt", Target: "sync/a, b.txt", Id: "id1", Size: 3, Message: "Uploading a, b.txt\n"},
+		{Action: "skip", Path: `say "hi".txt`, Message: `Skipping say "hi".txt`},
+	}
+
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{
+			format:   OutputCsv,
+			expected: "upload,\"a, b.txt\",\"sync/a, b.txt\",id1,3,\"Uploading a, b.txt\"\nskip,\"say \"\"hi\"\".txt\",,,0,\"Skipping say \"\"hi\"\".txt\"\n",
+		},
+		{
+			format:   OutputTable,
+			expected: "Uploading a, b.txt\n\nSkipping say \"hi\".txt\n",
+		},
+	}
+
+	for _, test := range tests {
+		renderer, err := NewRenderer(test.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out := &bytes.Buffer{}
+		for _, event := range events {
+			if err := renderer.RenderEvent(out, event); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if out.String() != test.expected {
+			t.Fatalf("Unexpected %s output, expected %q got %q", test.format, test.expected, out.String())
+		}
+	}
+}
+
+func TestRenderJsonlEvents(t *testing.T) {
+	events := []Event{
+		{Action: "upload", Path: "a.txt", Id: "id1", Size: 3, Message: "Uploading a.txt\n"},
+		{Action: "message", Message: "Done"},
+	}
+
+	// Events are written one object per line in both json modes
+	for _, format := range []string{OutputJson, OutputJsonl} {
+		renderer, err := NewRenderer(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out := &bytes.Buffer{}
+		for _, event := range events {
+			if err := renderer.RenderEvent(out, event); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		if len(lines) != len(events) {
+			t.Fatalf("Expected %d %s lines, got %q", len(events), format, out.String())
+		}
+
+		for i, line := range lines {
+			event := Event{}
+			if err := json.Unmarshal([]byte(line), &event); err != nil {
+				t.Fatalf("Failed to decode %s line %q: %s", format, line, err)
+			}
+
+			expected := events[i]
+			expected.Message = strings.TrimSpace(expected.Message)
+			if event != expected {
+				t.Fatalf("Expected event %+v, got %+v", expected, event)
+			}
+		}
+	}
+}
+
+func TestNewRendererRejectsUnknownFormats(t *testing.T) {
+	if _, err := NewRenderer("xml"); err == nil {
+		t.Fatal("Expected an error for an unknown output format")
+	}
+}
@@ -12,7 +12,12 @@ type DeleteRevisionArgs struct {
 }
 
 func (self *Drive) DeleteRevision(args DeleteRevisionArgs) (err error) {
-	rev, err := self.service.Revisions.Get(args.FileId, args.RevisionId).Fields("originalFilename").Do()
+	google, err := self.googleOnly("revisions")
+	if err != nil {
+		return err
+	}
+
+	rev, err := google.service.Revisions.Get(args.FileId, args.RevisionId).Fields("originalFilename").Do(google.fileOptions()...)
 	if err != nil {
 		return fmt.Errorf("Failed to get revision: %s", err)
 	}
@@ -21,11 +26,11 @@ func (self *Drive) DeleteRevision(args DeleteRevisionArgs) (err error) {
 		return fmt.Errorf("Deleting revisions for this file type is not supported")
 	}
 
-	err = self.service.Revisions.Delete(args.FileId, args.RevisionId).Do()
+	err = google.service.Revisions.Delete(args.FileId, args.RevisionId).Do(google.fileOptions()...)
 	if err != nil {
-		return fmt.Errorf("Failed to delete revision", err)
+		return fmt.Errorf("Failed to delete revision: %s", err)
 	}
 
-	fmt.Fprintf(args.Out, "Deleted revision '%s'\n", args.RevisionId)
+	self.event(args.Out, Event{Action: "delete", Id: args.FileId, Message: fmt.Sprintf("Deleted revision '%s'", args.RevisionId)})
 	return
 }
@@ -20,9 +20,14 @@ type DownloadRevisionArgs struct {
 }
 
 func (self *Drive) DownloadRevision(args DownloadRevisionArgs) (err error) {
-	getRev := self.service.Revisions.Get(args.FileId, args.RevisionId)
+	google, err := self.googleOnly("revisions")
+	if err != nil {
+		return err
+	}
 
-	rev, err := getRev.Fields("originalFilename").Do()
+	getRev := google.service.Revisions.Get(args.FileId, args.RevisionId)
+
+	rev, err := getRev.Fields("originalFilename").Do(google.fileOptions()...)
 	if err != nil {
 		return fmt.Errorf("Failed to get file: %s", err)
 	}
@@ -34,7 +39,7 @@ func (self *Drive) DownloadRevision(args DownloadRevisionArgs) (err error) {
 	// Get timeout reader wrapper and context
 	timeoutReaderWrapper, ctx := getTimeoutReaderWrapperContext(args.Timeout)
 
-	res, err := getRev.Context(ctx).Download()
+	res, err := getRev.Context(ctx).Download(google.fileOptions()...)
 	if err != nil {
 		if isTimeoutError(err) {
 			return fmt.Errorf("Failed to download file: timeout, no data was transferred for %v", args.Timeout)
@@ -54,7 +59,13 @@ func (self *Drive) DownloadRevision(args DownloadRevisionArgs) (err error) {
 	// Path to file
 	fpath := filepath.Join(args.Path, rev.OriginalFilename)
 
-	fmt.Fprintf(out, "Downloading %s -> %s\n", rev.OriginalFilename, fpath)
+	self.event(out, Event{
+		Action:  "download",
+		Path:    rev.OriginalFilename,
+		Target:  fpath,
+		Id:      args.FileId,
+		Message: fmt.Sprintf("Downloading %s -> %s", rev.OriginalFilename, fpath),
+	})
 
 	bytes, rate, err := self.saveFile(saveFileArgs{
 		out:           args.Out,
@@ -70,6 +81,11 @@ func (self *Drive) DownloadRevision(args DownloadRevisionArgs) (err error) {
 		return err
 	}
 
-	fmt.Fprintf(out, "Download complete, rate: %s/s, total size: %s\n", formatSize(rate, false), formatSize(bytes, false))
+	self.event(out, Event{
+		Action:  "downloaded",
+		Id:      args.FileId,
+		Size:    bytes,
+		Message: fmt.Sprintf("Download complete, rate: %s/s, total size: %s", formatSize(rate, false), formatSize(bytes, false)),
+	})
 	return nil
 }
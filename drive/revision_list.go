@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"google.golang.org/api/drive/v3"
 	"io"
+	"strconv"
 	"text/tabwriter"
 )
 
@@ -16,20 +17,47 @@ type ListRevisionsArgs struct {
 }
 
 func (self *Drive) ListRevisions(args ListRevisionsArgs) (err error) {
-	revList, err := self.service.Revisions.List(args.Id).Fields("revisions(id,keepForever,size,modifiedTime,originalFilename)").Do()
+	google, err := self.googleOnly("revisions")
+	if err != nil {
+		return err
+	}
+
+	revList, err := google.service.Revisions.List(args.Id).Fields("revisions(id,keepForever,size,modifiedTime,originalFilename)").Do(google.fileOptions()...)
 	if err != nil {
 		return fmt.Errorf("Failed listing revisions: %s", err)
 	}
 
-	PrintRevisionList(PrintRevisionListArgs{
-		Out:         args.Out,
-		Revisions:   revList.Revisions,
-		NameWidth:   int(args.NameWidth),
-		SkipHeader:  args.SkipHeader,
-		SizeInBytes: args.SizeInBytes,
+	records := make([]RevisionRecord, 0, len(revList.Revisions))
+	var rows [][]string
+	for _, rev := range revList.Revisions {
+		r := RevisionRecord{rev.Id, rev.OriginalFilename, rev.Size, rev.ModifiedTime, rev.KeepForever}
+		records = append(records, r)
+		rows = append(rows, []string{r.Id, r.Name, strconv.FormatInt(r.Size, 10), r.Modified, strconv.FormatBool(r.KeepForever)})
+	}
+
+	return self.render(args.Out, Result{
+		Value:      records,
+		Header:     []string{"Id", "Name", "Size", "Modified", "KeepForever"},
+		Rows:       rows,
+		SkipHeader: args.SkipHeader,
+		Table: func(w io.Writer) {
+			PrintRevisionList(PrintRevisionListArgs{
+				Out:         w,
+				Revisions:   revList.Revisions,
+				NameWidth:   int(args.NameWidth),
+				SkipHeader:  args.SkipHeader,
+				SizeInBytes: args.SizeInBytes,
+			})
+		},
 	})
+}
 
-	return
+type RevisionRecord struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	Modified    string `json:"modifiedTime"`
+	KeepForever bool   `json:"keepForever"`
 }
 
 type PrintRevisionListArgs struct {
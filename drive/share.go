@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"google.golang.org/api/drive/v3"
 	"io"
+	"strconv"
 	"text/tabwriter"
 )
 
@@ -26,12 +27,21 @@ func (self *Drive) Share(args ShareArgs) error {
 		Domain:             args.Domain,
 	}
 
-	_, err := self.service.Permissions.Create(args.FileId, permission).Do()
+	google, err := self.googleOnly("sharing")
+	if err != nil {
+		return err
+	}
+
+	_, err = google.service.Permissions.Create(args.FileId, permission).Do(google.fileOptions()...)
 	if err != nil {
 		return fmt.Errorf("Failed to share file: %s", err)
 	}
 
-	fmt.Fprintf(args.Out, "Granted %s permission to %s\n", args.Role, args.Type)
+	self.event(args.Out, Event{
+		Action:  "share",
+		Id:      args.FileId,
+		Message: fmt.Sprintf("Granted %s permission to %s", args.Role, args.Type),
+	})
 	return nil
 }
 
@@ -42,13 +52,17 @@ type RevokePermissionArgs struct {
 }
 
 func (self *Drive) RevokePermission(args RevokePermissionArgs) error {
-	err := self.service.Permissions.Delete(args.FileId, args.PermissionId).Do()
+	google, err := self.googleOnly("sharing")
 	if err != nil {
-		fmt.Errorf("Failed to revoke permission: %s", err)
 		return err
 	}
 
-	fmt.Fprintf(args.Out, "Permission revoked\n")
+	err = google.service.Permissions.Delete(args.FileId, args.PermissionId).Do(google.fileOptions()...)
+	if err != nil {
+		return fmt.Errorf("Failed to revoke permission: %s", err)
+	}
+
+	self.event(args.Out, Event{Action: "revoke", Id: args.FileId, Message: "Permission revoked"})
 	return nil
 }
 
@@ -58,17 +72,44 @@ type ListPermissionsArgs struct {
 }
 
 func (self *Drive) ListPermissions(args ListPermissionsArgs) error {
-	permList, err := self.service.Permissions.List(args.FileId).Fields("permissions(id,role,type,domain,emailAddress,allowFileDiscovery)").Do()
+	google, err := self.googleOnly("sharing")
 	if err != nil {
-		fmt.Errorf("Failed to list permissions: %s", err)
 		return err
 	}
 
-	printPermissions(printPermissionsArgs{
-		out:         args.Out,
-		permissions: permList.Permissions,
+	permList, err := google.service.Permissions.List(args.FileId).Fields("permissions(id,role,type,domain,emailAddress,allowFileDiscovery)").Do(google.fileOptions()...)
+	if err != nil {
+		return fmt.Errorf("Failed to list permissions: %s", err)
+	}
+
+	records := make([]PermissionRecord, 0, len(permList.Permissions))
+	var rows [][]string
+	for _, p := range permList.Permissions {
+		r := PermissionRecord{p.Id, p.Type, p.Role, p.EmailAddress, p.Domain, p.AllowFileDiscovery}
+		records = append(records, r)
+		rows = append(rows, []string{r.Id, r.Type, r.Role, r.Email, r.Domain, strconv.FormatBool(r.Discoverable)})
+	}
+
+	return self.render(args.Out, Result{
+		Value:  records,
+		Header: []string{"Id", "Type", "Role", "Email", "Domain", "Discoverable"},
+		Rows:   rows,
+		Table: func(w io.Writer) {
+			printPermissions(printPermissionsArgs{
+				out:         w,
+				permissions: permList.Permissions,
+			})
+		},
 	})
-	return nil
+}
+
+type PermissionRecord struct {
+	Id           string `json:"id"`
+	Type         string `json:"type"`
+	Role         string `json:"role"`
+	Email        string `json:"emailAddress,omitempty"`
+	Domain       string `json:"domain,omitempty"`
+	Discoverable bool   `json:"discoverable"`
 }
 
 func (self *Drive) shareAnyoneReader(fileId string) error {
@@ -77,7 +118,12 @@ func (self *Drive) shareAnyoneReader(fileId string) error {
 		Type: "anyone",
 	}
 
-	_, err := self.service.Permissions.Create(fileId, permission).Do()
+	google, err := self.googleOnly("sharing")
+	if err != nil {
+		return err
+	}
+
+	_, err = google.service.Permissions.Create(fileId, permission).Do(google.fileOptions()...)
 	if err != nil {
 		return fmt.Errorf("Failed to share file: %s", err)
 	}
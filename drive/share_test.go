@@ -0,0 +1,73 @@
+package drive
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/prasmussen/gdrive/drive/drivetest"
+	"io/ioutil"
+	"testing"
+)
+
+func TestShareAndRevoke(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	id := server.AddFile(drivetest.RootId, "shared.txt", []byte("shared"))
+
+	err := gdrive.Share(ShareArgs{
+		Out:    ioutil.Discard,
+		FileId: id,
+		Role:   "writer",
+		Type:   "user",
+		Email:  "friend@example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	permissions := server.Permissions(id)
+	if len(permissions) != 1 || permissions[0].EmailAddress != "friend@example.com" || permissions[0].Role != "writer" {
+		t.Fatalf("Unexpected permissions: %+v", permissions)
+	}
+
+	// List permissions as json
+	out := &bytes.Buffer{}
+	gdrive.SetRenderer(jsonRenderer{})
+	if err := gdrive.ListPermissions(ListPermissionsArgs{Out: out, FileId: id}); err != nil {
+		t.Fatal(err)
+	}
+
+	var records []PermissionRecord
+	if err := json.Unmarshal(out.Bytes(), &records); err != nil {
+		t.Fatalf("Failed to decode permissions: %s\n%s", err, out)
+	}
+	if len(records) != 1 || records[0].Id != permissions[0].Id {
+		t.Fatalf("Unexpected permission records: %+v", records)
+	}
+
+	err = gdrive.RevokePermission(RevokePermissionArgs{
+		Out:          ioutil.Discard,
+		FileId:       id,
+		PermissionId: records[0].Id,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if permissions := server.Permissions(id); len(permissions) != 0 {
+		t.Fatalf("Expected permission to be revoked, got %+v", permissions)
+	}
+}
+
+func TestShareRequiresEmail(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	id := server.AddFile(drivetest.RootId, "shared.txt", []byte("shared"))
+
+	err := gdrive.Share(ShareArgs{
+		Out:    ioutil.Discard,
+		FileId: id,
+		Role:   "reader",
+		Type:   "user",
+	})
+	if err == nil {
+		t.Fatal("Expected sharing with a user without email to fail")
+	}
+}
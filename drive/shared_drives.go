@@ -0,0 +1,245 @@
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"io"
+	"net/http"
+	"net/url"
+	"text/tabwriter"
+)
+
+// Listings and changes of a sync root in a shared drive are limited
+// to that drive, even if no drive was given to SetDriveId
+func (self *Drive) loadSyncDrive(rootDir *drive.File) error {
+	if self.google == nil {
+		return nil
+	}
+
+	driveId, err := self.google.fileDriveId(rootDir.Id)
+	if err != nil {
+		return fmt.Errorf("Failed to get the drive of the root dir: %s", err)
+	}
+
+	if driveId == "" {
+		delete(self.syncDrives, rootDir.Id)
+		return nil
+	}
+
+	if self.syncDrives == nil {
+		self.syncDrives = map[string]string{}
+	}
+	self.syncDrives[rootDir.Id] = driveId
+	return nil
+}
+
+// Returns the shared drive of the sync root, or an empty string for my drive
+func (self *Drive) syncDriveId(rootDir *drive.File) string {
+	return self.syncDrives[rootDir.Id]
+}
+
+// Query parameter that is not supported by the generated drive client
+type queryParam struct {
+	key   string
+	value string
+}
+
+func (self queryParam) Get() (string, string) {
+	return self.key, self.value
+}
+
+// Limits all listings to the given shared drive, an empty id means my drive
+func (self *Drive) SetDriveId(driveId string) error {
+	google, err := self.googleOnly("shared drives")
+	if err != nil {
+		return err
+	}
+
+	google.driveId = driveId
+	return nil
+}
+
+// Id of the root directory, which is the shared drive itself if one is set
+func (self *Drive) rootId() string {
+	return self.backend.RootId()
+}
+
+// New files end up in the root directory if no parents are given
+func (self *Drive) parentsOrRoot(parents []string) []string {
+	if len(parents) == 0 {
+		return []string{self.rootId()}
+	}
+	return parents
+}
+
+// Options for calls on a single file, needed to access files in shared drives
+func (self *googleBackend) fileOptions() []googleapi.CallOption {
+	return []googleapi.CallOption{queryParam{"supportsAllDrives", "true"}}
+}
+
+// Returns the given shared drive or the one given to SetDriveId
+func (self *googleBackend) sharedDriveId(driveId string) string {
+	if driveId != "" {
+		return driveId
+	}
+	return self.driveId
+}
+
+// Options for file listings
+func (self *googleBackend) listOptions(driveId string) []googleapi.CallOption {
+	driveId = self.sharedDriveId(driveId)
+	if driveId == "" {
+		return self.fileOptions()
+	}
+
+	return append(self.fileOptions(),
+		queryParam{"includeItemsFromAllDrives", "true"},
+		queryParam{"corpora", "drive"},
+		queryParam{"driveId", driveId},
+	)
+}
+
+// Options for change listings and start page tokens
+func (self *googleBackend) changesOptions(driveId string) []googleapi.CallOption {
+	driveId = self.sharedDriveId(driveId)
+	if driveId == "" {
+		return self.fileOptions()
+	}
+
+	return append(self.fileOptions(),
+		queryParam{"includeItemsFromAllDrives", "true"},
+		queryParam{"driveId", driveId},
+	)
+}
+
+// Adds the file options to query parameters of requests made without the generated client
+func (self *googleBackend) setFileOptions(params url.Values) {
+	for _, opt := range self.fileOptions() {
+		params.Set(opt.Get())
+	}
+}
+
+type ListDrivesArgs struct {
+	Out        io.Writer
+	SkipHeader bool
+}
+
+type DriveRecord struct {
+	Id      string `json:"id"`
+	Name    string `json:"name"`
+	Created string `json:"createdTime"`
+}
+
+type driveList struct {
+	NextPageToken string         `json:"nextPageToken"`
+	Drives        []*DriveRecord `json:"drives"`
+}
+
+func (self *Drive) ListDrives(args ListDrivesArgs) error {
+	google, err := self.googleOnly("shared drives")
+	if err != nil {
+		return err
+	}
+
+	records := []DriveRecord{}
+	var rows [][]string
+
+	pageToken := ""
+	for {
+		list, err := google.listDrivesPage(pageToken)
+		if err != nil {
+			return fmt.Errorf("Failed to list shared drives: %s", err)
+		}
+
+		for _, d := range list.Drives {
+			records = append(records, *d)
+			rows = append(rows, []string{d.Id, d.Name, d.Created})
+		}
+
+		if list.NextPageToken == "" {
+			break
+		}
+		pageToken = list.NextPageToken
+	}
+
+	return self.render(args.Out, Result{
+		Value:      records,
+		Header:     []string{"Id", "Name", "Created"},
+		Rows:       rows,
+		SkipHeader: args.SkipHeader,
+		Table: func(out io.Writer) {
+			w := new(tabwriter.Writer)
+			w.Init(out, 0, 0, 3, ' ', 0)
+
+			if !args.SkipHeader {
+				fmt.Fprintln(w, "Id\tName\tCreated")
+			}
+
+			for _, d := range records {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", d.Id, d.Name, formatDatetime(d.Created))
+			}
+
+			w.Flush()
+		},
+	})
+}
+
+// The generated client predates shared drives, so they are listed with a plain request
+func (self *googleBackend) listDrivesPage(pageToken string) (*driveList, error) {
+	params := url.Values{}
+	params.Set("pageSize", "100")
+	params.Set("fields", "nextPageToken,drives(id,name,createdTime)")
+	if pageToken != "" {
+		params.Set("pageToken", pageToken)
+	}
+
+	list := &driveList{}
+	if err := self.getJson("drives", params, list); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// Returns the id of the shared drive the file is in, an empty string
+// means my drive. The generated client has no driveId field either
+func (self *googleBackend) fileDriveId(id string) (string, error) {
+	params := url.Values{}
+	params.Set("fields", "driveId")
+	self.setFileOptions(params)
+
+	var f struct {
+		DriveId string `json:"driveId"`
+	}
+	if err := self.getJson("files/"+id, params, &f); err != nil {
+		return "", err
+	}
+
+	return f.DriveId, nil
+}
+
+func (self *googleBackend) getJson(path string, params url.Values, v interface{}) error {
+	urls := googleapi.ResolveRelative(self.service.BasePath, path)
+	req, err := http.NewRequest("GET", urls+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := ctxhttp.Do(context.TODO(), self.client, req)
+	if err != nil {
+		return err
+	}
+
+	// Close body on function exit
+	defer res.Body.Close()
+
+	if err := googleapi.CheckResponse(res); err != nil {
+		return err
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
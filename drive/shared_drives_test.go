@@ -0,0 +1,81 @@
+package drive
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/prasmussen/gdrive/drive/drivetest"
+	"io/ioutil"
+	"testing"
+)
+
+func TestListDrives(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	teamId := server.AddDrive("Team")
+	archiveId := server.AddDrive("Archive")
+
+	gdrive.SetRenderer(jsonRenderer{})
+	out := &bytes.Buffer{}
+	if err := gdrive.ListDrives(ListDrivesArgs{Out: out}); err != nil {
+		t.Fatal(err)
+	}
+
+	var records []DriveRecord
+	if err := json.Unmarshal(out.Bytes(), &records); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 || records[0].Id != teamId || records[0].Name != "Team" || records[1].Id != archiveId || records[1].Name != "Archive" {
+		t.Fatalf("Unexpected shared drives %+v", records)
+	}
+
+	assertRequested(t, server, "GET /drive/v3/drives")
+}
+
+func TestSetDriveId(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	server.AddFile(drivetest.RootId, "mine.txt", []byte("mine"))
+	driveId := server.AddDrive("Team")
+	teamFileId := server.AddFile(driveId, "team.txt", []byte("team"))
+
+	if err := gdrive.SetDriveId(driveId); err != nil {
+		t.Fatal(err)
+	}
+
+	// Listings only include files in the shared drive
+	gdrive.SetRenderer(jsonRenderer{})
+	out := &bytes.Buffer{}
+	if err := gdrive.List(ListFilesArgs{Out: out}); err != nil {
+		t.Fatal(err)
+	}
+
+	var records []FileRecord
+	if err := json.Unmarshal(out.Bytes(), &records); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 1 || records[0].Id != teamFileId {
+		t.Fatalf("Expected only team.txt to be listed, got %+v", records)
+	}
+
+	// New files without parents end up in the root of the shared drive
+	err := gdrive.Mkdir(MkdirArgs{Out: ioutil.Discard, Name: "reports"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if server.Find(driveId, "reports") == nil {
+		t.Fatal("Expected the directory to be created in the shared drive")
+	}
+	if server.Find(drivetest.RootId, "reports") != nil {
+		t.Fatal("Expected no directory to be created in my drive")
+	}
+
+	// Paths are resolved from the root of the shared drive
+	id, err := gdrive.ResolveId("drive:/team.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != teamFileId {
+		t.Fatalf("Expected drive:/team.txt to resolve to %s, got %s", teamFileId, id)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
@@ -16,6 +17,9 @@ import (
 
 const DefaultIgnoreFile = ".gdriveignore"
 
+// Fields needed to place a file in the sync tree and compare it
+var syncFileFields = []googleapi.Field{"id", "name", "parents", "md5Checksum", "mimeType", "size", "modifiedTime", "appProperties"}
+
 type ModTime int
 
 const (
@@ -24,47 +28,34 @@ const (
 	EqualModifiedTime
 )
 
-type LargestSize int
-
-const (
-	LocalLargestSize LargestSize = iota
-	RemoteLargestSize
-	EqualSize
-)
-
-type ConflictResolution int
-
-const (
-	NoResolution ConflictResolution = iota
-	KeepLocal
-	KeepRemote
-	KeepLargest
-)
-
-func (self *Drive) prepareSyncFiles(localPath string, root *drive.File, cmp FileComparer) (*syncFiles, error) {
+func (self *Drive) prepareSyncFiles(localPath string, root *drive.File, cmp FileComparer, state *syncState, symlinks SymlinkPolicy, skipInvalid bool) (*syncFiles, error) {
 	localCh := make(chan struct {
-		files []*LocalFile
-		err   error
+		files   []*LocalFile
+		skipped []string
+		err     error
 	})
 	remoteCh := make(chan struct {
-		files []*RemoteFile
-		err   error
+		files    []*RemoteFile
+		problems []*syncProblem
+		err      error
 	})
 
 	go func() {
-		files, err := prepareLocalFiles(localPath)
+		files, skipped, err := prepareLocalFiles(localPath, symlinks)
 		localCh <- struct {
-			files []*LocalFile
-			err   error
-		}{files, err}
+			files   []*LocalFile
+			skipped []string
+			err     error
+		}{files, skipped, err}
 	}()
 
 	go func() {
-		files, err := self.prepareRemoteFiles(root, "")
+		files, problems, err := self.prepareIncrementalRemoteFiles(root, state, skipInvalid)
 		remoteCh <- struct {
-			files []*RemoteFile
-			err   error
-		}{files, err}
+			files    []*RemoteFile
+			problems []*syncProblem
+			err      error
+		}{files, problems, err}
 	}()
 
 	local := <-localCh
@@ -77,16 +68,86 @@ func (self *Drive) prepareSyncFiles(localPath string, root *drive.File, cmp File
 		return nil, remote.err
 	}
 
-	return &syncFiles{
+	files := &syncFiles{
 		root:    &RemoteFile{file: root},
 		local:   local.files,
 		remote:  remote.files,
 		compare: cmp,
-	}, nil
+		state:   state,
+		skipped: local.skipped,
+	}
+
+	// Invalid remote files are only found if skipInvalid is given
+	files.skipSyncProblems(remote.problems)
+
+	// Remote symlinks can only be synced as symlinks
+	if symlinks != SymlinkPreserve {
+		files.skipRemoteSymlinks()
+	}
+
+	return files, nil
+}
+
+// Google documents can not be downloaded, they are synced as exported
+// files named after the export format if export mimes are given.
+// Documents exported by an earlier sync keep the path of their export so
+// that the exported file is left alone, other documents are left out of
+// the sync. Returns a message for every document that was left out
+func (self *syncFiles) prepareDocuments(exportMimes map[string]string) []string {
+	// Local paths of the documents exported by the last sync
+	exported := map[string]string{}
+	for relPath, entry := range self.state.Files {
+		if entry.Exported {
+			exported[entry.Id] = relPath
+		}
+	}
+
+	var documents, remote []*RemoteFile
+	taken := map[string]bool{}
+
+	for _, rf := range self.remote {
+		if rf.isDocument() {
+			documents = append(documents, rf)
+		} else {
+			remote = append(remote, rf)
+			taken[rf.relPath] = true
+		}
+	}
+
+	var skipped []string
+
+	for _, rf := range documents {
+		relPath, found := exported[rf.file.Id]
+
+		if exportMimes != nil {
+			exportMime, err := lookupExportMime(exportMimes, rf.file)
+			if err != nil {
+				skipped = append(skipped, fmt.Sprintf("Skipping %s: %s", rf.relPath, err))
+				continue
+			}
+			relPath = getExportFilename(rf.relPath, exportMime)
+			rf.exportMime = exportMime
+		} else if !found {
+			skipped = append(skipped, fmt.Sprintf("Skipping google document %s, use --export to export it", rf.relPath))
+			continue
+		}
+
+		if taken[relPath] {
+			skipped = append(skipped, fmt.Sprintf("Skipping google document %s, %s already exists", rf.relPath, relPath))
+			continue
+		}
+
+		rf.relPath = relPath
+		taken[relPath] = true
+		remote = append(remote, rf)
+	}
+
+	self.remote = remote
+	return skipped
 }
 
 func (self *Drive) isSyncFile(id string) (bool, error) {
-	f, err := self.service.Files.Get(id).Fields("appProperties").Do()
+	f, err := self.backend.GetFile(id, "appProperties")
 	if err != nil {
 		return false, fmt.Errorf("Failed to get file: %s", err)
 	}
@@ -95,89 +156,100 @@ func (self *Drive) isSyncFile(id string) (bool, error) {
 	return ok, nil
 }
 
-func prepareLocalFiles(root string) ([]*LocalFile, error) {
-	var files []*LocalFile
+// Returns the appProperties of files placed in the given directory,
+// nil if the directory is not part of a sync
+func inheritedSyncProperties(parent *drive.File) map[string]string {
+	if _, ok := parent.AppProperties["syncRoot"]; ok {
+		return map[string]string{"sync": "true", "syncRootId": parent.Id}
+	}
 
+	if rootId, ok := parent.AppProperties["syncRootId"]; ok {
+		return map[string]string{"sync": "true", "syncRootId": rootId}
+	}
+
+	return nil
+}
+
+func prepareLocalFiles(root string, symlinks SymlinkPolicy) ([]*LocalFile, []string, error) {
 	// Get absolute root path
 	absRootPath, err := filepath.Abs(root)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Prepare ignorer
 	shouldIgnore, err := prepareIgnorer(filepath.Join(absRootPath, DefaultIgnoreFile))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	err = filepath.Walk(absRootPath, func(absPath string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Skip root directory
-		if absPath == absRootPath {
-			return nil
-		}
-
-		// Skip files that are not a directory or regular file
-		if !info.IsDir() && !info.Mode().IsRegular() {
-			return nil
-		}
-
-		// Get relative path from root
-		relPath, err := filepath.Rel(absRootPath, absPath)
-		if err != nil {
-			return err
-		}
+	// Symlink loops are detected by the real path of directories
+	realRootPath, err := filepath.EvalSymlinks(absRootPath)
+	if err != nil {
+		realRootPath = absRootPath
+	}
 
-		// Skip file if it is ignored by ignore file
-		if shouldIgnore(relPath) {
-			return nil
-		}
+	walker := &localWalker{
+		shouldIgnore: shouldIgnore,
+		symlinks:     symlinks,
+	}
 
-		files = append(files, &LocalFile{
-			absPath: absPath,
-			relPath: relPath,
-			info:    info,
-		})
+	err = walker.walk(absRootPath, ".", []string{realRootPath})
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to prepare local files: %s", err)
+	}
 
-		return nil
-	})
+	return walker.files, walker.skipped, nil
+}
 
+func (self *Drive) prepareRemoteFiles(rootDir *drive.File, sortOrder string) ([]*RemoteFile, error) {
+	files, err := self.listSyncRootFiles(rootDir, sortOrder)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to prepare local files: %s", err)
+		return nil, err
 	}
 
-	return files, err
+	remoteFiles, _, err := prepareRemoteFileTree(rootDir, files, false)
+	return remoteFiles, err
 }
 
-func (self *Drive) prepareRemoteFiles(rootDir *drive.File, sortOrder string) ([]*RemoteFile, error) {
+func (self *Drive) listSyncRootFiles(rootDir *drive.File, sortOrder string) ([]*drive.File, error) {
 	// Find all files which has rootDir as root
 	listArgs := listAllFilesArgs{
-		query:     fmt.Sprintf("appProperties has {key='syncRootId' and value='%s'}", rootDir.Id),
-		fields:    []googleapi.Field{"nextPageToken", "files(id,name,parents,md5Checksum,mimeType,size,modifiedTime)"},
+		query:     fmt.Sprintf("appProperties has {key='syncRootId' and value='%s'} and trashed = false", rootDir.Id),
+		fields:    []googleapi.Field{"nextPageToken", "files(id,name,parents,md5Checksum,mimeType,size,modifiedTime,appProperties)"},
 		sortOrder: sortOrder,
+		driveId:   self.syncDriveId(rootDir),
 	}
 	files, err := self.listAllFiles(listArgs)
 	if err != nil {
 		return nil, fmt.Errorf("Failed listing files: %s", err)
 	}
 
-	if err := checkFiles(files); err != nil {
-		return nil, err
+	return files, nil
+}
+
+// Places the files in the sync tree. Files that can not be placed fail the
+// sync, unless skipInvalid is given in which case they are left out of
+// the tree together with their content and returned as problems
+func prepareRemoteFileTree(rootDir *drive.File, files []*drive.File, skipInvalid bool) ([]*RemoteFile, []*syncProblem, error) {
+	problems := findSyncProblems(rootDir, files)
+	if len(problems) > 0 {
+		if !skipInvalid {
+			return nil, nil, problems[0].err(rootDir.Id)
+		}
+		files = withoutSyncProblems(rootDir, files, problems)
 	}
 
 	relPaths, err := prepareRemoteRelPaths(rootDir, files)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var remoteFiles []*RemoteFile
 	for _, f := range files {
 		relPath, ok := relPaths[f.Id]
 		if !ok {
-			return nil, fmt.Errorf("File %s does not have a valid parent", f.Id)
+			return nil, nil, fmt.Errorf("File %s does not have a valid parent", f.Id)
 		}
 		remoteFiles = append(remoteFiles, &RemoteFile{
 			relPath: relPath,
@@ -185,7 +257,7 @@ func (self *Drive) prepareRemoteFiles(rootDir *drive.File, sortOrder string) ([]
 		})
 	}
 
-	return remoteFiles, nil
+	return remoteFiles, problems, nil
 }
 
 func prepareRemoteRelPaths(root *drive.File, files []*drive.File) (map[string]string, error) {
@@ -258,40 +330,30 @@ func prepareRemoteRelPaths(root *drive.File, files []*drive.File) (map[string]st
 	return paths, nil
 }
 
-func checkFiles(files []*drive.File) error {
-	uniq := map[string]string{}
-
-	for _, f := range files {
-		// Ensure all files have exactly one parent
-		if len(f.Parents) != 1 {
-			return fmt.Errorf("File %s does not have exacly one parent", f.Id)
-		}
-
-		// Ensure that there are no duplicate files
-		uniqKey := f.Name + f.Parents[0]
-		if dupeId, isDupe := uniq[uniqKey]; isDupe {
-			return fmt.Errorf("Found name collision between %s and %s", f.Id, dupeId)
-		}
-		uniq[uniqKey] = f.Id
-	}
-
-	return nil
-}
-
 type LocalFile struct {
-	absPath string
-	relPath string
-	info    os.FileInfo
+	absPath    string
+	relPath    string
+	info       os.FileInfo
+	linkTarget string
 }
 
 type RemoteFile struct {
-	relPath string
-	file    *drive.File
+	relPath    string
+	file       *drive.File
+	exportMime string
 }
 
 type changedFile struct {
 	local  *LocalFile
 	remote *RemoteFile
+	base   *syncStateEntry
+	action ConflictAction
+	reason string
+}
+
+type renamedFile struct {
+	local  *LocalFile
+	remote *RemoteFile
 }
 
 type syncFiles struct {
@@ -299,12 +361,38 @@ type syncFiles struct {
 	local   []*LocalFile
 	remote  []*RemoteFile
 	compare FileComparer
+	state   *syncState
+	skipped []string
 }
 
 type FileComparer interface {
 	Changed(*LocalFile, *RemoteFile) bool
 }
 
+// Leaves the local files at the given paths out of the sync, including their content
+func (self *syncFiles) skipLocalPaths(paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+
+	var local []*LocalFile
+	for _, lf := range self.local {
+		if !isPathOrChild(lf.relPath, paths) {
+			local = append(local, lf)
+		}
+	}
+	self.local = local
+}
+
+func isPathOrChild(relPath string, paths []string) bool {
+	for _, p := range paths {
+		if relPath == p || isChildPath(p, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
 func (self LocalFile) AbsPath() string {
 	return self.absPath
 }
@@ -321,6 +409,21 @@ func (self RemoteFile) Md5() string {
 	return self.file.Md5Checksum
 }
 
+// Returns true if the file is a google document, which has no content
+// of its own and can only be synced by exporting it
+func (self RemoteFile) isDocument() bool {
+	return !isDir(self.file) && !isBinary(self.file)
+}
+
+// Returns what identifies the content of the file, documents have
+// no md5 and are identified by their modified time instead
+func (self RemoteFile) version() string {
+	if self.isDocument() {
+		return self.file.ModifiedTime
+	}
+	return self.Md5()
+}
+
 func (self RemoteFile) Size() int64 {
 	return self.file.Size
 }
@@ -331,7 +434,8 @@ func (self RemoteFile) Modified() time.Time {
 }
 
 func (self *changedFile) compareModTime() ModTime {
-	localTime := self.local.Modified()
+	// Drive keeps modification times with millisecond precision
+	localTime := self.local.Modified().Truncate(time.Millisecond)
 	remoteTime := self.remote.Modified()
 
 	if localTime.After(remoteTime) {
@@ -345,19 +449,22 @@ func (self *changedFile) compareModTime() ModTime {
 	return EqualModifiedTime
 }
 
-func (self *changedFile) compareSize() LargestSize {
-	localSize := self.local.Size()
-	remoteSize := self.remote.Size()
-
-	if localSize > remoteSize {
-		return LocalLargestSize
+// Returns true if the local file has been modified since the last sync,
+// modification times are compared if the file has not been synced before
+func (self *changedFile) localModified() bool {
+	if self.base != nil {
+		return self.base.localChanged(self.local)
 	}
+	return self.compareModTime() == LocalLastModified
+}
 
-	if remoteSize > localSize {
-		return RemoteLargestSize
+// Returns true if the remote file has been modified since the last sync,
+// modification times are compared if the file has not been synced before
+func (self *changedFile) remoteModified() bool {
+	if self.base != nil {
+		return self.base.remoteChanged(self.remote)
 	}
-
-	return EqualSize
+	return self.compareModTime() == RemoteLastModified
 }
 
 func (self *syncFiles) filterMissingRemoteDirs() []*LocalFile {
@@ -400,6 +507,11 @@ func (self *syncFiles) filterMissingLocalFiles() []*RemoteFile {
 	var files []*RemoteFile
 
 	for _, rf := range self.remote {
+		// Skip documents that are not exported
+		if rf.isDocument() && rf.exportMime == "" {
+			continue
+		}
+
 		if !isDir(rf.file) && !self.existsLocal(rf) {
 			files = append(files, rf)
 		}
@@ -417,17 +529,20 @@ func (self *syncFiles) filterChangedLocalFiles() []*changedFile {
 			continue
 		}
 
-		// Skip files that don't exist on drive
+		// Skip files that don't exist on drive, exported
+		// documents are never uploaded
 		rf, found := self.findRemoteByPath(lf.relPath)
-		if !found {
+		if !found || rf.isDocument() {
 			continue
 		}
 
 		// Check if file has changed
-		if self.compare.Changed(lf, rf) {
+		if self.contentChanged(lf, rf) {
+			base, _ := self.state.get(lf.relPath)
 			files = append(files, &changedFile{
 				local:  lf,
 				remote: rf,
+				base:   base,
 			})
 		}
 	}
@@ -450,11 +565,12 @@ func (self *syncFiles) filterChangedRemoteFiles() []*changedFile {
 			continue
 		}
 
-		// Check if file has changed
-		if self.compare.Changed(lf, rf) {
+		base, _ := self.state.get(lf.relPath)
+		if self.remoteFileChanged(lf, rf, base) {
 			files = append(files, &changedFile{
 				local:  lf,
 				remote: rf,
+				base:   base,
 			})
 		}
 	}
@@ -462,10 +578,37 @@ func (self *syncFiles) filterChangedRemoteFiles() []*changedFile {
 	return files
 }
 
+// Symlinks are compared by their target, other files by the comparer
+func (self *syncFiles) contentChanged(lf *LocalFile, rf *RemoteFile) bool {
+	if lf.isSymlink() || rf.isSymlink() {
+		return lf.linkTarget != rf.linkTarget()
+	}
+	return self.compare.Changed(lf, rf)
+}
+
+// Exports can not be compared by content, they are changed if either
+// side has changed since the last sync
+func (self *syncFiles) remoteFileChanged(lf *LocalFile, rf *RemoteFile, base *syncStateEntry) bool {
+	if !rf.isDocument() {
+		return self.contentChanged(lf, rf)
+	}
+
+	if rf.exportMime == "" {
+		return false
+	}
+
+	return base == nil || base.remoteChanged(rf) || base.localChanged(lf)
+}
+
 func (self *syncFiles) filterExtraneousRemoteFiles() []*RemoteFile {
 	var files []*RemoteFile
 
 	for _, rf := range self.remote {
+		// Documents are never deleted by the deletion of their export
+		if rf.isDocument() {
+			continue
+		}
+
 		if !self.existsLocal(rf) {
 			files = append(files, rf)
 		}
@@ -486,6 +629,236 @@ func (self *syncFiles) filterExtraneousLocalFiles() []*LocalFile {
 	return files
 }
 
+// Finds missing remote files that are local files which have been renamed
+// or moved since the last sync. A file is considered renamed if it has the
+// same size and modification time as a synced file that no longer exists
+// locally, the remote counterpart of that file is unchanged and the content
+// matches the md5 recorded for the remote file
+func (self *syncFiles) findLocalRenames(missingFiles []*LocalFile) ([]*renamedFile, []*LocalFile) {
+	var candidates []*RemoteFile
+	var candidateKeys []string
+
+	for relPath, base := range self.state.Files {
+		if base.IsDir || base.Exported {
+			continue
+		}
+
+		if _, found := self.findLocalByPath(relPath); found {
+			continue
+		}
+
+		rf, found := self.findRemoteByPath(relPath)
+		if !found || base.remoteChanged(rf) {
+			continue
+		}
+
+		candidates = append(candidates, rf)
+		candidateKeys = append(candidateKeys, fmt.Sprintf("%d:%d", base.Size, base.Modified))
+	}
+
+	missingKeys := make([]string, len(missingFiles))
+	for i, lf := range missingFiles {
+		missingKeys[i] = fmt.Sprintf("%d:%d", lf.Size(), lf.Modified().UnixNano())
+	}
+
+	// Equal size and modification time is not enough, i.e. after cp -p
+	matches := matchRenames(missingKeys, candidateKeys, func(i, j int) bool {
+		return !self.contentChanged(missingFiles[i], candidates[j])
+	})
+
+	var renamed []*renamedFile
+	var remaining []*LocalFile
+
+	for i, j := range matches {
+		if j < 0 {
+			remaining = append(remaining, missingFiles[i])
+			continue
+		}
+		renamed = append(renamed, &renamedFile{local: missingFiles[i], remote: candidates[j]})
+	}
+
+	return renamed, remaining
+}
+
+// Finds missing local files that are remote files which have been renamed
+// or moved since the last sync. A file is considered renamed if it has the
+// same id and content as a synced file that no longer exists remotely,
+// and the local counterpart of that file is unchanged
+func (self *syncFiles) findRemoteRenames(missingFiles []*RemoteFile) ([]*renamedFile, []*RemoteFile) {
+	candidates := map[string]*LocalFile{}
+
+	for relPath, base := range self.state.Files {
+		if base.IsDir {
+			continue
+		}
+
+		if _, found := self.findRemoteByPath(relPath); found {
+			continue
+		}
+
+		lf, found := self.findLocalByPath(relPath)
+		if !found || base.localChanged(lf) {
+			continue
+		}
+
+		candidates[base.Id+":"+base.version()] = lf
+	}
+
+	var renamed []*renamedFile
+	var remaining []*RemoteFile
+
+	for _, rf := range missingFiles {
+		if lf, found := candidates[rf.file.Id+":"+rf.version()]; found {
+			renamed = append(renamed, &renamedFile{local: lf, remote: rf})
+			continue
+		}
+
+		remaining = append(remaining, rf)
+	}
+
+	return renamed, remaining
+}
+
+// Finds missing remote files that have the same md5 and size as an
+// extraneous remote file that would otherwise be deleted, so that files
+// renamed without a previous sync state are moved instead of uploaded again
+func (self *syncFiles) findLocalContentRenames(missingFiles []*LocalFile, renamed []*renamedFile, resolution ConflictResolution) ([]*renamedFile, []*LocalFile) {
+	// Remote files that are already renamed are not extraneous
+	moved := map[string]bool{}
+	for _, rn := range renamed {
+		moved[rn.remote.file.Id] = true
+	}
+
+	var candidates []*RemoteFile
+	var candidateKeys []string
+
+	for _, rf := range self.filterExtraneousRemoteFiles() {
+		if !isBinary(rf.file) || rf.isSymlink() || moved[rf.file.Id] {
+			continue
+		}
+
+		if skip, _ := checkExtraneousRemote(rf, self, nil, resolution); skip {
+			continue
+		}
+
+		candidates = append(candidates, rf)
+		candidateKeys = append(candidateKeys, strconv.FormatInt(rf.Size(), 10))
+	}
+
+	missingKeys := make([]string, len(missingFiles))
+	for i, lf := range missingFiles {
+		if !lf.isSymlink() {
+			missingKeys[i] = strconv.FormatInt(lf.Size(), 10)
+		}
+	}
+
+	matches := matchRenames(missingKeys, candidateKeys, func(i, j int) bool {
+		return !self.contentChanged(missingFiles[i], candidates[j])
+	})
+
+	var remaining []*LocalFile
+
+	for i, j := range matches {
+		if j < 0 {
+			remaining = append(remaining, missingFiles[i])
+			continue
+		}
+		renamed = append(renamed, &renamedFile{local: missingFiles[i], remote: candidates[j]})
+	}
+
+	return renamed, remaining
+}
+
+// Finds missing local files that have the same md5 and size as an
+// extraneous local file that would otherwise be deleted, so that files
+// renamed remotely without a previous sync state are moved instead of
+// downloaded again
+func (self *syncFiles) findRemoteContentRenames(missingFiles []*RemoteFile, renamed []*renamedFile, resolution ConflictResolution) ([]*renamedFile, []*RemoteFile) {
+	// Local files that are already renamed are not extraneous
+	moved := map[string]bool{}
+	for _, rn := range renamed {
+		moved[rn.local.relPath] = true
+	}
+
+	var candidates []*LocalFile
+	var candidateKeys []string
+
+	for _, lf := range self.filterExtraneousLocalFiles() {
+		if lf.info.IsDir() || lf.isSymlink() || moved[lf.relPath] {
+			continue
+		}
+
+		if skip, _ := checkExtraneousLocal(lf, self, nil, resolution); skip {
+			continue
+		}
+
+		candidates = append(candidates, lf)
+		candidateKeys = append(candidateKeys, strconv.FormatInt(lf.Size(), 10))
+	}
+
+	missingKeys := make([]string, len(missingFiles))
+	for i, rf := range missingFiles {
+		if isBinary(rf.file) && !rf.isSymlink() {
+			missingKeys[i] = strconv.FormatInt(rf.Size(), 10)
+		}
+	}
+
+	matches := matchRenames(missingKeys, candidateKeys, func(i, j int) bool {
+		return !self.contentChanged(candidates[j], missingFiles[i])
+	})
+
+	var remaining []*RemoteFile
+
+	for i, j := range matches {
+		if j < 0 {
+			remaining = append(remaining, missingFiles[i])
+			continue
+		}
+		renamed = append(renamed, &renamedFile{local: candidates[j], remote: missingFiles[i]})
+	}
+
+	return renamed, remaining
+}
+
+// Pairs missing files with rename candidates that have the same key, i.e.
+// the size of the file, and for which same returns true. Only unambiguous
+// matches are treated as renames and every candidate is used at most once.
+// Missing files with an empty key are never matched. Returns the index of
+// the matching candidate for every missing file, or -1 if there is none
+func matchRenames(missingKeys, candidateKeys []string, same func(i, j int) bool) []int {
+	byKey := map[string][]int{}
+	for j, key := range candidateKeys {
+		byKey[key] = append(byKey[key], j)
+	}
+
+	matches := make([]int, len(missingKeys))
+	for i, key := range missingKeys {
+		matches[i] = -1
+		if key == "" {
+			continue
+		}
+
+		index := -1
+		count := 0
+		for n, j := range byKey[key] {
+			if same(i, j) {
+				index = n
+				count++
+			}
+		}
+
+		if count != 1 {
+			continue
+		}
+
+		js := byKey[key]
+		matches[i] = js[index]
+		byKey[key] = append(js[:index:index], js[index+1:]...)
+	}
+
+	return matches
+}
+
 func (self *syncFiles) existsRemote(lf *LocalFile) bool {
 	_, found := self.findRemoteByPath(lf.relPath)
 	return found
@@ -524,7 +897,7 @@ func findLocalConflicts(files []*changedFile) []*changedFile {
 	var conflicts []*changedFile
 
 	for _, cf := range files {
-		if cf.compareModTime() == LocalLastModified {
+		if cf.localModified() {
 			conflicts = append(conflicts, cf)
 		}
 	}
@@ -536,7 +909,7 @@ func findRemoteConflicts(files []*changedFile) []*changedFile {
 	var conflicts []*changedFile
 
 	for _, cf := range files {
-		if cf.compareModTime() == RemoteLastModified {
+		if cf.remoteModified() {
 			conflicts = append(conflicts, cf)
 		}
 	}
@@ -544,6 +917,38 @@ func findRemoteConflicts(files []*changedFile) []*changedFile {
 	return conflicts
 }
 
+func sumLocalSize(files []*LocalFile) int64 {
+	var size int64
+	for _, lf := range files {
+		size += lf.Size()
+	}
+	return size
+}
+
+func sumRemoteSize(files []*RemoteFile) int64 {
+	var size int64
+	for _, rf := range files {
+		size += rf.Size()
+	}
+	return size
+}
+
+func sumChangedLocalSize(files []*changedFile) int64 {
+	var size int64
+	for _, cf := range files {
+		size += cf.local.Size()
+	}
+	return size
+}
+
+func sumChangedRemoteSize(files []*changedFile) int64 {
+	var size int64
+	for _, cf := range files {
+		size += cf.remote.Size()
+	}
+	return size
+}
+
 type byLocalPathLength []*LocalFile
 
 func (self byLocalPathLength) Len() int {
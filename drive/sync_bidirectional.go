@@ -0,0 +1,747 @@
+package drive
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type BidirectionalSyncArgs struct {
+	Out         io.Writer
+	Progress    io.Writer
+	Path        string
+	RootId      string
+	DryRun      bool
+	ChunkSize   int64
+	Timeout     time.Duration
+	Resolution  ConflictResolution
+	Comparer    FileComparer
+	StateDir    string
+	Parallel    int
+	SessionDir  string
+	Verify      VerifyPolicy
+	Permanent   bool
+	Symlinks    SymlinkPolicy
+	SkipInvalid bool
+}
+
+func (self *Drive) BidirectionalSync(args BidirectionalSyncArgs) (err error) {
+	if args.ChunkSize > intMax()-1 {
+		return fmt.Errorf("Chunk size is to big, max chunk size for this computer is %d", intMax()-1)
+	}
+
+	self.message(args.Out, "Starting sync...")
+	started := time.Now()
+
+	// Create root directory if it does not exist
+	rootDir, err := self.prepareSyncRoot(args.RootId)
+	if err != nil {
+		return err
+	}
+
+	// Load the state of the previous sync
+	state, err := loadSyncState(args.StateDir, rootDir.Id, args.Path)
+	if err != nil {
+		return err
+	}
+
+	self.message(args.Out, "Collecting local and remote file information...")
+	files, err := self.prepareSyncFiles(args.Path, rootDir, args.Comparer, state, args.Symlinks, args.SkipInvalid)
+	if err != nil {
+		return err
+	}
+
+	// Exported google documents are never uploaded
+	files.prepareDocuments(nil)
+
+	self.message(args.Out, "Found %d local files and %d remote files", len(files.local), len(files.remote))
+
+	changes, err := files.reconcile()
+	if err != nil {
+		return err
+	}
+
+	// Ensure that we don't overwrite any changes on either side
+	if args.Resolution == nil && len(changes.conflicts) > 0 {
+		buffer := bytes.NewBufferString("")
+		formatConflicts(changes.conflicts, buffer)
+		return fmt.Errorf("Conflict detected!\nThe following files have changed both locally and remotely since the last sync:\n\n%s\nNo conflict resolution was given, aborting...", buffer.String())
+	}
+
+	err = self.resolveBidirectionalConflicts(files, changes, args)
+	if err != nil {
+		return err
+	}
+
+	// Ensure that there is enough free space on drive
+	if ok, msg := self.checkRemoteFreeSpace(rootDir, changes.missingRemoteFiles, changes.changedLocalFiles); !ok {
+		return fmt.Errorf("%s", msg)
+	}
+
+	// Checksum results are shared between both directions
+	verified := &verifyStats{}
+
+	uploadArgs := UploadSyncArgs{
+		Out:        args.Out,
+		Progress:   args.Progress,
+		Path:       args.Path,
+		RootId:     rootDir.Id,
+		DryRun:     args.DryRun,
+		ChunkSize:  args.ChunkSize,
+		Timeout:    args.Timeout,
+		Resolution: args.Resolution,
+		Comparer:   args.Comparer,
+		Parallel:   args.Parallel,
+		SessionDir: args.SessionDir,
+		Verify:     args.Verify,
+		Permanent:  args.Permanent,
+		verified:   verified,
+	}
+
+	downloadArgs := DownloadSyncArgs{
+		Out:        args.Out,
+		Progress:   args.Progress,
+		Path:       args.Path,
+		RootId:     rootDir.Id,
+		DryRun:     args.DryRun,
+		Timeout:    args.Timeout,
+		Resolution: args.Resolution,
+		Comparer:   args.Comparer,
+		Parallel:   args.Parallel,
+		Verify:     args.Verify,
+		verified:   verified,
+	}
+
+	// Persist whatever was synced, even if we fail halfway through
+	if !args.DryRun {
+		defer func() {
+			if saveErr := state.save(); err == nil {
+				err = saveErr
+			}
+		}()
+	}
+
+	// Refresh the state of files that are already in sync
+	for _, cf := range changes.unchanged {
+		state.update(cf.local.relPath, cf.local.info, cf.remote.file)
+	}
+	files.pruneState()
+
+	err = self.createBidirectionalRemoteDirs(changes.missingRemoteDirs, files, uploadArgs)
+	if err != nil {
+		return err
+	}
+
+	err = self.createBidirectionalLocalDirs(changes.missingLocalDirs, files, downloadArgs)
+	if err != nil {
+		return err
+	}
+
+	err = self.moveRenamedRemoteFiles(changes.renamedLocalFiles, files, uploadArgs)
+	if err != nil {
+		return err
+	}
+
+	err = self.moveRenamedLocalFiles(changes.renamedRemoteFiles, files, downloadArgs)
+	if err != nil {
+		return err
+	}
+
+	err = self.uploadBidirectionalFiles(changes.missingRemoteFiles, files, uploadArgs)
+	if err != nil {
+		return err
+	}
+
+	err = self.updateBidirectionalFiles(changes.changedLocalFiles, files, uploadArgs)
+	if err != nil {
+		return err
+	}
+
+	err = self.downloadBidirectionalFiles(changes.missingLocalFiles, changes.changedRemoteFiles, files, downloadArgs)
+	if err != nil {
+		return err
+	}
+
+	err = self.deleteBidirectionalRemoteFiles(changes.deletedLocalFiles, files, uploadArgs)
+	if err != nil {
+		return err
+	}
+
+	err = self.deleteBidirectionalLocalFiles(changes.deletedRemoteFiles, files, downloadArgs)
+	if err != nil {
+		return err
+	}
+
+	self.printVerifyStats(args.Out, verified)
+	self.printSkippedFiles(args.Out, files.skipped)
+	self.message(args.Out, "Sync finished in %s", time.Since(started))
+
+	return nil
+}
+
+type bidirectionalChanges struct {
+	missingRemoteDirs  []*LocalFile
+	missingLocalDirs   []*RemoteFile
+	missingRemoteFiles []*LocalFile
+	missingLocalFiles  []*RemoteFile
+	changedLocalFiles  []*changedFile
+	changedRemoteFiles []*changedFile
+	deletedLocalFiles  []*RemoteFile
+	deletedRemoteFiles []*LocalFile
+	renamedLocalFiles  []*renamedFile
+	renamedRemoteFiles []*renamedFile
+	conflicts          []*changedFile
+	unchanged          []*changedFile
+}
+
+// Compares local and remote files with the state of the last sync
+// and decides in which direction each file should be propagated
+func (self *syncFiles) reconcile() (*bidirectionalChanges, error) {
+	changes := &bidirectionalChanges{}
+
+	var localDirs []*LocalFile
+	var remoteDirs []*RemoteFile
+
+	for _, lf := range self.local {
+		rf, remoteFound := self.findRemoteByPath(lf.relPath)
+		base, synced := self.state.get(lf.relPath)
+
+		if remoteFound && lf.info.IsDir() != isDir(rf.file) {
+			return nil, fmt.Errorf("Found both a file and a directory with path '%s'", lf.relPath)
+		}
+
+		if lf.info.IsDir() {
+			if remoteFound {
+				changes.unchanged = append(changes.unchanged, &changedFile{local: lf, remote: rf})
+			} else {
+				localDirs = append(localDirs, lf)
+			}
+			continue
+		}
+
+		// Exported documents are only synced by sync download
+		if remoteFound && rf.isDocument() {
+			continue
+		}
+
+		if !remoteFound {
+			if synced && !base.localChanged(lf) {
+				// File was deleted remotely and is untouched locally
+				changes.deletedRemoteFiles = append(changes.deletedRemoteFiles, lf)
+			} else {
+				changes.missingRemoteFiles = append(changes.missingRemoteFiles, lf)
+			}
+			continue
+		}
+
+		cf := &changedFile{local: lf, remote: rf}
+		localChanged := !synced || base.localChanged(lf)
+		remoteChanged := !synced || base.remoteChanged(rf)
+
+		if (!localChanged && !remoteChanged) || !self.contentChanged(lf, rf) {
+			changes.unchanged = append(changes.unchanged, cf)
+		} else if localChanged && remoteChanged {
+			changes.conflicts = append(changes.conflicts, cf)
+		} else if localChanged {
+			changes.changedLocalFiles = append(changes.changedLocalFiles, cf)
+		} else {
+			changes.changedRemoteFiles = append(changes.changedRemoteFiles, cf)
+		}
+	}
+
+	for _, rf := range self.remote {
+		if _, found := self.findLocalByPath(rf.relPath); found || rf.isDocument() {
+			continue
+		}
+
+		if isDir(rf.file) {
+			remoteDirs = append(remoteDirs, rf)
+			continue
+		}
+
+		base, synced := self.state.get(rf.relPath)
+		if synced && !base.remoteChanged(rf) {
+			// File was deleted locally and is untouched remotely
+			changes.deletedLocalFiles = append(changes.deletedLocalFiles, rf)
+		} else {
+			changes.missingLocalFiles = append(changes.missingLocalFiles, rf)
+		}
+	}
+
+	// A directory that was deleted on the other side is only deleted if
+	// everything inside it is deleted as well, deepest directories first
+	sort.Sort(sort.Reverse(byLocalPathLength(localDirs)))
+	for _, lf := range localDirs {
+		base, synced := self.state.get(lf.relPath)
+		if synced && base.IsDir && self.allLocalDeleted(lf.relPath, changes.deletedRemoteFiles) {
+			changes.deletedRemoteFiles = append(changes.deletedRemoteFiles, lf)
+		} else {
+			changes.missingRemoteDirs = append(changes.missingRemoteDirs, lf)
+		}
+	}
+
+	sort.Sort(sort.Reverse(byRemotePathLength(remoteDirs)))
+	for _, rf := range remoteDirs {
+		base, synced := self.state.get(rf.relPath)
+		if synced && base.IsDir && self.allRemoteDeleted(rf.relPath, changes.deletedLocalFiles) {
+			changes.deletedLocalFiles = append(changes.deletedLocalFiles, rf)
+		} else {
+			changes.missingLocalDirs = append(changes.missingLocalDirs, rf)
+		}
+	}
+
+	// Files renamed on one side are moved on the other side instead of
+	// being deleted and transferred again, which would lose the revisions
+	changes.renamedLocalFiles, changes.missingRemoteFiles = self.findLocalRenames(changes.missingRemoteFiles)
+	changes.deletedLocalFiles = withoutRenamedRemoteFiles(changes.deletedLocalFiles, changes.renamedLocalFiles)
+
+	changes.renamedRemoteFiles, changes.missingLocalFiles = self.findRemoteRenames(changes.missingLocalFiles)
+	changes.deletedRemoteFiles = withoutRenamedLocalFiles(changes.deletedRemoteFiles, changes.renamedRemoteFiles)
+
+	return changes, nil
+}
+
+func withoutRenamedRemoteFiles(files []*RemoteFile, renamed []*renamedFile) []*RemoteFile {
+	isRenamed := map[string]bool{}
+	for _, rn := range renamed {
+		isRenamed[rn.remote.file.Id] = true
+	}
+
+	var remaining []*RemoteFile
+	for _, rf := range files {
+		if !isRenamed[rf.file.Id] {
+			remaining = append(remaining, rf)
+		}
+	}
+	return remaining
+}
+
+func withoutRenamedLocalFiles(files []*LocalFile, renamed []*renamedFile) []*LocalFile {
+	isRenamed := map[string]bool{}
+	for _, rn := range renamed {
+		isRenamed[rn.local.relPath] = true
+	}
+
+	var remaining []*LocalFile
+	for _, lf := range files {
+		if !isRenamed[lf.relPath] {
+			remaining = append(remaining, lf)
+		}
+	}
+	return remaining
+}
+
+func (self *syncFiles) allLocalDeleted(dirPath string, deleted []*LocalFile) bool {
+	isDeleted := map[string]bool{}
+	for _, lf := range deleted {
+		isDeleted[lf.relPath] = true
+	}
+
+	for _, lf := range self.local {
+		if isChildPath(dirPath, lf.relPath) && !isDeleted[lf.relPath] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (self *syncFiles) allRemoteDeleted(dirPath string, deleted []*RemoteFile) bool {
+	isDeleted := map[string]bool{}
+	for _, rf := range deleted {
+		isDeleted[rf.relPath] = true
+	}
+
+	for _, rf := range self.remote {
+		if isChildPath(dirPath, rf.relPath) && !isDeleted[rf.relPath] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (self *Drive) resolveBidirectionalConflicts(files *syncFiles, changes *bidirectionalChanges, args BidirectionalSyncArgs) error {
+	conflictCount := len(changes.conflicts)
+
+	if conflictCount > 0 {
+		self.message(args.Out, "\n%d files has changed both locally and remotely", conflictCount)
+	}
+
+	for i, cf := range changes.conflicts {
+		action, reason := args.Resolution.Resolve(cf.local, cf.remote)
+
+		switch action {
+		case UseLocal:
+			self.event(args.Out, Event{
+				Action:  "keep-local",
+				Path:    cf.local.relPath,
+				Message: fmt.Sprintf("[%04d/%04d] Keeping local %s (%s)", i+1, conflictCount, cf.local.relPath, reason),
+			})
+			changes.changedLocalFiles = append(changes.changedLocalFiles, cf)
+		case UseRemote:
+			self.event(args.Out, Event{
+				Action:  "keep-remote",
+				Path:    cf.remote.relPath,
+				Message: fmt.Sprintf("[%04d/%04d] Keeping remote %s (%s)", i+1, conflictCount, cf.remote.relPath, reason),
+			})
+			changes.changedRemoteFiles = append(changes.changedRemoteFiles, cf)
+		case UseBoth:
+			self.event(args.Out, Event{
+				Action:  "keep-both",
+				Path:    cf.local.relPath,
+				Message: fmt.Sprintf("[%04d/%04d] Keeping both copies of %s (%s)", i+1, conflictCount, cf.local.relPath, reason),
+			})
+
+			// The local copy is uploaded under a name of its own
+			// and the remote file is downloaded in its place
+			lf, err := self.renameLocalConflict(cf, args.Out, args.DryRun)
+			if err != nil {
+				return err
+			}
+			files.local = append(files.local, lf)
+			changes.missingRemoteFiles = append(changes.missingRemoteFiles, lf)
+			changes.changedRemoteFiles = append(changes.changedRemoteFiles, cf)
+		default:
+			self.event(args.Out, Event{
+				Action:  "skip",
+				Path:    cf.local.relPath,
+				Message: fmt.Sprintf("[%04d/%04d] Skipping %s (conflicting file, %s)", i+1, conflictCount, cf.local.relPath, reason),
+			})
+		}
+	}
+
+	return nil
+}
+
+func (self *Drive) createBidirectionalRemoteDirs(missingDirs []*LocalFile, files *syncFiles, args UploadSyncArgs) error {
+	missingCount := len(missingDirs)
+
+	if missingCount > 0 {
+		self.message(args.Out, "\n%d remote directories are missing", missingCount)
+	}
+
+	// Sort directories so that the dirs with the shortest path comes first
+	sort.Sort(byLocalPathLength(missingDirs))
+
+	for i, lf := range missingDirs {
+		parentPath := parentFilePath(lf.relPath)
+		parent, ok := files.findRemoteByPath(parentPath)
+		if !ok {
+			return fmt.Errorf("Could not find remote directory with path '%s'", parentPath)
+		}
+
+		self.event(args.Out, Event{
+			Action:  "mkdir",
+			Path:    filepath.Join(files.root.file.Name, lf.relPath),
+			Message: fmt.Sprintf("[%04d/%04d] Creating directory %s", i+1, missingCount, filepath.Join(files.root.file.Name, lf.relPath)),
+		})
+
+		f, err := self.createMissingRemoteDir(createMissingRemoteDirArgs{
+			name:     lf.info.Name(),
+			parentId: parent.file.Id,
+			rootId:   args.RootId,
+			dryRun:   args.DryRun,
+			try:      0,
+		})
+		if err != nil {
+			return err
+		}
+
+		files.remote = append(files.remote, &RemoteFile{
+			relPath: lf.relPath,
+			file:    f,
+		})
+		files.state.update(lf.relPath, lf.info, f)
+	}
+
+	return nil
+}
+
+func (self *Drive) createBidirectionalLocalDirs(missingDirs []*RemoteFile, files *syncFiles, args DownloadSyncArgs) error {
+	missingCount := len(missingDirs)
+
+	if missingCount > 0 {
+		self.message(args.Out, "\n%d local directories are missing", missingCount)
+	}
+
+	// Sort directories so that the dirs with the shortest path comes first
+	sort.Sort(byRemotePathLength(missingDirs))
+
+	for i, rf := range missingDirs {
+		absPath, err := filepath.Abs(filepath.Join(args.Path, rf.relPath))
+		if err != nil {
+			return fmt.Errorf("Failed to determine local absolute path: %s", err)
+		}
+		self.event(args.Out, Event{
+			Action:  "mkdir",
+			Path:    filepath.Join(filepath.Base(args.Path), rf.relPath),
+			Message: fmt.Sprintf("[%04d/%04d] Creating directory %s", i+1, missingCount, filepath.Join(filepath.Base(args.Path), rf.relPath)),
+		})
+
+		if args.DryRun {
+			continue
+		}
+
+		err = os.MkdirAll(absPath, 0775)
+		if err != nil {
+			return fmt.Errorf("Failed to create local directory: %s", err)
+		}
+
+		if err = files.state.updateFromDisk(rf.relPath, absPath, rf.file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (self *Drive) uploadBidirectionalFiles(missingFiles []*LocalFile, files *syncFiles, args UploadSyncArgs) error {
+	missingCount := len(missingFiles)
+
+	if missingCount > 0 {
+		self.message(args.Out, "\n%d remote files are missing", missingCount)
+	}
+
+	out, progress, done := prepareParallelOutput(args.Parallel, args.Out, args.Progress, sumLocalSize(missingFiles))
+	defer done()
+
+	return runParallel(args.Parallel, missingCount, func(i int) error {
+		lf := missingFiles[i]
+
+		parentPath := parentFilePath(lf.relPath)
+		parent, ok := files.findRemoteByPath(parentPath)
+		if !ok {
+			return fmt.Errorf("Could not find remote directory with path '%s'", parentPath)
+		}
+
+		self.event(out, Event{
+			Action:  "upload",
+			Path:    lf.relPath,
+			Target:  filepath.Join(files.root.file.Name, lf.relPath),
+			Message: fmt.Sprintf("[%04d/%04d] Uploading %s -> %s", i+1, missingCount, lf.relPath, filepath.Join(files.root.file.Name, lf.relPath)),
+		})
+
+		workerArgs := args
+		workerArgs.Out = out
+		workerArgs.Progress = progress
+
+		f, err := self.uploadMissingFile(parent.file.Id, lf, workerArgs, 0)
+		if err != nil {
+			return err
+		}
+
+		if f != nil {
+			files.state.update(lf.relPath, lf.info, f)
+		}
+
+		return nil
+	})
+}
+
+func (self *Drive) updateBidirectionalFiles(changedFiles []*changedFile, files *syncFiles, args UploadSyncArgs) error {
+	changedCount := len(changedFiles)
+
+	if changedCount > 0 {
+		self.message(args.Out, "\n%d local files has changed", changedCount)
+	}
+
+	out, progress, done := prepareParallelOutput(args.Parallel, args.Out, args.Progress, sumChangedLocalSize(changedFiles))
+	defer done()
+
+	return runParallel(args.Parallel, changedCount, func(i int) error {
+		cf := changedFiles[i]
+
+		self.event(out, Event{
+			Action:  "update",
+			Path:    cf.local.relPath,
+			Target:  filepath.Join(files.root.file.Name, cf.local.relPath),
+			Message: fmt.Sprintf("[%04d/%04d] Updating %s -> %s", i+1, changedCount, cf.local.relPath, filepath.Join(files.root.file.Name, cf.local.relPath)),
+		})
+
+		workerArgs := args
+		workerArgs.Out = out
+		workerArgs.Progress = progress
+
+		f, err := self.updateChangedFile(cf, workerArgs, 0)
+		if err != nil {
+			return err
+		}
+
+		if f != nil {
+			files.state.update(cf.local.relPath, cf.local.info, f)
+		}
+
+		return nil
+	})
+}
+
+func (self *Drive) downloadBidirectionalFiles(missingFiles []*RemoteFile, changedFiles []*changedFile, files *syncFiles, args DownloadSyncArgs) error {
+	missingCount := len(missingFiles)
+
+	if missingCount > 0 {
+		self.message(args.Out, "\n%d local files are missing", missingCount)
+	}
+
+	out, progress, done := prepareParallelOutput(args.Parallel, args.Out, args.Progress, sumRemoteSize(missingFiles))
+
+	err := runParallel(args.Parallel, missingCount, func(i int) error {
+		rf := missingFiles[i]
+
+		self.event(out, Event{
+			Action:  "download",
+			Path:    rf.relPath,
+			Target:  filepath.Join(filepath.Base(args.Path), rf.relPath),
+			Message: fmt.Sprintf("[%04d/%04d] Downloading %s -> %s", i+1, missingCount, rf.relPath, filepath.Join(filepath.Base(args.Path), rf.relPath)),
+		})
+
+		workerArgs := args
+		workerArgs.Out = out
+		workerArgs.Progress = progress
+
+		return self.downloadBidirectionalFile(rf, files, workerArgs)
+	})
+	done()
+
+	if err != nil {
+		return err
+	}
+
+	changedCount := len(changedFiles)
+
+	if changedCount > 0 {
+		self.message(args.Out, "\n%d remote files has changed", changedCount)
+	}
+
+	out, progress, done = prepareParallelOutput(args.Parallel, args.Out, args.Progress, sumChangedRemoteSize(changedFiles))
+	defer done()
+
+	return runParallel(args.Parallel, changedCount, func(i int) error {
+		cf := changedFiles[i]
+
+		self.event(out, Event{
+			Action:  "download",
+			Path:    cf.remote.relPath,
+			Target:  filepath.Join(filepath.Base(args.Path), cf.remote.relPath),
+			Message: fmt.Sprintf("[%04d/%04d] Downloading %s -> %s", i+1, changedCount, cf.remote.relPath, filepath.Join(filepath.Base(args.Path), cf.remote.relPath)),
+		})
+
+		workerArgs := args
+		workerArgs.Out = out
+		workerArgs.Progress = progress
+
+		return self.downloadBidirectionalFile(cf.remote, files, workerArgs)
+	})
+}
+
+func (self *Drive) downloadBidirectionalFile(rf *RemoteFile, files *syncFiles, args DownloadSyncArgs) error {
+	absPath, err := filepath.Abs(filepath.Join(args.Path, rf.relPath))
+	if err != nil {
+		return fmt.Errorf("Failed to determine local absolute path: %s", err)
+	}
+
+	err = self.downloadSyncFile(rf, absPath, args)
+	if err != nil || args.DryRun {
+		return err
+	}
+
+	return files.state.updateFromDisk(rf.relPath, absPath, rf.file)
+}
+
+func (self *Drive) deleteBidirectionalRemoteFiles(deletedFiles []*RemoteFile, files *syncFiles, args UploadSyncArgs) error {
+	deletedCount := len(deletedFiles)
+
+	if deletedCount > 0 {
+		self.message(args.Out, "\n%d local files were deleted", deletedCount)
+	}
+
+	// Sort files so that the files with the longest path comes first
+	sort.Sort(sort.Reverse(byRemotePathLength(deletedFiles)))
+
+	for i, rf := range deletedFiles {
+		self.event(args.Out, Event{
+			Action:  "delete",
+			Path:    filepath.Join(files.root.file.Name, rf.relPath),
+			Message: fmt.Sprintf("[%04d/%04d] Deleting %s", i+1, deletedCount, filepath.Join(files.root.file.Name, rf.relPath)),
+		})
+
+		err := self.deleteRemoteFile(rf, args, 0)
+		if err != nil {
+			return err
+		}
+
+		files.state.remove(rf.relPath)
+	}
+
+	return nil
+}
+
+func (self *Drive) deleteBidirectionalLocalFiles(deletedFiles []*LocalFile, files *syncFiles, args DownloadSyncArgs) error {
+	deletedCount := len(deletedFiles)
+
+	if deletedCount > 0 {
+		self.message(args.Out, "\n%d remote files were deleted", deletedCount)
+	}
+
+	// Sort files so that the files with the longest path comes first
+	sort.Sort(sort.Reverse(byLocalPathLength(deletedFiles)))
+
+	// Paths that are deleted, or would be deleted on a dry run
+	deleted := map[string]bool{}
+
+	for i, lf := range deletedFiles {
+		// Directories that still hold ignored or untracked files are kept
+		if lf.info.IsDir() {
+			entries, err := ioutil.ReadDir(lf.absPath)
+			if err != nil {
+				return fmt.Errorf("Failed to read local directory: %s", err)
+			}
+
+			remaining := 0
+			for _, entry := range entries {
+				if !deleted[filepath.Join(lf.absPath, entry.Name())] {
+					remaining++
+				}
+			}
+
+			if remaining > 0 {
+				self.event(args.Out, Event{
+					Action:  "skip",
+					Path:    lf.absPath,
+					Message: fmt.Sprintf("[%04d/%04d] Skipping %s (directory is not empty)", i+1, deletedCount, lf.absPath),
+				})
+				continue
+			}
+		}
+
+		self.event(args.Out, Event{
+			Action:  "delete",
+			Path:    lf.absPath,
+			Message: fmt.Sprintf("[%04d/%04d] Deleting %s", i+1, deletedCount, lf.absPath),
+		})
+
+		deleted[lf.absPath] = true
+		if args.DryRun {
+			continue
+		}
+
+		err := os.Remove(lf.absPath)
+		if err != nil {
+			return fmt.Errorf("Failed to delete local file: %s", err)
+		}
+
+		files.state.remove(lf.relPath)
+	}
+
+	return nil
+}
+
+func isChildPath(dirPath, path string) bool {
+	return strings.HasPrefix(path, dirPath+string(os.PathSeparator))
+}
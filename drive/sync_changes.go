@@ -0,0 +1,157 @@
+package drive
+
+import (
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"sort"
+)
+
+var syncChangeFields = []googleapi.Field{
+	"nextPageToken",
+	"newStartPageToken",
+	"changes(fileId,removed,file(id,name,parents,md5Checksum,mimeType,size,modifiedTime,trashed,appProperties))",
+}
+
+// Returns all files under the sync root. The remote tree is cached in the
+// sync state together with a changes page token, so that subsequent syncs
+// only need to apply the changes made since the last sync. A full listing
+// is done if there is no cached tree or the changes could not be applied
+func (self *Drive) prepareIncrementalRemoteFiles(rootDir *drive.File, state *syncState, skipInvalid bool) ([]*RemoteFile, []*syncProblem, error) {
+	// Nothing to gain from the changes api if the state is not persisted
+	if state == nil || state.path == "" {
+		files, err := self.listSyncRootFiles(rootDir, "")
+		if err != nil {
+			return nil, nil, err
+		}
+		return prepareRemoteFileTree(rootDir, files, skipInvalid)
+	}
+
+	if state.ChangesToken != "" && state.Remote != nil {
+		err := self.applyRemoteChanges(rootDir, state)
+		if err == nil {
+			return prepareRemoteFileTree(rootDir, state.cachedRemoteFiles(), skipInvalid)
+		}
+	}
+
+	return self.prepareCachedRemoteFiles(rootDir, state, skipInvalid)
+}
+
+func (self *Drive) prepareCachedRemoteFiles(rootDir *drive.File, state *syncState, skipInvalid bool) ([]*RemoteFile, []*syncProblem, error) {
+	// Get the page token before listing so that no changes made
+	// while listing are lost
+	pageToken, err := self.GetChangesStartPageToken(self.syncDriveId(rootDir))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files, err := self.listSyncRootFiles(rootDir, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	state.ChangesToken = pageToken
+	state.Remote = map[string]*drive.File{}
+	for _, f := range files {
+		state.Remote[f.Id] = f
+	}
+
+	return prepareRemoteFileTree(rootDir, files, skipInvalid)
+}
+
+// Applies all changes since the stored page token to the cached remote tree
+func (self *Drive) applyRemoteChanges(rootDir *drive.File, state *syncState) error {
+	// Work on a copy so that the cache is left untouched on failure
+	remote := map[string]*drive.File{}
+	for id, f := range state.Remote {
+		remote[id] = f
+	}
+
+	pageToken := state.ChangesToken
+
+	for {
+		changeList, err := self.listChanges(self.syncDriveId(rootDir), pageToken, 0)
+		if err != nil {
+			return err
+		}
+
+		for _, c := range changeList.Changes {
+			if c.Removed || c.File == nil || c.File.Trashed {
+				delete(remote, c.FileId)
+				continue
+			}
+
+			// Forget files that no longer belongs to this sync root
+			if c.File.AppProperties["syncRootId"] != rootDir.Id {
+				delete(remote, c.FileId)
+				continue
+			}
+
+			// appProperties are kept, later syncs check the syncRootId of cached files
+			remote[c.FileId] = c.File
+		}
+
+		if changeList.NewStartPageToken != "" {
+			pruneOrphans(rootDir.Id, remote)
+			state.ChangesToken = changeList.NewStartPageToken
+			state.Remote = remote
+			return nil
+		}
+
+		pageToken = changeList.NextPageToken
+	}
+}
+
+func (self *Drive) listChanges(driveId, pageToken string, try int) (*drive.ChangeList, error) {
+	changeList, err := self.backend.ListChanges(ChangeQuery{PageToken: pageToken, PageSize: 1000, Fields: syncChangeFields, DriveId: driveId})
+	if err != nil {
+		if isBackendOrRateLimitError(err) && try < MaxErrorRetries {
+			exponentialBackoffSleep(try)
+			try++
+			return self.listChanges(driveId, pageToken, try)
+		} else {
+			return nil, fmt.Errorf("Failed listing changes: %s", err)
+		}
+	}
+
+	return changeList, nil
+}
+
+// Removes files whose parent is no longer part of the tree, i.e. the
+// content of a trashed directory which may not show up as changes itself
+func pruneOrphans(rootId string, remote map[string]*drive.File) {
+	for {
+		pruned := false
+		for id, f := range remote {
+			if len(f.Parents) == 0 {
+				continue
+			}
+
+			parentId := f.Parents[0]
+			if _, ok := remote[parentId]; !ok && parentId != rootId {
+				delete(remote, id)
+				pruned = true
+			}
+		}
+
+		if !pruned {
+			return
+		}
+	}
+}
+
+// Returns the cached remote files sorted by id
+func (self *syncState) cachedRemoteFiles() []*drive.File {
+	var ids []string
+	for id := range self.Remote {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var files []*drive.File
+	for _, id := range ids {
+		files = append(files, self.Remote[id])
+	}
+
+	return files
+}
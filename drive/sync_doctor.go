@@ -0,0 +1,337 @@
+package drive
+
+import (
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// A remote file that can not be placed in the sync tree,
+// i.e. because another file in the same directory has the same name
+type syncProblem struct {
+	file       *drive.File
+	path       string
+	reason     string
+	collisions []*drive.File
+}
+
+func (self *syncProblem) displayPath() string {
+	if self.path == "" {
+		return self.file.Name
+	}
+	return self.path
+}
+
+func (self *syncProblem) err(rootId string) error {
+	return fmt.Errorf("Invalid file %s (%s): %s\nRun 'gdrive sync doctor %s' to repair the sync root or use --skip-invalid to skip invalid files", self.file.Id, self.displayPath(), self.reason, rootId)
+}
+
+// Finds files with name collisions, files without exactly one
+// parent and files whose parent is not part of the sync root
+func findSyncProblems(root *drive.File, files []*drive.File) []*syncProblem {
+	byId := map[string]*drive.File{}
+	for _, f := range files {
+		byId[f.Id] = f
+	}
+
+	var problems []*syncProblem
+	var keys []string
+	groups := map[string][]*drive.File{}
+
+	for _, f := range files {
+		if len(f.Parents) != 1 {
+			reason := "no parent"
+			if len(f.Parents) > 1 {
+				reason = fmt.Sprintf("%d parents", len(f.Parents))
+			}
+			problems = append(problems, &syncProblem{file: f, path: remotePath(f, root.Id, byId), reason: reason})
+			continue
+		}
+
+		parentId := f.Parents[0]
+		if _, ok := byId[parentId]; !ok && parentId != root.Id {
+			problems = append(problems, &syncProblem{file: f, reason: "parent not found"})
+			continue
+		}
+
+		key := parentId + "\x00" + f.Name
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], f)
+	}
+
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) < 2 {
+			continue
+		}
+
+		sort.Sort(byModifiedTime(group))
+		for _, f := range group {
+			problems = append(problems, &syncProblem{
+				file:       f,
+				path:       remotePath(f, root.Id, byId),
+				reason:     fmt.Sprintf("name collision between %d files", len(group)),
+				collisions: group,
+			})
+		}
+	}
+
+	return problems
+}
+
+// Returns the path of f relative to the root by following the first
+// parent, an empty string is returned if the path can not be resolved
+func remotePath(f *drive.File, rootId string, byId map[string]*drive.File) string {
+	names := []string{f.Name}
+
+	for cur := f; len(names) <= len(byId)+1; {
+		if len(cur.Parents) == 0 {
+			return ""
+		}
+
+		if cur.Parents[0] == rootId {
+			// Reverse names to get the path from the root
+			for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+				names[i], names[j] = names[j], names[i]
+			}
+			return filepath.Join(names...)
+		}
+
+		parent, ok := byId[cur.Parents[0]]
+		if !ok {
+			return ""
+		}
+		names = append(names, parent.Name)
+		cur = parent
+	}
+
+	// The parents form a loop
+	return ""
+}
+
+// Returns the files that are not affected by any of the problems,
+// the content of invalid directories is left out as well
+func withoutSyncProblems(root *drive.File, files []*drive.File, problems []*syncProblem) []*drive.File {
+	invalid := map[string]bool{}
+	for _, p := range problems {
+		invalid[p.file.Id] = true
+	}
+
+	for {
+		valid := map[string]bool{root.Id: true}
+		for _, f := range files {
+			if !invalid[f.Id] {
+				valid[f.Id] = true
+			}
+		}
+
+		removed := false
+		for _, f := range files {
+			if !invalid[f.Id] && !valid[f.Parents[0]] {
+				invalid[f.Id] = true
+				removed = true
+			}
+		}
+
+		if !removed {
+			break
+		}
+	}
+
+	var validFiles []*drive.File
+	for _, f := range files {
+		if !invalid[f.Id] {
+			validFiles = append(validFiles, f)
+		}
+	}
+	return validFiles
+}
+
+// Leaves local files at the path of invalid remote files out of the sync,
+// they would otherwise be uploaded as new files or deleted as extraneous
+func (self *syncFiles) skipSyncProblems(problems []*syncProblem) {
+	var paths []string
+
+	for _, p := range problems {
+		self.skipped = append(self.skipped, fmt.Sprintf("%s (%s, id %s)", p.displayPath(), p.reason, p.file.Id))
+		if p.path != "" {
+			paths = append(paths, p.path)
+		}
+	}
+
+	self.skipLocalPaths(paths)
+}
+
+type SyncDoctorArgs struct {
+	Out              io.Writer
+	RootId           string
+	RenameDuplicates bool
+	MergeIdentical   bool
+	DryRun           bool
+}
+
+func (self *Drive) SyncDoctor(args SyncDoctorArgs) error {
+	rootDir, err := self.getSyncRoot(args.RootId)
+	if err != nil {
+		return err
+	}
+
+	files, err := self.listSyncRootFiles(rootDir, "")
+	if err != nil {
+		return err
+	}
+
+	problems := findSyncProblems(rootDir, files)
+	if len(problems) == 0 {
+		self.message(args.Out, "No problems found in %s", rootDir.Name)
+		return nil
+	}
+
+	printSyncProblems(args.Out, problems)
+
+	if !args.RenameDuplicates && !args.MergeIdentical {
+		self.message(args.Out, "\nFound %d problems, use --merge-identical and --rename-duplicates to repair name collisions", len(problems))
+		return nil
+	}
+
+	for _, group := range collisionGroups(problems) {
+		if args.MergeIdentical {
+			group, err = self.mergeIdenticalFiles(group, args)
+			if err != nil {
+				return err
+			}
+		}
+
+		if args.RenameDuplicates {
+			err = self.renameDuplicateFiles(group, files, args)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns every set of colliding files once
+func collisionGroups(problems []*syncProblem) [][]*drive.File {
+	var groups [][]*drive.File
+	seen := map[string]bool{}
+
+	for _, p := range problems {
+		if len(p.collisions) == 0 || seen[p.collisions[0].Id] {
+			continue
+		}
+		seen[p.collisions[0].Id] = true
+		groups = append(groups, p.collisions)
+	}
+
+	return groups
+}
+
+// Trashes files that are identical to an older file with the same name,
+// returns the files that are left
+func (self *Drive) mergeIdenticalFiles(group []*drive.File, args SyncDoctorArgs) ([]*drive.File, error) {
+	var kept []*drive.File
+	first := map[string]*drive.File{}
+
+	for _, f := range group {
+		if !isBinary(f) {
+			kept = append(kept, f)
+			continue
+		}
+
+		key := fmt.Sprintf("%s:%d", f.Md5Checksum, f.Size)
+		original, found := first[key]
+		if !found {
+			first[key] = f
+			kept = append(kept, f)
+			continue
+		}
+
+		self.message(args.Out, "Trashing %s (%s), identical to %s", f.Name, f.Id, original.Id)
+		err := self.deleteRemoteFile(&RemoteFile{file: f}, UploadSyncArgs{DryRun: args.DryRun}, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return kept, nil
+}
+
+// Renames all but the oldest file in the group to a name
+// that is not used by any other file in the directory
+func (self *Drive) renameDuplicateFiles(group []*drive.File, files []*drive.File, args SyncDoctorArgs) error {
+	if len(group) < 2 {
+		return nil
+	}
+
+	parentId := group[0].Parents[0]
+	taken := map[string]bool{}
+	for _, f := range files {
+		if len(f.Parents) > 0 && f.Parents[0] == parentId {
+			taken[f.Name] = true
+		}
+	}
+
+	for _, f := range group[1:] {
+		name := uniqueName(f.Name, taken)
+		taken[name] = true
+
+		self.message(args.Out, "Renaming %s (%s) to %s", f.Name, f.Id, name)
+		_, err := self.moveRemoteFile(&RemoteFile{file: f}, name, parentId, args.DryRun, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns name with a number appended, i.e. report (1).txt
+func uniqueName(name string, taken map[string]bool) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if !taken[candidate] {
+			return candidate
+		}
+	}
+}
+
+func printSyncProblems(out io.Writer, problems []*syncProblem) {
+	w := new(tabwriter.Writer)
+	w.Init(out, 0, 0, 3, ' ', 0)
+
+	fmt.Fprintln(w, "Id\tPath\tProblem")
+	for _, p := range problems {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", p.file.Id, p.displayPath(), p.reason)
+	}
+
+	w.Flush()
+}
+
+type byModifiedTime []*drive.File
+
+func (self byModifiedTime) Len() int {
+	return len(self)
+}
+
+func (self byModifiedTime) Swap(i, j int) {
+	self[i], self[j] = self[j], self[i]
+}
+
+func (self byModifiedTime) Less(i, j int) bool {
+	if self[i].ModifiedTime == self[j].ModifiedTime {
+		return self[i].Id < self[j].Id
+	}
+	return self[i].ModifiedTime < self[j].ModifiedTime
+}
@@ -0,0 +1,105 @@
+package drive
+
+import (
+	"bytes"
+	"github.com/prasmussen/gdrive/drive/drivetest"
+	"google.golang.org/api/drive/v3"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Adds a file to the sync root the way the web ui would add a duplicate
+func addSyncDuplicate(server *drivetest.Server, rootId, parentId, name, content string) string {
+	return server.Insert(&drive.File{
+		Name:          name,
+		Parents:       []string{parentId},
+		AppProperties: map[string]string{"sync": "true", "syncRootId": rootId},
+	}, []byte(content))
+}
+
+func TestSyncSkipInvalid(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	rootId := server.AddFolder(drivetest.RootId, "sync")
+	srcDir := t.TempDir()
+	writeTestFiles(t, srcDir, map[string]string{"a.txt": "a", "b.txt": "b"})
+	uploadSync(t, gdrive, rootId, srcDir, "")
+	addSyncDuplicate(server, rootId, rootId, "a.txt", "other")
+
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"a.txt": "local"})
+
+	args := DownloadSyncArgs{
+		Out:              ioutil.Discard,
+		Path:             dir,
+		RootId:           rootId,
+		DeleteExtraneous: true,
+		Comparer:         testComparer{},
+	}
+
+	err := gdrive.DownloadSync(args)
+	if err == nil || !strings.Contains(err.Error(), "sync doctor") {
+		t.Fatalf("Expected the name collision to fail the sync, got %v", err)
+	}
+
+	out := &bytes.Buffer{}
+	args.Out = out
+	args.SkipInvalid = true
+	if err := gdrive.DownloadSync(args); err != nil {
+		t.Fatal(err)
+	}
+
+	// The local file at the path of the collision is left alone
+	assertLocalFile(t, filepath.Join(dir, "a.txt"), []byte("local"))
+	assertLocalFile(t, filepath.Join(dir, "b.txt"), []byte("b"))
+
+	if !strings.Contains(out.String(), "a.txt (name collision between 2 files") {
+		t.Fatalf("Expected the collision to be reported, got %s", out.String())
+	}
+}
+
+func TestSyncDoctor(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	rootId := server.AddFolder(drivetest.RootId, "sync")
+	srcDir := t.TempDir()
+	writeTestFiles(t, srcDir, map[string]string{"a.txt": "a", "b.txt": "b"})
+	uploadSync(t, gdrive, rootId, srcDir, "")
+	identicalId := addSyncDuplicate(server, rootId, rootId, "a.txt", "a")
+	differentId := addSyncDuplicate(server, rootId, rootId, "b.txt", "other")
+
+	out := &bytes.Buffer{}
+	err := gdrive.SyncDoctor(SyncDoctorArgs{Out: out, RootId: rootId})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{identicalId, differentId} {
+		if !strings.Contains(out.String(), id) {
+			t.Fatalf("Expected %s to be listed, got %s", id, out.String())
+		}
+	}
+
+	err = gdrive.SyncDoctor(SyncDoctorArgs{
+		Out:              ioutil.Discard,
+		RootId:           rootId,
+		MergeIdentical:   true,
+		RenameDuplicates: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f := server.File(identicalId); f == nil || !f.Trashed {
+		t.Fatalf("Expected the identical duplicate to be trashed, got %v", f)
+	}
+
+	if f := server.File(differentId); f == nil || f.Name != "b (1).txt" {
+		t.Fatalf("Expected the duplicate to be renamed, got %v", f)
+	}
+
+	dir := t.TempDir()
+	downloadSync(t, gdrive, rootId, dir, "")
+	assertLocalFile(t, filepath.Join(dir, "a.txt"), []byte("a"))
+	assertLocalFile(t, filepath.Join(dir, "b (1).txt"), []byte("other"))
+}
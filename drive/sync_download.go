@@ -6,9 +6,11 @@ import (
 	"google.golang.org/api/drive/v3"
 	"google.golang.org/api/googleapi"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"time"
 )
 
@@ -22,11 +24,20 @@ type DownloadSyncArgs struct {
 	Timeout          time.Duration
 	Resolution       ConflictResolution
 	Comparer         FileComparer
+	StateDir         string
+	Parallel         int
+	Verify           VerifyPolicy
+	Export           bool
+	ExportMimes      map[string]string
+	Symlinks         SymlinkPolicy
+	SkipInvalid      bool
+	verified         *verifyStats
 }
 
-func (self *Drive) DownloadSync(args DownloadSyncArgs) error {
-	fmt.Fprintln(args.Out, "Starting sync...")
+func (self *Drive) DownloadSync(args DownloadSyncArgs) (err error) {
+	self.message(args.Out, "Starting sync...")
 	started := time.Now()
+	args.verified = &verifyStats{}
 
 	// Get remote root dir
 	rootDir, err := self.getSyncRoot(args.RootId)
@@ -34,39 +45,79 @@ func (self *Drive) DownloadSync(args DownloadSyncArgs) error {
 		return err
 	}
 
-	fmt.Fprintln(args.Out, "Collecting file information...")
-	files, err := self.prepareSyncFiles(args.Path, rootDir, args.Comparer)
+	// Load the state of the previous sync
+	state, err := loadSyncState(args.StateDir, rootDir.Id, args.Path)
 	if err != nil {
 		return err
 	}
 
-	// Find changed files
+	self.message(args.Out, "Collecting file information...")
+	files, err := self.prepareSyncFiles(args.Path, rootDir, args.Comparer, state, args.Symlinks, args.SkipInvalid)
+	if err != nil {
+		return err
+	}
+
+	// Google documents are only synced as exported files
+	for _, msg := range files.prepareDocuments(args.exportMimes()) {
+		self.message(args.Out, "%s", msg)
+	}
+
+	// Find changed and renamed files
 	changedFiles := files.filterChangedRemoteFiles()
+	renamedFiles, missingFiles := files.findRemoteRenames(files.filterMissingLocalFiles())
+	if args.DeleteExtraneous {
+		renamedFiles, missingFiles = files.findRemoteContentRenames(missingFiles, renamedFiles, args.Resolution)
+	}
 
-	fmt.Fprintf(args.Out, "Found %d local files and %d remote files\n", len(files.local), len(files.remote))
+	self.message(args.Out, "Found %d local files and %d remote files", len(files.local), len(files.remote))
 
 	// Ensure that we don't overwrite any local changes
-	if args.Resolution == NoResolution {
+	if args.Resolution == nil {
 		err = ensureNoLocalModifications(changedFiles)
 		if err != nil {
 			return fmt.Errorf("Conflict detected!\nThe following files have changed and the local file are newer than it's remote counterpart:\n\n%s\nNo conflict resolution was given, aborting...", err)
 		}
 	}
 
+	// Conflicts are resolved before anything is transferred,
+	// the resolution may have to ask the user
+	for _, cf := range changedFiles {
+		cf.action, cf.reason = checkLocalConflict(cf, args.Resolution)
+	}
+
+	// Persist the sync state, even if we fail halfway through
+	if !args.DryRun {
+		defer func() {
+			if saveErr := state.save(); err == nil {
+				err = saveErr
+			}
+		}()
+	}
+
+	// Refresh the state of files that are already in sync
+	files.recordUnchanged(changedFiles)
+	files.pruneState()
+
 	// Create missing directories
 	err = self.createMissingLocalDirs(files, args)
 	if err != nil {
 		return err
 	}
 
+	// Move files that have been renamed remotely
+	err = self.moveRenamedLocalFiles(renamedFiles, files, args)
+	if err != nil {
+		return err
+	}
+
 	// Download missing files
-	err = self.downloadMissingFiles(files, args)
+	err = self.downloadMissingFiles(missingFiles, files, args)
 	if err != nil {
 		return err
 	}
 
 	// Download files that has changed
-	err = self.downloadChangedFiles(changedFiles, args)
+	err = self.downloadChangedFiles(changedFiles, files, args)
 	if err != nil {
 		return err
 	}
@@ -78,14 +129,16 @@ func (self *Drive) DownloadSync(args DownloadSyncArgs) error {
 			return err
 		}
 	}
-	fmt.Fprintf(args.Out, "Sync finished in %s\n", time.Since(started))
+	self.printVerifyStats(args.Out, args.verified)
+	self.printSkippedFiles(args.Out, files.skipped)
+	self.message(args.Out, "Sync finished in %s", time.Since(started))
 
 	return nil
 }
 
 func (self *Drive) getSyncRoot(rootId string) (*drive.File, error) {
 	fields := []googleapi.Field{"id", "name", "mimeType", "appProperties"}
-	f, err := self.service.Files.Get(rootId).Fields(fields...).Do()
+	f, err := self.backend.GetFile(rootId, fields...)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to find root dir: %s", err)
 	}
@@ -95,6 +148,11 @@ func (self *Drive) getSyncRoot(rootId string) (*drive.File, error) {
 		return nil, fmt.Errorf("Provided root id is not a directory")
 	}
 
+	err = self.loadSyncDrive(f)
+	if err != nil {
+		return nil, err
+	}
+
 	// Ensure directory is a proper syncRoot
 	if _, ok := f.AppProperties["syncRoot"]; !ok {
 		return nil, fmt.Errorf("Provided id is not a sync root directory")
@@ -108,7 +166,7 @@ func (self *Drive) createMissingLocalDirs(files *syncFiles, args DownloadSyncArg
 	missingCount := len(missingDirs)
 
 	if missingCount > 0 {
-		fmt.Fprintf(args.Out, "\n%d local directories are missing\n", missingCount)
+		self.message(args.Out, "\n%d local directories are missing", missingCount)
 	}
 
 	// Sort directories so that the dirs with the shortest path comes first
@@ -119,84 +177,227 @@ func (self *Drive) createMissingLocalDirs(files *syncFiles, args DownloadSyncArg
 		if err != nil {
 			return fmt.Errorf("Failed to determine local absolute path: %s", err)
 		}
-		fmt.Fprintf(args.Out, "[%04d/%04d] Creating directory %s\n", i+1, missingCount, filepath.Join(filepath.Base(args.Path), rf.relPath))
+		self.event(args.Out, Event{
+			Action:  "mkdir",
+			Path:    filepath.Join(filepath.Base(args.Path), rf.relPath),
+			Message: fmt.Sprintf("[%04d/%04d] Creating directory %s", i+1, missingCount, filepath.Join(filepath.Base(args.Path), rf.relPath)),
+		})
 
 		if args.DryRun {
 			continue
 		}
 
 		os.MkdirAll(absPath, 0775)
+
+		if err = files.state.updateFromDisk(rf.relPath, absPath, rf.file); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func (self *Drive) downloadMissingFiles(files *syncFiles, args DownloadSyncArgs) error {
-	missingFiles := files.filterMissingLocalFiles()
-	missingCount := len(missingFiles)
+func (self *Drive) moveRenamedLocalFiles(renamedFiles []*renamedFile, files *syncFiles, args DownloadSyncArgs) error {
+	renamedCount := len(renamedFiles)
 
-	if missingCount > 0 {
-		fmt.Fprintf(args.Out, "\n%d local files are missing\n", missingCount)
+	if renamedCount > 0 {
+		self.message(args.Out, "\n%d remote files has been renamed", renamedCount)
 	}
 
-	for i, rf := range missingFiles {
-		absPath, err := filepath.Abs(filepath.Join(args.Path, rf.relPath))
+	for i, rn := range renamedFiles {
+		absPath, err := filepath.Abs(filepath.Join(args.Path, rn.remote.relPath))
 		if err != nil {
 			return fmt.Errorf("Failed to determine local absolute path: %s", err)
 		}
-		fmt.Fprintf(args.Out, "[%04d/%04d] Downloading %s -> %s\n", i+1, missingCount, rf.relPath, filepath.Join(filepath.Base(args.Path), rf.relPath))
 
-		err = self.downloadRemoteFile(rf.file.Id, absPath, args, 0)
-		if err != nil {
+		oldPath := rn.local.relPath
+		self.event(args.Out, Event{
+			Action:  "move",
+			Path:    filepath.Join(filepath.Base(args.Path), oldPath),
+			Target:  filepath.Join(filepath.Base(args.Path), rn.remote.relPath),
+			Message: fmt.Sprintf("[%04d/%04d] Moving %s -> %s", i+1, renamedCount, filepath.Join(filepath.Base(args.Path), oldPath), filepath.Join(filepath.Base(args.Path), rn.remote.relPath)),
+		})
+
+		if args.DryRun {
+			continue
+		}
+
+		// Ensure any parent directories exists
+		if err = mkdir(absPath); err != nil {
 			return err
 		}
+
+		err = os.Rename(rn.local.absPath, absPath)
+		if err != nil {
+			return fmt.Errorf("Failed to move local file: %s", err)
+		}
+
+		rn.local.relPath = rn.remote.relPath
+		rn.local.absPath = absPath
+		files.state.remove(oldPath)
+		files.state.update(rn.remote.relPath, rn.local.info, rn.remote.file)
 	}
 
 	return nil
 }
 
-func (self *Drive) downloadChangedFiles(changedFiles []*changedFile, args DownloadSyncArgs) error {
+func (self *Drive) downloadMissingFiles(missingFiles []*RemoteFile, files *syncFiles, args DownloadSyncArgs) error {
+	missingCount := len(missingFiles)
+
+	if missingCount > 0 {
+		self.message(args.Out, "\n%d local files are missing", missingCount)
+	}
+
+	out, progress, done := prepareParallelOutput(args.Parallel, args.Out, args.Progress, sumRemoteSize(missingFiles))
+	defer done()
+
+	return runParallel(args.Parallel, missingCount, func(i int) error {
+		rf := missingFiles[i]
+
+		absPath, err := filepath.Abs(filepath.Join(args.Path, rf.relPath))
+		if err != nil {
+			return fmt.Errorf("Failed to determine local absolute path: %s", err)
+		}
+		self.event(out, Event{
+			Action:  "download",
+			Path:    rf.relPath,
+			Target:  filepath.Join(filepath.Base(args.Path), rf.relPath),
+			Message: fmt.Sprintf("[%04d/%04d] Downloading %s -> %s", i+1, missingCount, rf.relPath, filepath.Join(filepath.Base(args.Path), rf.relPath)),
+		})
+
+		workerArgs := args
+		workerArgs.Out = out
+		workerArgs.Progress = progress
+
+		err = self.downloadSyncFile(rf, absPath, workerArgs)
+		if err != nil || args.DryRun {
+			return err
+		}
+
+		return files.state.updateFromDisk(rf.relPath, absPath, rf.file)
+	})
+}
+
+func (self *Drive) downloadChangedFiles(changedFiles []*changedFile, files *syncFiles, args DownloadSyncArgs) error {
 	changedCount := len(changedFiles)
 
 	if changedCount > 0 {
-		fmt.Fprintf(args.Out, "\n%d remote files has changed\n", changedCount)
+		self.message(args.Out, "\n%d remote files has changed", changedCount)
 	}
 
-	for i, cf := range changedFiles {
-		if skip, reason := checkLocalConflict(cf, args.Resolution); skip {
-			fmt.Fprintf(args.Out, "[%04d/%04d] Skipping %s (%s)\n", i+1, changedCount, cf.remote.relPath, reason)
-			continue
+	out, progress, done := prepareParallelOutput(args.Parallel, args.Out, args.Progress, sumChangedRemoteSize(changedFiles))
+	defer done()
+
+	return runParallel(args.Parallel, changedCount, func(i int) error {
+		cf := changedFiles[i]
+
+		if cf.action == SkipConflict || cf.action == UseLocal {
+			self.event(out, Event{
+				Action:  "skip",
+				Path:    cf.remote.relPath,
+				Message: fmt.Sprintf("[%04d/%04d] Skipping %s (%s)", i+1, changedCount, cf.remote.relPath, cf.reason),
+			})
+			return nil
 		}
 
 		absPath, err := filepath.Abs(filepath.Join(args.Path, cf.remote.relPath))
 		if err != nil {
 			return fmt.Errorf("Failed to determine local absolute path: %s", err)
 		}
-		fmt.Fprintf(args.Out, "[%04d/%04d] Downloading %s -> %s\n", i+1, changedCount, cf.remote.relPath, filepath.Join(filepath.Base(args.Path), cf.remote.relPath))
 
-		err = self.downloadRemoteFile(cf.remote.file.Id, absPath, args, 0)
-		if err != nil {
+		// Keep the local copy under a name of its own
+		if cf.action == UseBoth {
+			if _, err := self.renameLocalConflict(cf, out, args.DryRun); err != nil {
+				return err
+			}
+		}
+		self.event(out, Event{
+			Action:  "download",
+			Path:    cf.remote.relPath,
+			Target:  filepath.Join(filepath.Base(args.Path), cf.remote.relPath),
+			Message: fmt.Sprintf("[%04d/%04d] Downloading %s -> %s", i+1, changedCount, cf.remote.relPath, filepath.Join(filepath.Base(args.Path), cf.remote.relPath)),
+		})
+
+		workerArgs := args
+		workerArgs.Out = out
+		workerArgs.Progress = progress
+
+		err = self.downloadSyncFile(cf.remote, absPath, workerArgs)
+		if err != nil || args.DryRun {
 			return err
 		}
+
+		return files.state.updateFromDisk(cf.remote.relPath, absPath, cf.remote.file)
+	})
+}
+
+// Returns the export mimes of the documents, nil if documents should not be exported
+func (self DownloadSyncArgs) exportMimes() map[string]string {
+	if !self.Export {
+		return nil
 	}
 
-	return nil
+	if self.ExportMimes == nil {
+		return map[string]string{}
+	}
+
+	return self.ExportMimes
+}
+
+// Documents are exported and symlinks are recreated,
+// all other files are downloaded
+func (self *Drive) downloadSyncFile(rf *RemoteFile, fpath string, args DownloadSyncArgs) error {
+	if rf.isSymlink() {
+		return createLocalSymlink(rf.linkTarget(), fpath, args.DryRun)
+	}
+
+	if rf.exportMime == "" {
+		return self.downloadRemoteFile(rf.file, fpath, args, 0)
+	}
+
+	if args.DryRun {
+		return nil
+	}
+
+	// The export is named after the document, which gives the same path
+	// as the relative path of the exported file
+	_, _, err := self.downloadExport(rf.file, DownloadArgs{
+		Out:         ioutil.Discard,
+		Progress:    args.Progress,
+		Path:        filepath.Dir(fpath),
+		Force:       true,
+		Timeout:     args.Timeout,
+		Verify:      args.Verify,
+		ExportMimes: map[string]string{rf.file.MimeType: rf.exportMime},
+		verified:    args.verified,
+	})
+	if err != nil {
+		return err
+	}
+
+	return setLocalModTime(fpath, rf.file.ModifiedTime)
 }
 
-func (self *Drive) downloadRemoteFile(id, fpath string, args DownloadSyncArgs, try int) error {
+func (self *Drive) downloadRemoteFile(f *drive.File, fpath string, args DownloadSyncArgs, try int) error {
 	if args.DryRun {
 		return nil
 	}
 
+	// Download to tmp file
+	tmpPath := fpath + ".incomplete"
+
+	// Continue from where a previous download was interrupted
+	offset := incompleteOffset(tmpPath, f.Size)
+
 	// Get timeout reader wrapper and context
 	timeoutReaderWrapper, ctx := getTimeoutReaderWrapperContext(args.Timeout)
 
-	res, err := self.service.Files.Get(id).Context(ctx).Download()
+	body, offset, err := self.backend.Download(ctx, f.Id, offset)
 	if err != nil {
 		if isBackendOrRateLimitError(err) && try < MaxErrorRetries {
 			exponentialBackoffSleep(try)
 			try++
-			return self.downloadRemoteFile(id, fpath, args, try)
+			return self.downloadRemoteFile(f, fpath, args, try)
 		} else if isTimeoutError(err) {
 			return fmt.Errorf("Failed to download file: timeout, no data was transferred for %v", args.Timeout)
 		} else {
@@ -205,10 +406,10 @@ func (self *Drive) downloadRemoteFile(id, fpath string, args DownloadSyncArgs, t
 	}
 
 	// Close body on function exit
-	defer res.Body.Close()
+	defer body.Close()
 
 	// Wrap response body in progress reader
-	progressReader := getProgressReader(res.Body, args.Progress, res.ContentLength)
+	progressReader := getProgressReader(body, args.Progress, f.Size-offset)
 
 	// Wrap reader in timeout reader
 	reader := timeoutReaderWrapper(progressReader)
@@ -218,25 +419,22 @@ func (self *Drive) downloadRemoteFile(id, fpath string, args DownloadSyncArgs, t
 		return err
 	}
 
-	// Download to tmp file
-	tmpPath := fpath + ".incomplete"
-
-	// Create new file
-	outFile, err := os.Create(tmpPath)
+	// Create new file or continue on the incomplete one
+	outFile, hasher, err := openIncompleteFile(tmpPath, offset)
 	if err != nil {
-		return fmt.Errorf("Unable to create local file: %s", err)
+		return err
 	}
 
-	// Save file to disk
-	_, err = io.Copy(outFile, reader)
+	// Save file to disk, the incomplete file is kept on failure
+	// so that the download can be resumed by the next sync
+	_, err = io.Copy(io.MultiWriter(outFile, hasher), reader)
 	if err != nil {
 		outFile.Close()
 		if try < MaxErrorRetries {
 			exponentialBackoffSleep(try)
 			try++
-			return self.downloadRemoteFile(id, fpath, args, try)
+			return self.downloadRemoteFile(f, fpath, args, try)
 		} else {
-			os.Remove(tmpPath)
 			return fmt.Errorf("Download was interrupted: %s", err)
 		}
 	}
@@ -244,8 +442,66 @@ func (self *Drive) downloadRemoteFile(id, fpath string, args DownloadSyncArgs, t
 	// Close file
 	outFile.Close()
 
-	// Rename tmp file to proper filename
-	return os.Rename(tmpPath, fpath)
+	// The incomplete file was probably from an older version of the file,
+	// start over if the resumed download fails verification
+	if offset > 0 && f.Md5Checksum != "" && formatMd5(hasher) != f.Md5Checksum {
+		os.Remove(tmpPath)
+		return self.downloadRemoteFile(f, fpath, args, try)
+	}
+
+	// Verify and rename tmp file to proper filename
+	err = finishDownload(tmpPath, fpath, hasher, f.Md5Checksum, args.Verify, args.verified)
+	if err != nil {
+		return err
+	}
+
+	return restoreFileInfo(fpath, f)
+}
+
+// Gives the local file the modification time of the remote file,
+// and the permissions it had when it was uploaded
+func restoreFileInfo(fpath string, f *drive.File) error {
+	if mode, err := strconv.ParseUint(f.AppProperties["syncMode"], 8, 32); err == nil {
+		if err := os.Chmod(fpath, os.FileMode(mode).Perm()); err != nil {
+			return fmt.Errorf("Failed to set file mode: %s", err)
+		}
+	}
+
+	return setLocalModTime(fpath, f.ModifiedTime)
+}
+
+// Permissions are stored as octal, i.e. 0644
+func formatFileMode(info os.FileInfo) string {
+	return fmt.Sprintf("%04o", info.Mode().Perm())
+}
+
+// Moves the local file of a conflict out of the way, the renamed
+// copy is not part of the sync until it is picked up by a later sync
+func (self *Drive) renameLocalConflict(cf *changedFile, out io.Writer, dryRun bool) (*LocalFile, error) {
+	relPath := filepath.Join(filepath.Dir(cf.local.relPath), conflictName(cf.local.info.Name(), time.Now()))
+	absPath := filepath.Join(filepath.Dir(cf.local.absPath), filepath.Base(relPath))
+
+	self.event(out, Event{
+		Action:  "conflict",
+		Path:    cf.local.relPath,
+		Target:  relPath,
+		Message: fmt.Sprintf("Renaming conflicting local file %s -> %s", cf.local.relPath, relPath),
+	})
+
+	if dryRun {
+		return &LocalFile{absPath: absPath, relPath: relPath, info: cf.local.info}, nil
+	}
+
+	if err := os.Rename(cf.local.absPath, absPath); err != nil {
+		return nil, fmt.Errorf("Failed to rename conflicting file: %s", err)
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to stat local file: %s", err)
+	}
+
+	return &LocalFile{absPath: absPath, relPath: relPath, info: info}, nil
 }
 
 func (self *Drive) deleteExtraneousLocalFiles(files *syncFiles, args DownloadSyncArgs) error {
@@ -253,14 +509,30 @@ func (self *Drive) deleteExtraneousLocalFiles(files *syncFiles, args DownloadSyn
 	extraneousCount := len(extraneousFiles)
 
 	if extraneousCount > 0 {
-		fmt.Fprintf(args.Out, "\n%d local files are extraneous\n", extraneousCount)
+		self.message(args.Out, "\n%d local files are extraneous", extraneousCount)
 	}
 
 	// Sort files so that the files with the longest path comes first
 	sort.Sort(sort.Reverse(byLocalPathLength(extraneousFiles)))
 
+	var skipped []string
+
 	for i, lf := range extraneousFiles {
-		fmt.Fprintf(args.Out, "[%04d/%04d] Deleting %s\n", i+1, extraneousCount, lf.absPath)
+		if skip, reason := checkExtraneousLocal(lf, files, skipped, args.Resolution); skip {
+			self.event(args.Out, Event{
+				Action:  "skip",
+				Path:    lf.absPath,
+				Message: fmt.Sprintf("[%04d/%04d] Skipping %s (%s)", i+1, extraneousCount, lf.absPath, reason),
+			})
+			skipped = append(skipped, lf.relPath)
+			continue
+		}
+
+		self.event(args.Out, Event{
+			Action:  "delete",
+			Path:    lf.absPath,
+			Message: fmt.Sprintf("[%04d/%04d] Deleting %s", i+1, extraneousCount, lf.absPath),
+		})
 
 		if args.DryRun {
 			continue
@@ -270,50 +542,52 @@ func (self *Drive) deleteExtraneousLocalFiles(files *syncFiles, args DownloadSyn
 		if err != nil {
 			return fmt.Errorf("Failed to delete local file: %s", err)
 		}
+
+		files.state.remove(lf.relPath)
 	}
 
 	return nil
 }
 
-func checkLocalConflict(cf *changedFile, resolution ConflictResolution) (bool, string) {
-	// No conflict unless local file was last modified
-	if cf.compareModTime() != LocalLastModified {
-		return false, ""
-	}
-
-	// Don't skip if want to keep the remote file
-	if resolution == KeepRemote {
-		return false, ""
+// Decides whether a changed file is downloaded, the remote file is
+// used unless the local file has been modified as well
+func checkLocalConflict(cf *changedFile, resolution ConflictResolution) (ConflictAction, string) {
+	// No conflict unless local file was modified
+	if !cf.localModified() {
+		return UseRemote, ""
 	}
 
-	// Skip if we want to keep the local file
-	if resolution == KeepLocal {
-		return true, "conflicting file, keeping local file"
+	// Default to being non-destructive and skip the file
+	if resolution == nil {
+		return SkipConflict, "conflicting file, no conflict resolution given"
 	}
 
-	if resolution == KeepLargest {
-		largest := cf.compareSize()
+	action, reason := resolution.Resolve(cf.local, cf.remote)
+	return action, "conflicting file, " + reason
+}
 
-		// Skip if the local file is largest
-		if largest == LocalLargestSize {
-			return true, "conflicting file, local file is largest, keeping local"
+// Extraneous local files are only deleted if they were deleted remotely,
+// files that were created or changed locally since the last sync are kept
+// unless the remote side should win
+func checkExtraneousLocal(lf *LocalFile, files *syncFiles, skipped []string, resolution ConflictResolution) (bool, string) {
+	// Keep directories that still hold skipped files
+	for _, relPath := range skipped {
+		if isChildPath(lf.relPath, relPath) {
+			return true, "directory contains local changes"
 		}
+	}
 
-		// Don't skip if the remote file is largest
-		if largest == RemoteLargestSize {
-			return false, ""
-		}
+	// Without any previous state everything extraneous is deleted
+	if files.state.isEmpty() || resolution == KeepRemote {
+		return false, ""
+	}
 
-		// Keep local if both files have the same size
-		if largest == EqualSize {
-			return true, "conflicting file, file sizes are equal, keeping local"
-		}
+	base, synced := files.state.get(lf.relPath)
+	if synced && !base.localChanged(lf) {
+		return false, ""
 	}
 
-	// The conditionals above should cover all cases,
-	// unless the programmer did something wrong,
-	// in which case we default to being non-destructive and skip the file
-	return true, "conflicting file, unhandled case"
+	return true, "created or changed locally since last sync"
 }
 
 func ensureNoLocalModifications(files []*changedFile) error {
@@ -324,5 +598,5 @@ func ensureNoLocalModifications(files []*changedFile) error {
 
 	buffer := bytes.NewBufferString("")
 	formatConflicts(conflicts, buffer)
-	return fmt.Errorf(buffer.String())
+	return fmt.Errorf("%s", buffer.String())
 }
@@ -16,15 +16,27 @@ type ListSyncArgs struct {
 
 func (self *Drive) ListSync(args ListSyncArgs) error {
 	listArgs := listAllFilesArgs{
-		query:  "appProperties has {key='syncRoot' and value='true'}",
+		query:  "appProperties has {key='syncRoot' and value='true'} and trashed = false",
 		fields: []googleapi.Field{"nextPageToken", "files(id,name,mimeType,createdTime)"},
 	}
 	files, err := self.listAllFiles(listArgs)
 	if err != nil {
 		return err
 	}
-	printSyncDirectories(files, args)
-	return nil
+	records := make([]FileRecord, 0, len(files))
+	for _, f := range files {
+		records = append(records, newFileRecord(f, ""))
+	}
+
+	return self.render(args.Out, Result{
+		Value:      records,
+		Header:     fileRecordHeader,
+		Rows:       fileRecordRows(records),
+		SkipHeader: args.SkipHeader,
+		Table: func(w io.Writer) {
+			printSyncDirectories(w, files, args)
+		},
+	})
 }
 
 type ListRecursiveSyncArgs struct {
@@ -47,13 +59,30 @@ func (self *Drive) ListRecursiveSync(args ListRecursiveSyncArgs) error {
 		return err
 	}
 
-	printSyncDirContent(files, args)
-	return nil
+	if args.SortOrder == "" {
+		// Sort files by path
+		sort.Sort(byRemotePath(files))
+	}
+
+	records := make([]FileRecord, 0, len(files))
+	for _, rf := range files {
+		records = append(records, newFileRecord(rf.file, rf.relPath))
+	}
+
+	return self.render(args.Out, Result{
+		Value:      records,
+		Header:     fileRecordHeader,
+		Rows:       fileRecordRows(records),
+		SkipHeader: args.SkipHeader,
+		Table: func(w io.Writer) {
+			printSyncDirContent(w, files, args)
+		},
+	})
 }
 
-func printSyncDirectories(files []*drive.File, args ListSyncArgs) {
+func printSyncDirectories(out io.Writer, files []*drive.File, args ListSyncArgs) {
 	w := new(tabwriter.Writer)
-	w.Init(args.Out, 0, 0, 3, ' ', 0)
+	w.Init(out, 0, 0, 3, ' ', 0)
 
 	if !args.SkipHeader {
 		fmt.Fprintln(w, "Id\tName\tCreated")
@@ -70,14 +99,9 @@ func printSyncDirectories(files []*drive.File, args ListSyncArgs) {
 	w.Flush()
 }
 
-func printSyncDirContent(files []*RemoteFile, args ListRecursiveSyncArgs) {
-	if args.SortOrder == "" {
-		// Sort files by path
-		sort.Sort(byRemotePath(files))
-	}
-
+func printSyncDirContent(out io.Writer, files []*RemoteFile, args ListRecursiveSyncArgs) {
 	w := new(tabwriter.Writer)
-	w.Init(args.Out, 0, 0, 3, ' ', 0)
+	w.Init(out, 0, 0, 3, ' ', 0)
 
 	if !args.SkipHeader {
 		fmt.Fprintln(w, "Id\tPath\tType\tSize\tModified")
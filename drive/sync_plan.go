@@ -0,0 +1,488 @@
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const syncPlanVersion = 2
+
+// syncPlan is the exact list of actions an upload sync would perform,
+// it can be reviewed and later executed with SyncApply
+type syncPlan struct {
+	Version          int                `json:"version"`
+	RootId           string             `json:"rootId"`
+	Path             string             `json:"path"`
+	Created          time.Time          `json:"created"`
+	DeleteExtraneous bool               `json:"deleteExtraneous"`
+	SkipInvalid      bool               `json:"skipInvalid,omitempty"`
+	Actions          []*syncPlanAction  `json:"actions"`
+	Skipped          []*syncPlanSkipped `json:"skipped,omitempty"`
+}
+
+type syncPlanAction struct {
+	Action string `json:"action"`
+
+	// Path relative to the sync root, for moves this is the new path
+	Path    string `json:"path"`
+	OldPath string `json:"oldPath,omitempty"`
+
+	// Remote file that is moved, updated or deleted, the version is
+	// the md5 of the content or the modified time of documents
+	Id            string `json:"id,omitempty"`
+	RemoteVersion string `json:"remoteVersion,omitempty"`
+
+	// Local source file
+	Size     int64  `json:"size"`
+	Modified int64  `json:"modified,omitempty"`
+	Md5      string `json:"md5,omitempty"`
+}
+
+type syncPlanSkipped struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+func (self *Drive) writeUploadPlan(path string, files *syncFiles, renamedFiles []*renamedFile, missingFiles []*LocalFile, changedFiles []*changedFile, args UploadSyncArgs) error {
+	absPath, err := filepath.Abs(args.Path)
+	if err != nil {
+		return fmt.Errorf("Failed to determine local absolute path: %s", err)
+	}
+
+	plan := &syncPlan{
+		Version:          syncPlanVersion,
+		RootId:           files.root.file.Id,
+		Path:             absPath,
+		Created:          time.Now(),
+		DeleteExtraneous: args.DeleteExtraneous,
+		SkipInvalid:      args.SkipInvalid,
+	}
+
+	// Directories with the shortest path comes first
+	missingDirs := files.filterMissingRemoteDirs()
+	sort.Sort(byLocalPathLength(missingDirs))
+
+	for _, lf := range missingDirs {
+		plan.Actions = append(plan.Actions, &syncPlanAction{Action: "mkdir", Path: lf.relPath})
+	}
+
+	for _, rn := range renamedFiles {
+		action := newLocalPlanAction("move", rn.local)
+		action.OldPath = rn.remote.relPath
+		action.Id = rn.remote.file.Id
+		action.RemoteVersion = rn.remote.version()
+		plan.Actions = append(plan.Actions, action)
+	}
+
+	for _, lf := range missingFiles {
+		action := newLocalPlanAction("upload", lf)
+		if action.Md5, err = localMd5(lf.absPath); err != nil {
+			return err
+		}
+		plan.Actions = append(plan.Actions, action)
+	}
+
+	for _, cf := range changedFiles {
+		if cf.action == SkipConflict || cf.action == UseRemote {
+			plan.Skipped = append(plan.Skipped, &syncPlanSkipped{cf.local.relPath, cf.reason})
+			continue
+		}
+
+		// Plans only hold actions on files that exist when the plan is made
+		if cf.action == UseBoth {
+			plan.Skipped = append(plan.Skipped, &syncPlanSkipped{cf.local.relPath, "conflicting file, keeping both files is not supported by plans"})
+			continue
+		}
+
+		action := newLocalPlanAction("update", cf.local)
+		action.Id = cf.remote.file.Id
+		action.RemoteVersion = cf.remote.version()
+		if action.Md5, err = localMd5(cf.local.absPath); err != nil {
+			return err
+		}
+		plan.Actions = append(plan.Actions, action)
+	}
+
+	if args.DeleteExtraneous {
+		// Files with the longest path comes first
+		extraneousFiles := files.filterExtraneousRemoteFiles()
+		sort.Sort(sort.Reverse(byRemotePathLength(extraneousFiles)))
+
+		var skipped []string
+		for _, rf := range extraneousFiles {
+			if skip, reason := checkExtraneousRemote(rf, files, skipped, args.Resolution); skip {
+				plan.Skipped = append(plan.Skipped, &syncPlanSkipped{rf.relPath, reason})
+				skipped = append(skipped, rf.relPath)
+				continue
+			}
+
+			plan.Actions = append(plan.Actions, &syncPlanAction{
+				Action:        "delete",
+				Path:          rf.relPath,
+				Id:            rf.file.Id,
+				RemoteVersion: rf.version(),
+				Size:          rf.file.Size,
+			})
+		}
+	}
+
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Failed to encode plan: %s", err)
+	}
+
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("Failed to write plan: %s", err)
+	}
+
+	self.message(args.Out, "Wrote plan with %d actions to %s", len(plan.Actions), path)
+	return nil
+}
+
+func localMd5(path string) (string, error) {
+	hasher, err := md5File(path)
+	if err != nil {
+		return "", err
+	}
+	return formatMd5(hasher), nil
+}
+
+func newLocalPlanAction(action string, lf *LocalFile) *syncPlanAction {
+	return &syncPlanAction{
+		Action:   action,
+		Path:     lf.relPath,
+		Size:     lf.Size(),
+		Modified: lf.Modified().UnixNano(),
+	}
+}
+
+type SyncApplyArgs struct {
+	Out        io.Writer
+	Progress   io.Writer
+	PlanPath   string
+	ChunkSize  int64
+	Timeout    time.Duration
+	StateDir   string
+	SessionDir string
+	Verify     VerifyPolicy
+	Permanent  bool
+}
+
+func (self *Drive) SyncApply(args SyncApplyArgs) (err error) {
+	if args.ChunkSize > intMax()-1 {
+		return fmt.Errorf("Chunk size is to big, max chunk size for this computer is %d", intMax()-1)
+	}
+
+	plan, err := readSyncPlan(args.PlanPath)
+	if err != nil {
+		return err
+	}
+
+	self.message(args.Out, "Applying plan from %s...", plan.Created.Format(time.RFC3339))
+	started := time.Now()
+
+	rootDir, err := self.getSyncRoot(plan.RootId)
+	if err != nil {
+		return err
+	}
+
+	// Invalid remote files are skipped if they were skipped when the plan was made
+	remoteList, err := self.listSyncRootFiles(rootDir, "")
+	if err != nil {
+		return err
+	}
+
+	remoteFiles, _, err := prepareRemoteFileTree(rootDir, remoteList, plan.SkipInvalid)
+	if err != nil {
+		return err
+	}
+
+	files := &syncFiles{
+		root:   &RemoteFile{file: rootDir},
+		remote: remoteFiles,
+	}
+
+	// Refuse to do anything if either side has changed since the plan was made
+	if err := plan.check(files); err != nil {
+		return fmt.Errorf("The plan is outdated: %s\nCreate a new plan and try again", err)
+	}
+
+	state, err := loadSyncState(args.StateDir, rootDir.Id, plan.Path)
+	if err != nil {
+		return err
+	}
+	files.state = state
+
+	// Persist the sync state, even if we fail halfway through
+	defer func() {
+		if saveErr := state.save(); err == nil {
+			err = saveErr
+		}
+	}()
+
+	syncArgs := UploadSyncArgs{
+		Out:        args.Out,
+		Progress:   args.Progress,
+		Path:       plan.Path,
+		RootId:     rootDir.Id,
+		ChunkSize:  args.ChunkSize,
+		Timeout:    args.Timeout,
+		SessionDir: args.SessionDir,
+		Verify:     args.Verify,
+		Permanent:  args.Permanent,
+		verified:   &verifyStats{},
+	}
+
+	actionCount := len(plan.Actions)
+	for i, action := range plan.Actions {
+		self.event(args.Out, Event{
+			Action:  action.Action,
+			Path:    action.Path,
+			Id:      action.Id,
+			Size:    action.Size,
+			Message: fmt.Sprintf("[%04d/%04d] %s", i+1, actionCount, action.describe()),
+		})
+
+		if err := self.applySyncPlanAction(action, files, syncArgs); err != nil {
+			return err
+		}
+	}
+
+	self.printVerifyStats(args.Out, syncArgs.verified)
+	self.message(args.Out, "Plan applied in %s", time.Since(started))
+	return nil
+}
+
+func readSyncPlan(path string) (*syncPlan, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open plan: %s", err)
+	}
+
+	// Close file on function exit
+	defer f.Close()
+
+	plan := &syncPlan{}
+	if err := json.NewDecoder(f).Decode(plan); err != nil {
+		return nil, fmt.Errorf("Failed to read plan %s: %s", path, err)
+	}
+
+	if plan.Version != syncPlanVersion {
+		return nil, fmt.Errorf("Unsupported plan version %d", plan.Version)
+	}
+
+	return plan, nil
+}
+
+// Ensures that every file the plan touches looks exactly like it did when the plan was made
+func (self *syncPlan) check(files *syncFiles) error {
+	remoteById := map[string]*RemoteFile{}
+	for _, rf := range files.remote {
+		remoteById[rf.file.Id] = rf
+	}
+
+	deleted := map[string]bool{}
+	for _, action := range self.Actions {
+		if action.Action == "delete" {
+			deleted[action.Path] = true
+		}
+	}
+
+	for _, action := range self.Actions {
+		absPath := filepath.Join(self.Path, action.Path)
+
+		switch action.Action {
+		case "mkdir":
+			info, err := os.Stat(absPath)
+			if err != nil || !info.IsDir() {
+				return fmt.Errorf("local directory %s no longer exists", action.Path)
+			}
+		case "move", "upload", "update":
+			if err := action.checkLocal(absPath); err != nil {
+				return err
+			}
+		case "delete":
+			if _, err := os.Lstat(absPath); err == nil {
+				return fmt.Errorf("local file %s has been created", action.Path)
+			}
+		default:
+			return fmt.Errorf("unknown action '%s'", action.Action)
+		}
+
+		switch action.Action {
+		case "mkdir", "upload":
+			if _, found := files.findRemoteByPath(action.Path); found {
+				return fmt.Errorf("remote file %s has been created", action.Path)
+			}
+		case "move", "update", "delete":
+			if err := action.checkRemote(remoteById); err != nil {
+				return err
+			}
+		}
+
+		// Directories are only deleted if everything in them is deleted as well
+		if action.Action == "delete" && isDir(remoteById[action.Id].file) {
+			for _, rf := range files.remote {
+				if isChildPath(action.Path, rf.relPath) && !deleted[rf.relPath] {
+					return fmt.Errorf("remote directory %s is no longer empty", action.Path)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (self *syncPlanAction) checkLocal(absPath string) error {
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return fmt.Errorf("local file %s no longer exists", self.Path)
+	}
+
+	if info.Size() != self.Size || info.ModTime().UnixNano() != self.Modified {
+		return fmt.Errorf("local file %s has changed", self.Path)
+	}
+
+	return nil
+}
+
+func (self *syncPlanAction) checkRemote(remoteById map[string]*RemoteFile) error {
+	relPath := self.Path
+	if self.Action == "move" {
+		relPath = self.OldPath
+	}
+
+	rf, found := remoteById[self.Id]
+	if !found {
+		return fmt.Errorf("remote file %s no longer exists", relPath)
+	}
+
+	if rf.relPath != relPath || rf.version() != self.RemoteVersion {
+		return fmt.Errorf("remote file %s has changed", relPath)
+	}
+
+	return nil
+}
+
+func (self *syncPlanAction) describe() string {
+	switch self.Action {
+	case "mkdir":
+		return fmt.Sprintf("Creating directory %s", self.Path)
+	case "move":
+		return fmt.Sprintf("Moving %s -> %s", self.OldPath, self.Path)
+	case "upload":
+		return fmt.Sprintf("Uploading %s", self.Path)
+	case "update":
+		return fmt.Sprintf("Updating %s", self.Path)
+	case "delete":
+		return fmt.Sprintf("Deleting %s", self.Path)
+	}
+	return self.Action
+}
+
+func (self *Drive) applySyncPlanAction(action *syncPlanAction, files *syncFiles, args UploadSyncArgs) error {
+	absPath := filepath.Join(args.Path, action.Path)
+
+	var parentId string
+	if action.Action != "delete" && action.Action != "update" {
+		parentPath := parentFilePath(action.Path)
+		parent, ok := files.findRemoteByPath(parentPath)
+		if !ok {
+			return fmt.Errorf("Could not find remote directory with path '%s'", parentPath)
+		}
+		parentId = parent.file.Id
+	}
+
+	var lf *LocalFile
+	if action.Action != "delete" {
+		info, err := os.Stat(absPath)
+		if err != nil {
+			return fmt.Errorf("Failed to stat local file: %s", err)
+		}
+		lf = &LocalFile{absPath: absPath, relPath: action.Path, info: info}
+	}
+
+	switch action.Action {
+	case "mkdir":
+		f, err := self.createMissingRemoteDir(createMissingRemoteDirArgs{
+			name:     lf.info.Name(),
+			parentId: parentId,
+			rootId:   args.RootId,
+		})
+		if err != nil {
+			return err
+		}
+		files.remote = append(files.remote, &RemoteFile{relPath: action.Path, file: f})
+		files.state.update(action.Path, lf.info, f)
+
+	case "move":
+		rf, _ := files.findRemoteByPath(action.OldPath)
+		f, err := self.moveRemoteFile(rf, lf.info.Name(), parentId, false, 0)
+		if err != nil {
+			return err
+		}
+		rf.relPath = action.Path
+		rf.file = f
+		files.state.remove(action.OldPath)
+		files.state.update(action.Path, lf.info, f)
+
+	case "upload":
+		f, err := self.uploadMissingFile(parentId, lf, args, 0)
+		if err != nil {
+			return err
+		}
+		if err := action.checkUploaded(f); err != nil {
+			return err
+		}
+		files.remote = append(files.remote, &RemoteFile{relPath: action.Path, file: f})
+		files.state.update(action.Path, lf.info, f)
+
+	case "update":
+		rf, _ := files.findRemoteByPath(action.Path)
+		f, err := self.updateChangedFile(&changedFile{local: lf, remote: rf}, args, 0)
+		if err != nil {
+			return err
+		}
+		if err := action.checkUploaded(f); err != nil {
+			return err
+		}
+		files.state.update(action.Path, lf.info, f)
+
+	case "delete":
+		rf, _ := files.findRemoteByPath(action.Path)
+
+		// Files in the directory that are part of the plan are deleted
+		// first, anything else has been added since the plan was made
+		if isDir(rf.file) {
+			isEmpty, err := self.dirIsEmpty(rf.file)
+			if err != nil {
+				return err
+			}
+			if !isEmpty {
+				return fmt.Errorf("The plan is outdated: remote directory %s is no longer empty\nCreate a new plan and try again", action.Path)
+			}
+		}
+
+		if err := self.deleteRemoteFile(rf, args, 0); err != nil {
+			return err
+		}
+		files.state.remove(action.Path)
+	}
+
+	return nil
+}
+
+// The uploaded content must be exactly what was reviewed in the plan
+func (self *syncPlanAction) checkUploaded(f *drive.File) error {
+	if f.Md5Checksum != "" && f.Md5Checksum != self.Md5 {
+		return fmt.Errorf("Local file %s changed while it was uploaded, expected md5 %s got %s", self.Path, self.Md5, f.Md5Checksum)
+	}
+	return nil
+}
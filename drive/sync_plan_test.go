@@ -0,0 +1,139 @@
+package drive
+
+import (
+	"github.com/prasmussen/gdrive/drive/drivetest"
+	"google.golang.org/api/drive/v3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Syncs the initial files and changes the local directory, so that
+// the plan uploads a.txt, updates b.txt and deletes extra.txt and old/
+func prepareSyncPlan(t *testing.T) (*Drive, *drivetest.Server, string, string, string) {
+	gdrive, server := newTestDrive(t)
+	rootId := server.AddFolder(drivetest.RootId, "sync")
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"b.txt":     "b",
+		"extra.txt": "extra",
+		"old/c.txt": "c",
+	})
+	uploadSync(t, gdrive, rootId, dir, "")
+
+	if err := os.Remove(filepath.Join(dir, "extra.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, "old")); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFiles(t, dir, map[string]string{
+		"a.txt": "new file",
+		"b.txt": "changed b",
+	})
+
+	planPath := filepath.Join(t.TempDir(), "plan.json")
+	err := gdrive.UploadSync(UploadSyncArgs{
+		Out:              ioutil.Discard,
+		Path:             dir,
+		RootId:           rootId,
+		DeleteExtraneous: true,
+		Comparer:         testComparer{},
+		PlanOut:          planPath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return gdrive, server, rootId, dir, planPath
+}
+
+// Adds a file like another sync client would
+func addSyncedFile(server *drivetest.Server, rootId, parentId, name, content string) string {
+	return server.Insert(&drive.File{
+		Name:          name,
+		Parents:       []string{parentId},
+		AppProperties: map[string]string{"sync": "true", "syncRootId": rootId},
+	}, []byte(content))
+}
+
+func TestSyncApply(t *testing.T) {
+	gdrive, server, rootId, _, planPath := prepareSyncPlan(t)
+	extraId := server.Find(rootId, "extra.txt").Id
+	oldId := server.Find(rootId, "old").Id
+
+	err := gdrive.SyncApply(SyncApplyArgs{Out: ioutil.Discard, PlanPath: planPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertRemoteFile(t, server, rootId, "a.txt", []byte("new file"))
+	assertRemoteFile(t, server, rootId, "b.txt", []byte("changed b"))
+
+	for _, id := range []string{extraId, oldId} {
+		if f := server.File(id); f != nil && !f.Trashed {
+			t.Fatalf("Expected %s to be deleted", f.Name)
+		}
+	}
+}
+
+func TestSyncApplyRefusesOutdatedPlans(t *testing.T) {
+	tests := map[string]func(t *testing.T, server *drivetest.Server, rootId, dir string){
+		"local file changed": func(t *testing.T, server *drivetest.Server, rootId, dir string) {
+			writeTestFiles(t, dir, map[string]string{"b.txt": "changed b again"})
+		},
+		"local file created": func(t *testing.T, server *drivetest.Server, rootId, dir string) {
+			writeTestFiles(t, dir, map[string]string{"extra.txt": "extra"})
+		},
+		"remote file changed": func(t *testing.T, server *drivetest.Server, rootId, dir string) {
+			server.SetContent(server.Find(rootId, "b.txt").Id, []byte("remote b"))
+		},
+		"remote file created": func(t *testing.T, server *drivetest.Server, rootId, dir string) {
+			addSyncedFile(server, rootId, rootId, "a.txt", "remote a")
+		},
+		"remote directory no longer empty": func(t *testing.T, server *drivetest.Server, rootId, dir string) {
+			addSyncedFile(server, rootId, server.Find(rootId, "old").Id, "d.txt", "d")
+		},
+	}
+
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			gdrive, server, rootId, dir, planPath := prepareSyncPlan(t)
+			mutate(t, server, rootId, dir)
+			requests := len(server.Requests())
+
+			err := gdrive.SyncApply(SyncApplyArgs{Out: ioutil.Discard, PlanPath: planPath})
+			if err == nil || !strings.Contains(err.Error(), "plan is outdated") {
+				t.Fatalf("Expected the outdated plan to be refused, got %v", err)
+			}
+
+			// Nothing is changed on drive
+			for _, r := range server.Requests()[requests:] {
+				if !strings.HasPrefix(r, "GET ") {
+					t.Fatalf("Expected no changes to be made, got request %s", r)
+				}
+			}
+		})
+	}
+}
+
+func TestSyncApplyKeepsNonEmptyDirectories(t *testing.T) {
+	gdrive, server, rootId, _, planPath := prepareSyncPlan(t)
+	oldId := server.Find(rootId, "old").Id
+
+	// Files that are not synced are only seen when the directory is deleted
+	foreignId := server.AddFile(oldId, "d.txt", []byte("d"))
+
+	err := gdrive.SyncApply(SyncApplyArgs{Out: ioutil.Discard, PlanPath: planPath})
+	if err == nil || !strings.Contains(err.Error(), "plan is outdated") {
+		t.Fatalf("Expected the directory delete to be refused, got %v", err)
+	}
+
+	for _, id := range []string{oldId, foreignId} {
+		if f := server.File(id); f == nil || f.Trashed {
+			t.Fatalf("Expected %s to be kept", id)
+		}
+	}
+}
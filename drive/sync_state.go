@@ -0,0 +1,228 @@
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// syncState holds what every path looked like after the last successful
+// sync, which makes it possible to tell a local deletion apart from a
+// remote creation (and vice versa)
+type syncState struct {
+	mu           sync.Mutex
+	path         string
+	RootId       string                     `json:"rootId"`
+	LocalPath    string                     `json:"localPath"`
+	Files        map[string]*syncStateEntry `json:"files"`
+	ChangesToken string                     `json:"changesToken,omitempty"`
+	Remote       map[string]*drive.File     `json:"remote,omitempty"`
+}
+
+type syncStateEntry struct {
+	Id             string `json:"id"`
+	Md5            string `json:"md5,omitempty"`
+	Size           int64  `json:"size"`
+	Modified       int64  `json:"modified"`
+	IsDir          bool   `json:"isDir,omitempty"`
+	Exported       bool   `json:"exported,omitempty"`
+	RemoteModified string `json:"remoteModified,omitempty"`
+}
+
+func loadSyncState(dir, rootId, localPath string) (*syncState, error) {
+	absPath, err := filepath.Abs(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to determine local absolute path: %s", err)
+	}
+
+	state := &syncState{
+		RootId:    rootId,
+		LocalPath: absPath,
+		Files:     map[string]*syncStateEntry{},
+	}
+
+	// State is kept in memory only if no directory is given
+	if dir == "" {
+		return state, nil
+	}
+
+	state.path = filepath.Join(dir, rootId+".json")
+
+	f, err := os.Open(state.path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open sync state: %s", err)
+	}
+
+	// Close file on function exit
+	defer f.Close()
+
+	persisted := &syncState{}
+	err = json.NewDecoder(f).Decode(persisted)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read sync state %s: %s", state.path, err)
+	}
+
+	// The cached remote tree does not depend on the local path
+	if persisted.RootId == rootId && persisted.Remote != nil {
+		state.ChangesToken = persisted.ChangesToken
+		state.Remote = persisted.Remote
+	}
+
+	// The recorded state says nothing about this directory if
+	// the sync root was last synced with another local path
+	if persisted.LocalPath != absPath || persisted.Files == nil {
+		return state, nil
+	}
+
+	state.Files = persisted.Files
+	return state, nil
+}
+
+func (self *syncState) save() error {
+	if self.path == "" {
+		return nil
+	}
+
+	if err := mkdir(self.path); err != nil {
+		return fmt.Errorf("Failed to create sync state directory: %s", err)
+	}
+
+	// Write to tmp file first
+	tmpPath := self.path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("Failed to save sync state: %s", err)
+	}
+
+	err = json.NewEncoder(f).Encode(self)
+	f.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("Failed to save sync state: %s", err)
+	}
+
+	// Move file to correct path
+	return os.Rename(tmpPath, self.path)
+}
+
+func (self *syncState) get(relPath string) (*syncStateEntry, bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	entry, found := self.Files[relPath]
+	return entry, found
+}
+
+func (self *syncState) update(relPath string, info os.FileInfo, f *drive.File) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	entry := &syncStateEntry{
+		Id:       f.Id,
+		Md5:      f.Md5Checksum,
+		Size:     info.Size(),
+		Modified: info.ModTime().UnixNano(),
+		IsDir:    info.IsDir(),
+	}
+
+	// Google documents have no md5, the local file is an export
+	// which is tracked by the modified time of the document
+	if !info.IsDir() && f.Md5Checksum == "" {
+		entry.Exported = true
+		entry.RemoteModified = f.ModifiedTime
+	}
+
+	self.Files[relPath] = entry
+}
+
+// Records the local file at absPath as being in sync with f
+func (self *syncState) updateFromDisk(relPath, absPath string, f *drive.File) error {
+	// Symlinks are recorded as themselves, like they are collected
+	info, err := os.Lstat(absPath)
+	if err != nil {
+		return fmt.Errorf("Failed to stat local file: %s", err)
+	}
+
+	self.update(relPath, info, f)
+	return nil
+}
+
+func (self *syncState) remove(relPath string) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	delete(self.Files, relPath)
+}
+
+// Returns true if nothing has been synced yet
+func (self *syncState) isEmpty() bool {
+	return len(self.Files) == 0
+}
+
+// Records files that exist on both sides and have not changed
+func (self *syncFiles) recordUnchanged(changedFiles []*changedFile) {
+	changed := map[string]bool{}
+	for _, cf := range changedFiles {
+		changed[cf.local.relPath] = true
+	}
+
+	for _, lf := range self.local {
+		if changed[lf.relPath] {
+			continue
+		}
+
+		// Documents are either exported or left alone
+		rf, found := self.findRemoteByPath(lf.relPath)
+		if found && !rf.isDocument() && lf.info.IsDir() == isDir(rf.file) {
+			self.state.update(lf.relPath, lf.info, rf.file)
+		}
+	}
+}
+
+// Forgets files that no longer exists on either side
+func (self *syncFiles) pruneState() {
+	for relPath := range self.state.Files {
+		_, localFound := self.findLocalByPath(relPath)
+		_, remoteFound := self.findRemoteByPath(relPath)
+		if !localFound && !remoteFound {
+			self.state.remove(relPath)
+		}
+	}
+}
+
+// Returns true if the local file differs from what was last synced
+func (self *syncStateEntry) localChanged(lf *LocalFile) bool {
+	if self.IsDir != lf.info.IsDir() {
+		return true
+	}
+
+	if self.IsDir {
+		return false
+	}
+
+	return self.Size != lf.Size() || self.Modified != lf.Modified().UnixNano()
+}
+
+// Returns true if the remote file differs from what was last synced
+func (self *syncStateEntry) remoteChanged(rf *RemoteFile) bool {
+	if self.IsDir != isDir(rf.file) {
+		return true
+	}
+
+	if self.IsDir {
+		return self.Id != rf.file.Id
+	}
+
+	return self.Id != rf.file.Id || self.version() != rf.version()
+}
+
+// Returns what identifies the synced content of the remote file
+func (self *syncStateEntry) version() string {
+	if self.Exported {
+		return self.RemoteModified
+	}
+	return self.Md5
+}
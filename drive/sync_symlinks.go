@@ -0,0 +1,240 @@
+package drive
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type SymlinkPolicy int
+
+const (
+	// Skip symlinks, they are listed in the summary
+	SymlinkSkip SymlinkPolicy = iota
+
+	// Sync the files and directories the symlinks point to
+	SymlinkFollow
+
+	// Sync the symlinks themselves, the target is kept in an appProperty
+	SymlinkPreserve
+)
+
+// Collects the local files of a sync root, files that can not
+// be synced are collected as well so that they can be reported
+type localWalker struct {
+	shouldIgnore ignoreFunc
+	symlinks     SymlinkPolicy
+	files        []*LocalFile
+	skipped      []string
+}
+
+// Walks the directory at absPath, relPath is the path of the directory
+// relative to the sync root. Ancestors holds the real paths of the
+// directories that are being walked, to detect symlink loops
+func (self *localWalker) walk(absPath, relPath string, ancestors []string) error {
+	return filepath.Walk(absPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Skip the directory itself
+		if path == absPath {
+			return nil
+		}
+
+		// Get relative path from root
+		rel, err := filepath.Rel(absPath, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.Join(relPath, rel)
+
+		// Skip file if it is ignored by ignore file
+		if self.shouldIgnore(rel) {
+			return nil
+		}
+
+		return self.add(path, rel, info, ancestors)
+	})
+}
+
+func (self *localWalker) add(absPath, relPath string, info os.FileInfo, ancestors []string) error {
+	if info.Mode()&os.ModeSymlink != 0 {
+		return self.addSymlink(absPath, relPath, info, ancestors)
+	}
+
+	// Skip interrupted downloads, they are resumed by the next sync download
+	if info.Mode().IsRegular() && strings.HasSuffix(absPath, ".incomplete") {
+		return nil
+	}
+
+	if !info.IsDir() && !info.Mode().IsRegular() {
+		self.skip(relPath, describeFileMode(info.Mode()))
+		return nil
+	}
+
+	self.files = append(self.files, &LocalFile{
+		absPath: absPath,
+		relPath: relPath,
+		info:    info,
+	})
+	return nil
+}
+
+func (self *localWalker) addSymlink(absPath, relPath string, info os.FileInfo, ancestors []string) error {
+	switch self.symlinks {
+	case SymlinkPreserve:
+		target, err := os.Readlink(absPath)
+		if err != nil {
+			return err
+		}
+
+		self.files = append(self.files, &LocalFile{
+			absPath:    absPath,
+			relPath:    relPath,
+			info:       info,
+			linkTarget: target,
+		})
+		return nil
+
+	case SymlinkFollow:
+		return self.followSymlink(absPath, relPath, ancestors)
+	}
+
+	self.skip(relPath, "symlink")
+	return nil
+}
+
+func (self *localWalker) followSymlink(absPath, relPath string, ancestors []string) error {
+	realPath, err := filepath.EvalSymlinks(absPath)
+	if err != nil {
+		self.skip(relPath, "broken symlink")
+		return nil
+	}
+
+	info, err := os.Stat(realPath)
+	if err != nil {
+		self.skip(relPath, "broken symlink")
+		return nil
+	}
+
+	if !info.IsDir() {
+		return self.add(absPath, relPath, info, ancestors)
+	}
+
+	// A link to a directory that is being walked would never end
+	parent, err := filepath.EvalSymlinks(filepath.Dir(absPath))
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range append(ancestors, parent) {
+		if realPath == dir || isChildPath(realPath, dir) {
+			self.skip(relPath, "symlink loop")
+			return nil
+		}
+	}
+
+	self.files = append(self.files, &LocalFile{
+		absPath: absPath,
+		relPath: relPath,
+		info:    info,
+	})
+
+	return self.walk(realPath, relPath, append(ancestors, realPath))
+}
+
+func (self *localWalker) skip(relPath, reason string) {
+	self.skipped = append(self.skipped, fmt.Sprintf("%s (%s)", relPath, reason))
+}
+
+func describeFileMode(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeNamedPipe != 0:
+		return "fifo"
+	case mode&os.ModeCharDevice != 0:
+		return "character device"
+	case mode&os.ModeDevice != 0:
+		return "device"
+	}
+	return "special file"
+}
+
+func (self LocalFile) isSymlink() bool {
+	return self.linkTarget != ""
+}
+
+// Opens the content that is uploaded, preserved symlinks are
+// uploaded with their target as content
+func (self LocalFile) open() (io.ReadCloser, error) {
+	if self.isSymlink() {
+		return ioutil.NopCloser(strings.NewReader(self.linkTarget)), nil
+	}
+	return os.Open(self.absPath)
+}
+
+func (self RemoteFile) linkTarget() string {
+	return self.file.AppProperties["syncSymlink"]
+}
+
+func (self RemoteFile) isSymlink() bool {
+	return self.linkTarget() != ""
+}
+
+// Leaves remote symlinks out of the sync, along with anything
+// at the same local path that would otherwise collide with them
+func (self *syncFiles) skipRemoteSymlinks() {
+	var remote []*RemoteFile
+	var links []string
+
+	for _, rf := range self.remote {
+		if rf.isSymlink() {
+			links = append(links, rf.relPath)
+			self.skipped = append(self.skipped, fmt.Sprintf("%s (remote symlink, use --symlinks preserve to sync it)", rf.relPath))
+			continue
+		}
+		remote = append(remote, rf)
+	}
+
+	self.remote = remote
+	self.skipLocalPaths(links)
+}
+
+// Replaces whatever is at fpath with a symlink to target
+func createLocalSymlink(target, fpath string, dryRun bool) error {
+	if dryRun {
+		return nil
+	}
+
+	// Ensure any parent directories exists
+	if err := mkdir(fpath); err != nil {
+		return err
+	}
+
+	if _, err := os.Lstat(fpath); err == nil {
+		if err := os.Remove(fpath); err != nil {
+			return fmt.Errorf("Failed to replace local file with symlink: %s", err)
+		}
+	}
+
+	if err := os.Symlink(target, fpath); err != nil {
+		return fmt.Errorf("Failed to create symlink: %s", err)
+	}
+	return nil
+}
+
+func (self *Drive) printSkippedFiles(out io.Writer, skipped []string) {
+	if len(skipped) == 0 {
+		return
+	}
+
+	self.message(out, "\n%d files were skipped:", len(skipped))
+	for _, s := range skipped {
+		self.message(out, "  %s", s)
+	}
+}
@@ -0,0 +1,144 @@
+package drive
+
+import (
+	"bytes"
+	"github.com/prasmussen/gdrive/drive/drivetest"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSyncPreserveSymlinks(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	rootId := server.AddFolder(drivetest.RootId, "sync")
+	srcDir := t.TempDir()
+	srcStateDir := t.TempDir()
+	writeTestFiles(t, srcDir, map[string]string{"a.txt": "a"})
+
+	if err := os.Symlink("a.txt", filepath.Join(srcDir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	upload := func() {
+		t.Helper()
+		err := gdrive.UploadSync(UploadSyncArgs{
+			Out:      ioutil.Discard,
+			Path:     srcDir,
+			RootId:   rootId,
+			Comparer: testComparer{},
+			StateDir: srcStateDir,
+			Symlinks: SymlinkPreserve,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := t.TempDir()
+	stateDir := t.TempDir()
+	download := func() {
+		t.Helper()
+		err := gdrive.DownloadSync(DownloadSyncArgs{
+			Out:      ioutil.Discard,
+			Path:     dir,
+			RootId:   rootId,
+			Comparer: testComparer{},
+			StateDir: stateDir,
+			Symlinks: SymlinkPreserve,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assertSymlink := func(name, expected string) {
+		t.Helper()
+		target, err := os.Readlink(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if target != expected {
+			t.Fatalf("Expected %s to link to %s, got %s", name, expected, target)
+		}
+	}
+
+	upload()
+
+	f := server.Find(rootId, "link")
+	if f == nil || f.AppProperties["syncSymlink"] != "a.txt" {
+		t.Fatalf("Expected the symlink target to be stored remotely, got %v", f)
+	}
+
+	// Remote symlinks are skipped unless they are preserved
+	skipDir := t.TempDir()
+	downloadSync(t, gdrive, rootId, skipDir, "")
+	if _, err := os.Lstat(filepath.Join(skipDir, "link")); !os.IsNotExist(err) {
+		t.Fatalf("Expected the symlink to be skipped, got %v", err)
+	}
+
+	download()
+	assertSymlink("link", "a.txt")
+
+	// The next syncs get the new symlink from the changes api
+	if err := os.Symlink("link", filepath.Join(srcDir, "link2")); err != nil {
+		t.Fatal(err)
+	}
+	upload()
+	download()
+	assertRequested(t, server, "GET /drive/v3/changes")
+
+	assertSymlink("link", "a.txt")
+	assertSymlink("link2", "link")
+
+	// Unchanged symlinks are not uploaded again
+	countUpdates := func() int {
+		return countRequests(server, "PATCH /upload/drive/v3/files/") + countRequests(server, "PATCH /drive/v3/files/")
+	}
+	updates := countUpdates()
+	upload()
+	if n := countUpdates() - updates; n != 0 {
+		t.Fatalf("Expected unchanged symlinks not to be updated, got %d updates", n)
+	}
+}
+
+func TestUploadSyncReportsSkippedFiles(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	rootId := server.AddFolder(drivetest.RootId, "sync")
+	srcDir := t.TempDir()
+	writeTestFiles(t, srcDir, map[string]string{"a.txt": "a"})
+
+	if err := os.Symlink("a.txt", filepath.Join(srcDir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("unix", filepath.Join(srcDir, "sock"))
+	if err != nil {
+		t.Skipf("Unix sockets are not supported: %s", err)
+	}
+	defer l.Close()
+
+	out := &bytes.Buffer{}
+	err = gdrive.UploadSync(UploadSyncArgs{
+		Out:      out,
+		Path:     srcDir,
+		RootId:   rootId,
+		Comparer: testComparer{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{"link (symlink)", "sock (socket)"} {
+		if !strings.Contains(out.String(), s) {
+			t.Fatalf("Expected %q in output, got %s", s, out.String())
+		}
+	}
+
+	if server.Find(rootId, "link") != nil || server.Find(rootId, "sock") != nil {
+		t.Fatal("Expected skipped files not to be uploaded")
+	}
+}
@@ -0,0 +1,628 @@
+package drive
+
+import (
+	"fmt"
+	"github.com/prasmussen/gdrive/drive/drivetest"
+	"google.golang.org/api/drive/v3"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func uploadSync(t *testing.T, gdrive *Drive, rootId, path, stateDir string) {
+	t.Helper()
+
+	err := gdrive.UploadSync(UploadSyncArgs{
+		Out:              ioutil.Discard,
+		Path:             path,
+		RootId:           rootId,
+		DeleteExtraneous: true,
+		Comparer:         testComparer{},
+		StateDir:         stateDir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func downloadSync(t *testing.T, gdrive *Drive, rootId, path, stateDir string) {
+	t.Helper()
+
+	err := gdrive.DownloadSync(DownloadSyncArgs{
+		Out:              ioutil.Discard,
+		Path:             path,
+		RootId:           rootId,
+		DeleteExtraneous: true,
+		Comparer:         testComparer{},
+		StateDir:         stateDir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUploadSync(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	rootId := server.AddFolder(drivetest.RootId, "sync")
+	dir := t.TempDir()
+	stateDir := t.TempDir()
+
+	writeTestFiles(t, dir, map[string]string{
+		"a.txt":     "a",
+		"dir/b.txt": "b",
+	})
+	uploadSync(t, gdrive, rootId, dir, stateDir)
+
+	assertRemoteFile(t, server, rootId, "a.txt", []byte("a"))
+	assertRemoteFile(t, server, rootId, "dir/b.txt", []byte("b"))
+
+	if _, ok := server.File(rootId).AppProperties["syncRoot"]; !ok {
+		t.Fatal("Expected the root directory to be marked as sync root")
+	}
+
+	// Change, add and remove files, the next sync only applies the difference
+	writeTestFiles(t, dir, map[string]string{
+		"a.txt": "changed",
+		"c.txt": "c",
+	})
+	if err := os.Remove(filepath.Join(dir, "dir", "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	uploadSync(t, gdrive, rootId, dir, stateDir)
+
+	assertRemoteFile(t, server, rootId, "a.txt", []byte("changed"))
+	assertRemoteFile(t, server, rootId, "c.txt", []byte("c"))
+	if f := server.Find(rootId, "dir/b.txt"); f == nil || !f.Trashed {
+		t.Fatal("Expected dir/b.txt to be moved to trash")
+	}
+}
+
+func TestUploadSyncRequiresEmptyRoot(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	rootId := server.AddFolder(drivetest.RootId, "sync")
+	server.AddFile(rootId, "unrelated.txt", []byte("x"))
+
+	err := gdrive.UploadSync(UploadSyncArgs{
+		Out:      ioutil.Discard,
+		Path:     t.TempDir(),
+		RootId:   rootId,
+		Comparer: testComparer{},
+	})
+	if err == nil {
+		t.Fatal("Expected the initial sync to a non-empty directory to fail")
+	}
+}
+
+func TestUploadSyncRetriesRateLimitErrors(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	rootId := server.AddFolder(drivetest.RootId, "sync")
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"a.txt": "a"})
+
+	server.Fail(drivetest.Fault{Method: "POST", Path: "/drive/v3/files", Status: http.StatusForbidden})
+	uploadSync(t, gdrive, rootId, dir, "")
+
+	assertRemoteFile(t, server, rootId, "a.txt", []byte("a"))
+}
+
+func TestUploadSyncRetriesDeletes(t *testing.T) {
+	shortBackoff(t)
+
+	for _, permanent := range []bool{false, true} {
+		gdrive, server := newTestDrive(t)
+		rootId := server.AddFolder(drivetest.RootId, "sync")
+		dir := t.TempDir()
+		stateDir := t.TempDir()
+		writeTestFiles(t, dir, map[string]string{"a.txt": "a", "b.txt": "b"})
+		uploadSync(t, gdrive, rootId, dir, stateDir)
+
+		bId := server.Find(rootId, "b.txt").Id
+		if err := os.Remove(filepath.Join(dir, "b.txt")); err != nil {
+			t.Fatal(err)
+		}
+
+		method := "PATCH"
+		if permanent {
+			method = "DELETE"
+		}
+		server.Fail(drivetest.Fault{Method: method, Path: "/drive/v3/files/" + bId, Status: http.StatusForbidden})
+
+		err := gdrive.UploadSync(UploadSyncArgs{
+			Out:              ioutil.Discard,
+			Path:             dir,
+			RootId:           rootId,
+			DeleteExtraneous: true,
+			Permanent:        permanent,
+			Comparer:         testComparer{},
+			StateDir:         stateDir,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f := server.File(bId)
+		if permanent && f != nil {
+			t.Fatal("Expected b.txt to be deleted permanently")
+		}
+		if !permanent && (f == nil || !f.Trashed) {
+			t.Fatal("Expected b.txt to be trashed")
+		}
+
+		if n := countRequests(server, method+" /drive/v3/files/"+bId); n != 2 {
+			t.Fatalf("Expected the failed %s to be retried once, got %d requests", method, n)
+		}
+	}
+}
+
+func TestDownloadSync(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	rootId := server.AddFolder(drivetest.RootId, "sync")
+	srcDir := t.TempDir()
+	writeTestFiles(t, srcDir, map[string]string{
+		"a.txt":     "a",
+		"dir/b.txt": "b",
+	})
+	uploadSync(t, gdrive, rootId, srcDir, "")
+
+	dir := t.TempDir()
+	stateDir := t.TempDir()
+	downloadSync(t, gdrive, rootId, dir, stateDir)
+
+	assertLocalFile(t, filepath.Join(dir, "a.txt"), []byte("a"))
+	assertLocalFile(t, filepath.Join(dir, "dir", "b.txt"), []byte("b"))
+
+	// Remote changes are picked up through the changes api,
+	// a failing download is retried
+	a := server.Find(rootId, "a.txt")
+	server.SetContent(a.Id, []byte("changed remotely"))
+	server.Remove(server.Find(rootId, "dir/b.txt").Id)
+	server.Fail(drivetest.Fault{Method: "GET", Path: "/drive/v3/files/" + a.Id, Status: http.StatusServiceUnavailable})
+
+	downloadSync(t, gdrive, rootId, dir, stateDir)
+	assertRequested(t, server, "GET /drive/v3/changes")
+
+	// The first sync, the failed attempt and the retry
+	if n := countRequests(server, "GET /drive/v3/files/"+a.Id); n != 3 {
+		t.Fatalf("Expected 3 downloads of a.txt, got %d", n)
+	}
+
+	assertLocalFile(t, filepath.Join(dir, "a.txt"), []byte("changed remotely"))
+	if _, err := os.Stat(filepath.Join(dir, "dir", "b.txt")); !os.IsNotExist(err) {
+		t.Fatal("Expected dir/b.txt to be deleted locally")
+	}
+}
+
+func TestDownloadSyncExport(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	rootId := server.AddFolder(drivetest.RootId, "sync")
+	srcDir := t.TempDir()
+	writeTestFiles(t, srcDir, map[string]string{"a.txt": "a"})
+	uploadSync(t, gdrive, rootId, srcDir, "")
+
+	docId := server.Insert(&drive.File{
+		Name:          "report",
+		Parents:       []string{rootId},
+		MimeType:      "application/vnd.google-apps.document",
+		AppProperties: map[string]string{"sync": "true", "syncRootId": rootId},
+	}, []byte("v1"))
+
+	dir := t.TempDir()
+	stateDir := t.TempDir()
+	args := DownloadSyncArgs{
+		Out:              ioutil.Discard,
+		Path:             dir,
+		RootId:           rootId,
+		DeleteExtraneous: true,
+		Comparer:         testComparer{},
+		StateDir:         stateDir,
+		Export:           true,
+	}
+
+	if err := gdrive.DownloadSync(args); err != nil {
+		t.Fatal(err)
+	}
+	assertLocalFile(t, filepath.Join(dir, "report.pdf"), []byte("v1"))
+
+	// Documents have no md5, a new modified time means a new export
+	server.SetContent(docId, []byte("v2"))
+	server.Modify(docId, func(f *drive.File) {
+		f.ModifiedTime = "2030-01-02T15:04:05.000Z"
+	})
+
+	if err := gdrive.DownloadSync(args); err != nil {
+		t.Fatal(err)
+	}
+	assertLocalFile(t, filepath.Join(dir, "report.pdf"), []byte("v2"))
+
+	// The export is neither uploaded nor does it replace the document
+	uploadSync(t, gdrive, rootId, dir, stateDir)
+
+	if f := server.Find(rootId, "report.pdf"); f != nil {
+		t.Fatal("Expected the exported file to be left out of the upload")
+	}
+
+	if f := server.File(docId); f.Trashed || string(server.Content(docId)) != "v2" {
+		t.Fatal("Expected the document to be left alone by the upload")
+	}
+
+	// Documents are skipped without export
+	other := t.TempDir()
+	downloadSync(t, gdrive, rootId, other, "")
+
+	if _, err := os.Stat(filepath.Join(other, "report.pdf")); !os.IsNotExist(err) {
+		t.Fatal("Expected the document to be skipped without export")
+	}
+}
+
+func TestSyncPreservesModTime(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	rootId := server.AddFolder(drivetest.RootId, "sync")
+	srcDir := t.TempDir()
+	srcStateDir := t.TempDir()
+	writeTestFiles(t, srcDir, map[string]string{"a.txt": "a"})
+
+	modified := time.Date(2016, 1, 2, 15, 4, 5, 0, time.UTC)
+	setModeAndTime := func(name string, mode os.FileMode) {
+		t.Helper()
+		path := filepath.Join(srcDir, name)
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assertModeAndTime := func(dir, name string, mode os.FileMode) {
+		t.Helper()
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !info.ModTime().Equal(modified) || info.Mode().Perm() != mode {
+			t.Fatalf("Unexpected local modified time %s and mode %s of %s", info.ModTime(), info.Mode(), name)
+		}
+	}
+
+	setModeAndTime("a.txt", 0600)
+	uploadSync(t, gdrive, rootId, srcDir, srcStateDir)
+
+	f := server.Find(rootId, "a.txt")
+	if f.ModifiedTime != "2016-01-02T15:04:05.000Z" || f.AppProperties["syncMode"] != "0600" {
+		t.Fatalf("Unexpected remote modified time %s and mode %s", f.ModifiedTime, f.AppProperties["syncMode"])
+	}
+
+	dir := t.TempDir()
+	stateDir := t.TempDir()
+	downloadSync(t, gdrive, rootId, dir, stateDir)
+	assertModeAndTime(dir, "a.txt", 0600)
+
+	// The next syncs get the changed and the new file from the changes api
+	writeTestFiles(t, srcDir, map[string]string{"a.txt": "changed", "b.txt": "b"})
+	setModeAndTime("a.txt", 0640)
+	setModeAndTime("b.txt", 0600)
+	uploadSync(t, gdrive, rootId, srcDir, srcStateDir)
+	downloadSync(t, gdrive, rootId, dir, stateDir)
+	assertRequested(t, server, "GET /drive/v3/changes")
+
+	assertLocalFile(t, filepath.Join(dir, "a.txt"), []byte("changed"))
+	assertModeAndTime(dir, "a.txt", 0640)
+	assertModeAndTime(dir, "b.txt", 0600)
+}
+
+func TestSyncDetectsRenamesByContent(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	rootId := server.AddFolder(drivetest.RootId, "sync")
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"a.txt": "a"})
+	uploadSync(t, gdrive, rootId, dir, "")
+	fileId := server.Find(rootId, "a.txt").Id
+
+	// Without a sync state the rename can only be told by the content
+	if err := os.MkdirAll(filepath.Join(dir, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "a.txt"), filepath.Join(dir, "dir", "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	uploadSync(t, gdrive, rootId, dir, "")
+
+	if f := server.Find(rootId, "dir/b.txt"); f == nil || f.Id != fileId {
+		t.Fatalf("Expected the remote file to be moved, got %v", f)
+	}
+
+	// Rename the file remotely and move the local file along with it
+	server.Modify(fileId, func(meta *drive.File) {
+		meta.Name = "c.txt"
+	})
+
+	info, err := os.Stat(filepath.Join(dir, "dir", "b.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloadSync(t, gdrive, rootId, dir, "")
+
+	moved, err := os.Stat(filepath.Join(dir, "dir", "c.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !os.SameFile(info, moved) {
+		t.Fatal("Expected the local file to be moved instead of downloaded")
+	}
+}
+
+func TestIncrementalSyncKeepsAppProperties(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	rootId := server.AddFolder(drivetest.RootId, "sync")
+	srcDir := t.TempDir()
+	writeTestFiles(t, srcDir, map[string]string{"a.txt": "a"})
+	uploadSync(t, gdrive, rootId, srcDir, "")
+
+	dir := t.TempDir()
+	stateDir := t.TempDir()
+	downloadSync(t, gdrive, rootId, dir, stateDir)
+
+	// The next sync gets the changed file from the changes api
+	server.SetContent(server.Find(rootId, "a.txt").Id, []byte("changed"))
+	downloadSync(t, gdrive, rootId, dir, stateDir)
+	assertRequested(t, server, "GET /drive/v3/changes")
+
+	state, err := loadSyncState(stateDir, rootId, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range state.Remote {
+		if f.AppProperties["syncRootId"] != rootId {
+			t.Fatalf("Expected the cached file %s to keep its appProperties, got %v", f.Name, f.AppProperties)
+		}
+	}
+}
+
+func TestIncrementalSyncAppliesChanges(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	rootId := server.AddFolder(drivetest.RootId, "sync")
+	otherId := server.AddFolder(drivetest.RootId, "other")
+	srcDir := t.TempDir()
+	writeTestFiles(t, srcDir, map[string]string{
+		"kept.txt":    "kept",
+		"removed.txt": "removed",
+		"trashed.txt": "trashed",
+		"moved.txt":   "moved",
+		"dir/c.txt":   "c",
+	})
+	uploadSync(t, gdrive, rootId, srcDir, "")
+
+	dir := t.TempDir()
+	stateDir := t.TempDir()
+	downloadSync(t, gdrive, rootId, dir, stateDir)
+
+	server.Remove(server.Find(rootId, "removed.txt").Id)
+	server.Modify(server.Find(rootId, "trashed.txt").Id, func(f *drive.File) {
+		f.Trashed = true
+	})
+	server.Modify(server.Find(rootId, "moved.txt").Id, func(f *drive.File) {
+		f.Parents = []string{otherId}
+	})
+
+	// The content of a trashed directory does not show up as changes
+	server.Modify(server.Find(rootId, "dir").Id, func(f *drive.File) {
+		f.Trashed = true
+	})
+
+	countListings := func() int {
+		n := 0
+		for _, r := range server.Requests() {
+			if r == "GET /drive/v3/files" {
+				n++
+			}
+		}
+		return n
+	}
+	listings := countListings()
+
+	downloadSync(t, gdrive, rootId, dir, stateDir)
+	assertRequested(t, server, "GET /drive/v3/changes")
+	if n := countListings() - listings; n != 0 {
+		t.Fatalf("Expected the cached tree to be used, got %d listings", n)
+	}
+
+	assertLocalFile(t, filepath.Join(dir, "kept.txt"), []byte("kept"))
+	for _, name := range []string{"removed.txt", "trashed.txt", "moved.txt", "dir"} {
+		if _, err := os.Lstat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Fatalf("Expected %s to be deleted, got %v", name, err)
+		}
+	}
+
+	state, err := loadSyncState(stateDir, rootId, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(state.Remote) != 1 {
+		t.Fatalf("Expected only kept.txt to be cached, got %d files", len(state.Remote))
+	}
+}
+
+func TestUploadSyncRenameRequiresSameContent(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	rootId := server.AddFolder(drivetest.RootId, "sync")
+	dir := t.TempDir()
+	stateDir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"a.txt": "aaa"})
+
+	modified := time.Date(2016, 1, 2, 15, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(dir, "a.txt"), modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	uploadSync(t, gdrive, rootId, dir, stateDir)
+	fileId := server.Find(rootId, "a.txt").Id
+
+	// A different file with the same size and modification time replaces a.txt
+	if err := os.Remove(filepath.Join(dir, "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFiles(t, dir, map[string]string{"b.txt": "bbb"})
+	if err := os.Chtimes(filepath.Join(dir, "b.txt"), modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	uploadSync(t, gdrive, rootId, dir, stateDir)
+
+	assertRemoteFile(t, server, rootId, "b.txt", []byte("bbb"))
+	if server.Find(rootId, "b.txt").Id == fileId {
+		t.Fatal("Expected b.txt to be uploaded instead of moving a.txt")
+	}
+}
+
+func TestBidirectionalSyncKeepsNonEmptyDirectories(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	rootId := server.AddFolder(drivetest.RootId, "sync")
+	dir := t.TempDir()
+	stateDir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		".gdriveignore": "*.tmp",
+		"dir/a.txt":     "a",
+		"dir/x.tmp":     "x",
+	})
+
+	bidirectionalSync := func() {
+		t.Helper()
+		err := gdrive.BidirectionalSync(BidirectionalSyncArgs{
+			Out:      ioutil.Discard,
+			Path:     dir,
+			RootId:   rootId,
+			Comparer: testComparer{},
+			StateDir: stateDir,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bidirectionalSync()
+	assertRemoteFile(t, server, rootId, "dir/a.txt", []byte("a"))
+
+	// The directory is deleted remotely but still holds an ignored file
+	server.Remove(server.Find(rootId, "dir").Id)
+	bidirectionalSync()
+
+	if _, err := os.Stat(filepath.Join(dir, "dir", "a.txt")); !os.IsNotExist(err) {
+		t.Fatalf("Expected dir/a.txt to be deleted locally, got %v", err)
+	}
+	assertLocalFile(t, filepath.Join(dir, "dir", "x.tmp"), []byte("x"))
+}
+
+func TestSyncRootInSharedDrive(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	driveId := server.AddDrive("Team")
+	rootId := server.AddFolder(driveId, "sync")
+	srcDir := t.TempDir()
+	srcStateDir := t.TempDir()
+	writeTestFiles(t, srcDir, map[string]string{"a.txt": "abc"})
+
+	// Files in shared drives do not use the personal quota
+	server.SetQuota(1)
+
+	uploadSync(t, gdrive, rootId, srcDir, srcStateDir)
+
+	dir := t.TempDir()
+	stateDir := t.TempDir()
+	downloadSync(t, gdrive, rootId, dir, stateDir)
+	assertLocalFile(t, filepath.Join(dir, "a.txt"), []byte("abc"))
+
+	// Changes in the shared drive are picked up without --drive
+	writeTestFiles(t, srcDir, map[string]string{"b.txt": "b"})
+	uploadSync(t, gdrive, rootId, srcDir, srcStateDir)
+	downloadSync(t, gdrive, rootId, dir, stateDir)
+	assertRequested(t, server, "GET /drive/v3/changes")
+	assertLocalFile(t, filepath.Join(dir, "b.txt"), []byte("b"))
+}
+
+func TestMatchRenames(t *testing.T) {
+	cases := []struct {
+		name       string
+		missing    []string
+		candidates []string
+		same       func(i, j int) bool
+		expected   []int
+	}{
+		{"unique", []string{"1", "2"}, []string{"2", "1"}, func(i, j int) bool { return true }, []int{1, 0}},
+		{"no match", []string{"1"}, []string{"2"}, func(i, j int) bool { return true }, []int{-1}},
+		{"different content", []string{"1"}, []string{"1"}, func(i, j int) bool { return false }, []int{-1}},
+		{"ambiguous", []string{"1"}, []string{"1", "1"}, func(i, j int) bool { return true }, []int{-1}},
+		{"same content decides", []string{"1"}, []string{"1", "1"}, func(i, j int) bool { return j == 1 }, []int{1}},
+		{"used once", []string{"1", "1"}, []string{"1"}, func(i, j int) bool { return true }, []int{0, -1}},
+		{"empty key", []string{""}, []string{""}, func(i, j int) bool { return true }, []int{-1}},
+	}
+
+	for _, c := range cases {
+		matches := matchRenames(c.missing, c.candidates, c.same)
+		if fmt.Sprint(matches) != fmt.Sprint(c.expected) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.expected, matches)
+		}
+	}
+}
+
+func TestBidirectionalSyncDetectsRenames(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	rootId := server.AddFolder(drivetest.RootId, "sync")
+	dir := t.TempDir()
+	stateDir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"a.txt": "a", "b.txt": "b"})
+
+	bidirectionalSync := func() {
+		t.Helper()
+		err := gdrive.BidirectionalSync(BidirectionalSyncArgs{
+			Out:      ioutil.Discard,
+			Path:     dir,
+			RootId:   rootId,
+			Comparer: testComparer{},
+			StateDir: stateDir,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bidirectionalSync()
+	aId := server.Find(rootId, "a.txt").Id
+	bId := server.Find(rootId, "b.txt").Id
+
+	// Local rename into a new directory
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "a.txt"), filepath.Join(dir, "sub", "renamed.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Remote rename
+	server.Modify(bId, func(f *drive.File) {
+		f.Name = "moved.txt"
+	})
+
+	bidirectionalSync()
+
+	if f := server.Find(rootId, "sub/renamed.txt"); f == nil || f.Id != aId {
+		t.Fatalf("Expected the remote file to be moved, got %v", f)
+	}
+	if f := server.File(aId); f == nil || f.Trashed {
+		t.Fatalf("Expected the renamed remote file to be kept, got %v", f)
+	}
+	if server.Find(rootId, "a.txt") != nil {
+		t.Fatal("Expected a.txt to be gone remotely")
+	}
+
+	assertLocalFile(t, filepath.Join(dir, "moved.txt"), []byte("b"))
+	if _, err := os.Stat(filepath.Join(dir, "b.txt")); !os.IsNotExist(err) {
+		t.Fatalf("Expected b.txt to be moved locally, got %v", err)
+	}
+	if f := server.File(bId); f == nil || f.Trashed {
+		t.Fatalf("Expected the remotely renamed file to be kept, got %v", f)
+	}
+}
@@ -2,11 +2,11 @@ package drive
 
 import (
 	"bytes"
+	"crypto/md5"
 	"fmt"
 	"google.golang.org/api/drive/v3"
 	"google.golang.org/api/googleapi"
 	"io"
-	"os"
 	"path/filepath"
 	"sort"
 	"time"
@@ -19,57 +19,114 @@ type UploadSyncArgs struct {
 	RootId           string
 	DryRun           bool
 	DeleteExtraneous bool
+	Permanent        bool
 	ChunkSize        int64
 	Timeout          time.Duration
 	Resolution       ConflictResolution
 	Comparer         FileComparer
+	StateDir         string
+	Parallel         int
+	SessionDir       string
+	Verify           VerifyPolicy
+	PlanOut          string
+	Symlinks         SymlinkPolicy
+	SkipInvalid      bool
+	verified         *verifyStats
 }
 
-func (self *Drive) UploadSync(args UploadSyncArgs) error {
+func (self *Drive) UploadSync(args UploadSyncArgs) (err error) {
 	if args.ChunkSize > intMax()-1 {
 		return fmt.Errorf("Chunk size is to big, max chunk size for this computer is %d", intMax()-1)
 	}
 
-	fmt.Fprintln(args.Out, "Starting sync...")
+	// Plans are applied by reading the local files again
+	if args.PlanOut != "" && args.Symlinks == SymlinkPreserve {
+		return fmt.Errorf("Preserved symlinks are not supported by plans")
+	}
+
+	self.message(args.Out, "Starting sync...")
 	started := time.Now()
+	args.verified = &verifyStats{}
 
 	// Create root directory if it does not exist
-	rootDir, err := self.prepareSyncRoot(args)
+	rootDir, err := self.prepareSyncRoot(args.RootId)
 	if err != nil {
 		return err
 	}
 
-	fmt.Fprintln(args.Out, "Collecting local and remote file information...")
-	files, err := self.prepareSyncFiles(args.Path, rootDir, args.Comparer)
+	// Load the state of the previous sync
+	state, err := loadSyncState(args.StateDir, rootDir.Id, args.Path)
 	if err != nil {
 		return err
 	}
 
-	// Find missing and changed files
+	self.message(args.Out, "Collecting local and remote file information...")
+	files, err := self.prepareSyncFiles(args.Path, rootDir, args.Comparer, state, args.Symlinks, args.SkipInvalid)
+	if err != nil {
+		return err
+	}
+
+	// Exported google documents are never uploaded
+	files.prepareDocuments(nil)
+
+	// Find missing, renamed and changed files
 	changedFiles := files.filterChangedLocalFiles()
-	missingFiles := files.filterMissingRemoteFiles()
+	renamedFiles, missingFiles := files.findLocalRenames(files.filterMissingRemoteFiles())
+	if args.DeleteExtraneous {
+		renamedFiles, missingFiles = files.findLocalContentRenames(missingFiles, renamedFiles, args.Resolution)
+	}
 
-	fmt.Fprintf(args.Out, "Found %d local files and %d remote files\n", len(files.local), len(files.remote))
+	self.message(args.Out, "Found %d local files and %d remote files", len(files.local), len(files.remote))
 
 	// Ensure that there is enough free space on drive
-	if ok, msg := self.checkRemoteFreeSpace(missingFiles, changedFiles); !ok {
-		return fmt.Errorf(msg)
+	if ok, msg := self.checkRemoteFreeSpace(rootDir, missingFiles, changedFiles); !ok {
+		return fmt.Errorf("%s", msg)
 	}
 
 	// Ensure that we don't overwrite any remote changes
-	if args.Resolution == NoResolution {
+	if args.Resolution == nil {
 		err = ensureNoRemoteModifications(changedFiles)
 		if err != nil {
 			return fmt.Errorf("Conflict detected!\nThe following files have changed and the remote file are newer than it's local counterpart:\n\n%s\nNo conflict resolution was given, aborting...", err)
 		}
 	}
 
+	// Conflicts are resolved before anything is transferred,
+	// the resolution may have to ask the user
+	for _, cf := range changedFiles {
+		cf.action, cf.reason = checkRemoteConflict(cf, args.Resolution)
+	}
+
+	// Write the plan for later review instead of syncing
+	if args.PlanOut != "" {
+		return self.writeUploadPlan(args.PlanOut, files, renamedFiles, missingFiles, changedFiles, args)
+	}
+
+	// Persist the sync state, even if we fail halfway through
+	if !args.DryRun {
+		defer func() {
+			if saveErr := state.save(); err == nil {
+				err = saveErr
+			}
+		}()
+	}
+
+	// Refresh the state of files that are already in sync
+	files.recordUnchanged(changedFiles)
+	files.pruneState()
+
 	// Create missing directories
 	files, err = self.createMissingRemoteDirs(files, args)
 	if err != nil {
 		return err
 	}
 
+	// Move files that have been renamed locally
+	err = self.moveRenamedRemoteFiles(renamedFiles, files, args)
+	if err != nil {
+		return err
+	}
+
 	// Upload missing files
 	err = self.uploadMissingFiles(missingFiles, files, args)
 	if err != nil {
@@ -77,7 +134,7 @@ func (self *Drive) UploadSync(args UploadSyncArgs) error {
 	}
 
 	// Update modified files
-	err = self.updateChangedFiles(changedFiles, rootDir, args)
+	err = self.updateChangedFiles(changedFiles, files, args)
 	if err != nil {
 		return err
 	}
@@ -89,14 +146,16 @@ func (self *Drive) UploadSync(args UploadSyncArgs) error {
 			return err
 		}
 	}
-	fmt.Fprintf(args.Out, "Sync finished in %s\n", time.Since(started))
+	self.printVerifyStats(args.Out, args.verified)
+	self.printSkippedFiles(args.Out, files.skipped)
+	self.message(args.Out, "Sync finished in %s", time.Since(started))
 
 	return nil
 }
 
-func (self *Drive) prepareSyncRoot(args UploadSyncArgs) (*drive.File, error) {
+func (self *Drive) prepareSyncRoot(rootId string) (*drive.File, error) {
 	fields := []googleapi.Field{"id", "name", "mimeType", "appProperties"}
-	f, err := self.service.Files.Get(args.RootId).Fields(fields...).Do()
+	f, err := self.backend.GetFile(rootId, fields...)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to find root dir: %s", err)
 	}
@@ -106,6 +165,11 @@ func (self *Drive) prepareSyncRoot(args UploadSyncArgs) (*drive.File, error) {
 		return nil, fmt.Errorf("Provided root id is not a directory")
 	}
 
+	err = self.loadSyncDrive(f)
+	if err != nil {
+		return nil, err
+	}
+
 	// Return directory if syncRoot property is already set
 	if _, ok := f.AppProperties["syncRoot"]; ok {
 		return f, nil
@@ -113,7 +177,7 @@ func (self *Drive) prepareSyncRoot(args UploadSyncArgs) (*drive.File, error) {
 
 	// This is the first time this directory have been used for sync
 	// Check if the directory is empty
-	isEmpty, err := self.dirIsEmpty(f.Id)
+	isEmpty, err := self.dirIsEmpty(f)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to check if root dir is empty: %s", err)
 	}
@@ -128,7 +192,7 @@ func (self *Drive) prepareSyncRoot(args UploadSyncArgs) (*drive.File, error) {
 		AppProperties: map[string]string{"sync": "true", "syncRoot": "true"},
 	}
 
-	f, err = self.service.Files.Update(f.Id, dstFile).Fields(fields...).Do()
+	f, err = self.backend.UpdateFile(f.Id, dstFile, FileUpdate{Fields: fields})
 	if err != nil {
 		return nil, fmt.Errorf("Failed to update root directory: %s", err)
 	}
@@ -141,7 +205,7 @@ func (self *Drive) createMissingRemoteDirs(files *syncFiles, args UploadSyncArgs
 	missingCount := len(missingDirs)
 
 	if missingCount > 0 {
-		fmt.Fprintf(args.Out, "\n%d remote directories are missing\n", missingCount)
+		self.message(args.Out, "\n%d remote directories are missing", missingCount)
 	}
 
 	// Sort directories so that the dirs with the shortest path comes first
@@ -154,7 +218,11 @@ func (self *Drive) createMissingRemoteDirs(files *syncFiles, args UploadSyncArgs
 			return nil, fmt.Errorf("Could not find remote directory with path '%s'", parentPath)
 		}
 
-		fmt.Fprintf(args.Out, "[%04d/%04d] Creating directory %s\n", i+1, missingCount, filepath.Join(files.root.file.Name, lf.relPath))
+		self.event(args.Out, Event{
+			Action:  "mkdir",
+			Path:    filepath.Join(files.root.file.Name, lf.relPath),
+			Message: fmt.Sprintf("[%04d/%04d] Creating directory %s", i+1, missingCount, filepath.Join(files.root.file.Name, lf.relPath)),
+		})
 
 		f, err := self.createMissingRemoteDir(createMissingRemoteDirArgs{
 			name:     lf.info.Name(),
@@ -171,6 +239,7 @@ func (self *Drive) createMissingRemoteDirs(files *syncFiles, args UploadSyncArgs
 			relPath: lf.relPath,
 			file:    f,
 		})
+		files.state.update(lf.relPath, lf.info, f)
 	}
 
 	return files, nil
@@ -188,49 +257,156 @@ func (self *Drive) uploadMissingFiles(missingFiles []*LocalFile, files *syncFile
 	missingCount := len(missingFiles)
 
 	if missingCount > 0 {
-		fmt.Fprintf(args.Out, "\n%d remote files are missing\n", missingCount)
+		self.message(args.Out, "\n%d remote files are missing", missingCount)
 	}
 
-	for i, lf := range missingFiles {
+	out, progress, done := prepareParallelOutput(args.Parallel, args.Out, args.Progress, sumLocalSize(missingFiles))
+	defer done()
+
+	return runParallel(args.Parallel, missingCount, func(i int) error {
+		lf := missingFiles[i]
+
 		parentPath := parentFilePath(lf.relPath)
 		parent, ok := files.findRemoteByPath(parentPath)
 		if !ok {
 			return fmt.Errorf("Could not find remote directory with path '%s'", parentPath)
 		}
 
-		fmt.Fprintf(args.Out, "[%04d/%04d] Uploading %s -> %s\n", i+1, missingCount, lf.relPath, filepath.Join(files.root.file.Name, lf.relPath))
+		self.event(out, Event{
+			Action:  "upload",
+			Path:    lf.relPath,
+			Target:  filepath.Join(files.root.file.Name, lf.relPath),
+			Message: fmt.Sprintf("[%04d/%04d] Uploading %s -> %s", i+1, missingCount, lf.relPath, filepath.Join(files.root.file.Name, lf.relPath)),
+		})
+
+		workerArgs := args
+		workerArgs.Out = out
+		workerArgs.Progress = progress
+
+		f, err := self.uploadMissingFile(parent.file.Id, lf, workerArgs, 0)
+		if err != nil {
+			return err
+		}
+
+		if f != nil {
+			files.state.update(lf.relPath, lf.info, f)
+		}
+
+		return nil
+	})
+}
+
+func (self *Drive) moveRenamedRemoteFiles(renamedFiles []*renamedFile, files *syncFiles, args UploadSyncArgs) error {
+	renamedCount := len(renamedFiles)
+
+	if renamedCount > 0 {
+		self.message(args.Out, "\n%d local files has been renamed", renamedCount)
+	}
+
+	for i, rn := range renamedFiles {
+		parentPath := parentFilePath(rn.local.relPath)
+		parent, ok := files.findRemoteByPath(parentPath)
+		if !ok {
+			return fmt.Errorf("Could not find remote directory with path '%s'", parentPath)
+		}
+
+		oldPath := rn.remote.relPath
+		self.event(args.Out, Event{
+			Action:  "move",
+			Path:    filepath.Join(files.root.file.Name, oldPath),
+			Target:  filepath.Join(files.root.file.Name, rn.local.relPath),
+			Message: fmt.Sprintf("[%04d/%04d] Moving %s -> %s", i+1, renamedCount, filepath.Join(files.root.file.Name, oldPath), filepath.Join(files.root.file.Name, rn.local.relPath)),
+		})
 
-		err := self.uploadMissingFile(parent.file.Id, lf, args, 0)
+		f, err := self.moveRemoteFile(rn.remote, rn.local.info.Name(), parent.file.Id, args.DryRun, 0)
 		if err != nil {
 			return err
 		}
+
+		if f == nil {
+			continue
+		}
+
+		rn.remote.relPath = rn.local.relPath
+		rn.remote.file = f
+		files.state.remove(oldPath)
+		files.state.update(rn.local.relPath, rn.local.info, f)
 	}
 
 	return nil
 }
 
-func (self *Drive) updateChangedFiles(changedFiles []*changedFile, root *drive.File, args UploadSyncArgs) error {
+func (self *Drive) updateChangedFiles(changedFiles []*changedFile, files *syncFiles, args UploadSyncArgs) error {
 	changedCount := len(changedFiles)
 
 	if changedCount > 0 {
-		fmt.Fprintf(args.Out, "\n%d local files has changed\n", changedCount)
+		self.message(args.Out, "\n%d local files has changed", changedCount)
 	}
 
-	for i, cf := range changedFiles {
-		if skip, reason := checkRemoteConflict(cf, args.Resolution); skip {
-			fmt.Fprintf(args.Out, "[%04d/%04d] Skipping %s (%s)\n", i+1, changedCount, cf.local.relPath, reason)
-			continue
+	out, progress, done := prepareParallelOutput(args.Parallel, args.Out, args.Progress, sumChangedLocalSize(changedFiles))
+	defer done()
+
+	return runParallel(args.Parallel, changedCount, func(i int) error {
+		cf := changedFiles[i]
+
+		if cf.action == SkipConflict || cf.action == UseRemote {
+			self.event(out, Event{
+				Action:  "skip",
+				Path:    cf.local.relPath,
+				Message: fmt.Sprintf("[%04d/%04d] Skipping %s (%s)", i+1, changedCount, cf.local.relPath, cf.reason),
+			})
+			return nil
 		}
 
-		fmt.Fprintf(args.Out, "[%04d/%04d] Updating %s -> %s\n", i+1, changedCount, cf.local.relPath, filepath.Join(root.Name, cf.local.relPath))
+		self.event(out, Event{
+			Action:  "update",
+			Path:    cf.local.relPath,
+			Target:  filepath.Join(files.root.file.Name, cf.local.relPath),
+			Message: fmt.Sprintf("[%04d/%04d] Updating %s -> %s", i+1, changedCount, cf.local.relPath, filepath.Join(files.root.file.Name, cf.local.relPath)),
+		})
+
+		workerArgs := args
+		workerArgs.Out = out
+		workerArgs.Progress = progress
 
-		err := self.updateChangedFile(cf, args, 0)
+		var f *drive.File
+		var err error
+		if cf.action == UseBoth {
+			f, err = self.uploadRemoteConflict(cf, workerArgs)
+		} else {
+			f, err = self.updateChangedFile(cf, workerArgs, 0)
+		}
 		if err != nil {
 			return err
 		}
+
+		if f != nil {
+			files.state.update(cf.local.relPath, cf.local.info, f)
+		}
+
+		return nil
+	})
+}
+
+// Moves the remote file of a conflict out of the way and uploads the local
+// file in its place, the renamed copy is left on drive
+func (self *Drive) uploadRemoteConflict(cf *changedFile, args UploadSyncArgs) (*drive.File, error) {
+	parentId := cf.remote.file.Parents[0]
+	name := conflictName(cf.remote.file.Name, time.Now())
+
+	self.event(args.Out, Event{
+		Action:  "conflict",
+		Path:    cf.remote.relPath,
+		Target:  filepath.Join(filepath.Dir(cf.remote.relPath), name),
+		Id:      cf.remote.file.Id,
+		Message: fmt.Sprintf("Renaming conflicting remote file %s -> %s", cf.remote.relPath, filepath.Join(filepath.Dir(cf.remote.relPath), name)),
+	})
+
+	if _, err := self.moveRemoteFile(cf.remote, name, parentId, args.DryRun, 0); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return self.uploadMissingFile(parentId, cf.local, args, 0)
 }
 
 func (self *Drive) deleteExtraneousRemoteFiles(files *syncFiles, args UploadSyncArgs) error {
@@ -238,19 +414,37 @@ func (self *Drive) deleteExtraneousRemoteFiles(files *syncFiles, args UploadSync
 	extraneousCount := len(extraneousFiles)
 
 	if extraneousCount > 0 {
-		fmt.Fprintf(args.Out, "\n%d remote files are extraneous\n", extraneousCount)
+		self.message(args.Out, "\n%d remote files are extraneous", extraneousCount)
 	}
 
 	// Sort files so that the files with the longest path comes first
 	sort.Sort(sort.Reverse(byRemotePathLength(extraneousFiles)))
 
+	var skipped []string
+
 	for i, rf := range extraneousFiles {
-		fmt.Fprintf(args.Out, "[%04d/%04d] Deleting %s\n", i+1, extraneousCount, filepath.Join(files.root.file.Name, rf.relPath))
+		if skip, reason := checkExtraneousRemote(rf, files, skipped, args.Resolution); skip {
+			self.event(args.Out, Event{
+				Action:  "skip",
+				Path:    filepath.Join(files.root.file.Name, rf.relPath),
+				Message: fmt.Sprintf("[%04d/%04d] Skipping %s (%s)", i+1, extraneousCount, filepath.Join(files.root.file.Name, rf.relPath), reason),
+			})
+			skipped = append(skipped, rf.relPath)
+			continue
+		}
+
+		self.event(args.Out, Event{
+			Action:  "delete",
+			Path:    filepath.Join(files.root.file.Name, rf.relPath),
+			Message: fmt.Sprintf("[%04d/%04d] Deleting %s", i+1, extraneousCount, filepath.Join(files.root.file.Name, rf.relPath)),
+		})
 
 		err := self.deleteRemoteFile(rf, args, 0)
 		if err != nil {
 			return err
 		}
+
+		files.state.remove(rf.relPath)
 	}
 
 	return nil
@@ -268,7 +462,7 @@ func (self *Drive) createMissingRemoteDir(args createMissingRemoteDirArgs) (*dri
 		return dstFile, nil
 	}
 
-	f, err := self.service.Files.Create(dstFile).Do()
+	f, err := self.backend.CreateFile(dstFile, nil)
 	if err != nil {
 		if isBackendOrRateLimitError(err) && args.try < MaxErrorRetries {
 			exponentialBackoffSleep(args.try)
@@ -282,90 +476,172 @@ func (self *Drive) createMissingRemoteDir(args createMissingRemoteDirArgs) (*dri
 	return f, nil
 }
 
-func (self *Drive) uploadMissingFile(parentId string, lf *LocalFile, args UploadSyncArgs, try int) error {
+func (self *Drive) uploadMissingFile(parentId string, lf *LocalFile, args UploadSyncArgs, try int) (*drive.File, error) {
 	if args.DryRun {
-		return nil
+		return nil, nil
 	}
 
-	srcFile, err := os.Open(lf.absPath)
-	if err != nil {
-		return fmt.Errorf("Failed to open file: %s", err)
-	}
-
-	// Close file on function exit
-	defer srcFile.Close()
-
 	// Instantiate drive file
 	dstFile := &drive.File{
 		Name:          lf.info.Name(),
 		Parents:       []string{parentId},
-		AppProperties: map[string]string{"sync": "true", "syncRootId": args.RootId},
+		ModifiedTime:  localTime(lf.info.ModTime()),
+		AppProperties: map[string]string{"sync": "true", "syncRootId": args.RootId, "syncMode": formatFileMode(lf.info)},
+	}
+
+	if lf.isSymlink() {
+		dstFile.AppProperties["syncSymlink"] = lf.linkTarget
+	}
+
+	// Large files are uploaded in a session that can be resumed
+	if !lf.isSymlink() && self.useResumableUpload(args.SessionDir, lf.info.Size(), args.ChunkSize) {
+		f, hasher, err := self.uploadResumable(resumableUploadArgs{
+			progress:   args.Progress,
+			sessionDir: args.SessionDir,
+			path:       lf.absPath,
+			file:       dstFile,
+			fields:     syncFileFields,
+			chunkSize:  args.ChunkSize,
+			timeout:    args.Timeout,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return f, self.verifyUpload(f, lf.absPath, hasher, true, args.Verify, args.verified)
 	}
 
-	// Chunk size option
-	chunkSize := googleapi.ChunkSize(int(args.ChunkSize))
+	srcFile, err := lf.open()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open file: %s", err)
+	}
+
+	// Close file on function exit
+	defer srcFile.Close()
+
+	// Hash file content while it is uploaded
+	hasher := md5.New()
 
 	// Wrap file in progress reader
-	progressReader := getProgressReader(srcFile, args.Progress, lf.info.Size())
+	progressReader := getProgressReader(getMd5Reader(srcFile, hasher), args.Progress, lf.info.Size())
 
 	// Wrap reader in timeout reader
 	reader, ctx := getTimeoutReaderContext(progressReader, args.Timeout)
 
-	_, err = self.service.Files.Create(dstFile).Fields("id", "name", "size", "md5Checksum").Context(ctx).Media(reader, chunkSize).Do()
+	media := &Media{Reader: reader, ChunkSize: args.ChunkSize, Context: ctx}
+	f, err := self.backend.CreateFile(dstFile, media, syncFileFields...)
 	if err != nil {
 		if isBackendOrRateLimitError(err) && try < MaxErrorRetries {
 			exponentialBackoffSleep(try)
 			try++
 			return self.uploadMissingFile(parentId, lf, args, try)
 		} else if isTimeoutError(err) {
-			return fmt.Errorf("Failed to upload file: timeout, no data was transferred for %v", args.Timeout)
+			return nil, fmt.Errorf("Failed to upload file: timeout, no data was transferred for %v", args.Timeout)
 		} else {
-			return fmt.Errorf("Failed to upload file: %s", err)
+			return nil, fmt.Errorf("Failed to upload file: %s", err)
 		}
 	}
 
-	return nil
+	return f, self.verifyUpload(f, lf.absPath, hasher, true, args.Verify, args.verified)
 }
 
-func (self *Drive) updateChangedFile(cf *changedFile, args UploadSyncArgs, try int) error {
+func (self *Drive) updateChangedFile(cf *changedFile, args UploadSyncArgs, try int) (*drive.File, error) {
 	if args.DryRun {
-		return nil
+		return nil, nil
+	}
+
+	// Instantiate drive file
+	dstFile := &drive.File{
+		ModifiedTime:  localTime(cf.local.info.ModTime()),
+		AppProperties: map[string]string{"syncMode": formatFileMode(cf.local.info)},
+	}
+
+	// The target is cleared if a symlink is replaced by a file
+	if cf.local.isSymlink() || cf.remote.isSymlink() {
+		dstFile.AppProperties["syncSymlink"] = cf.local.linkTarget
+	}
+
+	// Large files are uploaded in a session that can be resumed
+	if !cf.local.isSymlink() && self.useResumableUpload(args.SessionDir, cf.local.info.Size(), args.ChunkSize) {
+		f, hasher, err := self.uploadResumable(resumableUploadArgs{
+			progress:   args.Progress,
+			sessionDir: args.SessionDir,
+			path:       cf.local.absPath,
+			fileId:     cf.remote.file.Id,
+			file:       dstFile,
+			fields:     syncFileFields,
+			chunkSize:  args.ChunkSize,
+			timeout:    args.Timeout,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return f, self.verifyUpload(f, cf.local.absPath, hasher, false, args.Verify, args.verified)
 	}
 
-	srcFile, err := os.Open(cf.local.absPath)
+	srcFile, err := cf.local.open()
 	if err != nil {
-		return fmt.Errorf("Failed to open file: %s", err)
+		return nil, fmt.Errorf("Failed to open file: %s", err)
 	}
 
 	// Close file on function exit
 	defer srcFile.Close()
 
-	// Instantiate drive file
-	dstFile := &drive.File{}
-
-	// Chunk size option
-	chunkSize := googleapi.ChunkSize(int(args.ChunkSize))
+	// Hash file content while it is uploaded
+	hasher := md5.New()
 
 	// Wrap file in progress reader
-	progressReader := getProgressReader(srcFile, args.Progress, cf.local.info.Size())
+	progressReader := getProgressReader(getMd5Reader(srcFile, hasher), args.Progress, cf.local.info.Size())
 
 	// Wrap reader in timeout reader
 	reader, ctx := getTimeoutReaderContext(progressReader, args.Timeout)
 
-	_, err = self.service.Files.Update(cf.remote.file.Id, dstFile).Context(ctx).Media(reader, chunkSize).Do()
+	update := FileUpdate{
+		Media:  &Media{Reader: reader, ChunkSize: args.ChunkSize, Context: ctx},
+		Fields: syncFileFields,
+	}
+	f, err := self.backend.UpdateFile(cf.remote.file.Id, dstFile, update)
 	if err != nil {
 		if isBackendOrRateLimitError(err) && try < MaxErrorRetries {
 			exponentialBackoffSleep(try)
 			try++
 			return self.updateChangedFile(cf, args, try)
 		} else if isTimeoutError(err) {
-			return fmt.Errorf("Failed to upload file: timeout, no data was transferred for %v", args.Timeout)
+			return nil, fmt.Errorf("Failed to upload file: timeout, no data was transferred for %v", args.Timeout)
 		} else {
-			return fmt.Errorf("Failed to update file: %s", err)
+			return nil, fmt.Errorf("Failed to update file: %s", err)
 		}
 	}
 
-	return nil
+	return f, self.verifyUpload(f, cf.local.absPath, hasher, false, args.Verify, args.verified)
+}
+
+func (self *Drive) moveRemoteFile(rf *RemoteFile, name, parentId string, dryRun bool, try int) (*drive.File, error) {
+	if dryRun {
+		return nil, nil
+	}
+
+	dstFile := &drive.File{Name: name}
+
+	update := FileUpdate{Fields: syncFileFields}
+	if len(rf.file.Parents) > 0 && rf.file.Parents[0] != parentId {
+		update.AddParents = []string{parentId}
+		update.RemoveParents = []string{rf.file.Parents[0]}
+	}
+
+	f, err := self.backend.UpdateFile(rf.file.Id, dstFile, update)
+	if err != nil {
+		if isBackendOrRateLimitError(err) && try < MaxErrorRetries {
+			exponentialBackoffSleep(try)
+			try++
+			return self.moveRemoteFile(rf, name, parentId, dryRun, try)
+		} else {
+			return nil, fmt.Errorf("Failed to move file: %s", err)
+		}
+	}
+
+	return f, nil
 }
 
 func (self *Drive) deleteRemoteFile(rf *RemoteFile, args UploadSyncArgs, try int) error {
@@ -373,7 +649,7 @@ func (self *Drive) deleteRemoteFile(rf *RemoteFile, args UploadSyncArgs, try int
 		return nil
 	}
 
-	err := self.service.Files.Delete(rf.file.Id).Do()
+	err := self.removeFile(rf.file.Id, args.Permanent)
 	if err != nil {
 		if isBackendOrRateLimitError(err) && try < MaxErrorRetries {
 			exponentialBackoffSleep(try)
@@ -387,55 +663,61 @@ func (self *Drive) deleteRemoteFile(rf *RemoteFile, args UploadSyncArgs, try int
 	return nil
 }
 
-func (self *Drive) dirIsEmpty(id string) (bool, error) {
-	query := fmt.Sprintf("'%s' in parents", id)
-	fileList, err := self.service.Files.List().Q(query).Do()
-	if err != nil {
-		return false, fmt.Errorf("Empty dir check failed: ", err)
+func (self *Drive) dirIsEmpty(dir *drive.File) (bool, error) {
+	query := fmt.Sprintf("'%s' in parents and trashed = false", dir.Id)
+	empty := true
+
+	// The first page is enough to tell
+	err := self.backend.ListFiles(FileQuery{Query: query, Fields: []googleapi.Field{"files(id)"}, PageSize: 1, DriveId: self.syncDriveId(dir)}, func(fl *drive.FileList) error {
+		empty = len(fl.Files) == 0
+		return errStopListing
+	})
+	if err != nil && err != errStopListing {
+		return false, fmt.Errorf("Empty dir check failed: %s", err)
 	}
 
-	return len(fileList.Files) == 0, nil
+	return empty, nil
 }
 
-func checkRemoteConflict(cf *changedFile, resolution ConflictResolution) (bool, string) {
-	// No conflict unless remote file was last modified
-	if cf.compareModTime() != RemoteLastModified {
-		return false, ""
+// Decides whether a changed file is uploaded, the local file is
+// used unless the remote file has been modified as well
+func checkRemoteConflict(cf *changedFile, resolution ConflictResolution) (ConflictAction, string) {
+	// No conflict unless remote file was modified
+	if !cf.remoteModified() {
+		return UseLocal, ""
 	}
 
-	// Don't skip if want to keep the local file
-	if resolution == KeepLocal {
-		return false, ""
+	// Default to being non-destructive and skip the file
+	if resolution == nil {
+		return SkipConflict, "conflicting file, no conflict resolution given"
 	}
 
-	// Skip if we want to keep the remote file
-	if resolution == KeepRemote {
-		return true, "conflicting file, keeping remote file"
-	}
-
-	if resolution == KeepLargest {
-		largest := cf.compareSize()
+	action, reason := resolution.Resolve(cf.local, cf.remote)
+	return action, "conflicting file, " + reason
+}
 
-		// Skip if the remote file is largest
-		if largest == RemoteLargestSize {
-			return true, "conflicting file, remote file is largest, keeping remote"
+// Extraneous remote files are only deleted if they were deleted locally,
+// files that were created or changed remotely since the last sync are kept
+// unless the local side should win
+func checkExtraneousRemote(rf *RemoteFile, files *syncFiles, skipped []string, resolution ConflictResolution) (bool, string) {
+	// Keep directories that still hold skipped files
+	for _, relPath := range skipped {
+		if isChildPath(rf.relPath, relPath) {
+			return true, "directory contains remote changes"
 		}
+	}
 
-		// Don't skip if the local file is largest
-		if largest == LocalLargestSize {
-			return false, ""
-		}
+	// Without any previous state everything extraneous is deleted
+	if files.state.isEmpty() || resolution == KeepLocal {
+		return false, ""
+	}
 
-		// Keep remote if both files have the same size
-		if largest == EqualSize {
-			return true, "conflicting file, file sizes are equal, keeping remote"
-		}
+	base, synced := files.state.get(rf.relPath)
+	if synced && !base.remoteChanged(rf) {
+		return false, ""
 	}
 
-	// The conditionals above should cover all cases,
-	// unless the programmer did something wrong,
-	// in which case we default to being non-destructive and skip the file
-	return true, "conflicting file, unhandled case"
+	return true, "created or changed remotely since last sync"
 }
 
 func ensureNoRemoteModifications(files []*changedFile) error {
@@ -446,21 +728,25 @@ func ensureNoRemoteModifications(files []*changedFile) error {
 
 	buffer := bytes.NewBufferString("")
 	formatConflicts(conflicts, buffer)
-	return fmt.Errorf(buffer.String())
+	return fmt.Errorf("%s", buffer.String())
 }
 
-func (self *Drive) checkRemoteFreeSpace(missingFiles []*LocalFile, changedFiles []*changedFile) (bool, string) {
-	about, err := self.service.About.Get().Fields("storageQuota").Do()
+func (self *Drive) checkRemoteFreeSpace(rootDir *drive.File, missingFiles []*LocalFile, changedFiles []*changedFile) (bool, string) {
+	// Files in shared drives do not count against the user's quota
+	if self.syncDriveId(rootDir) != "" {
+		return true, ""
+	}
+
+	limit, usage, err := self.backend.Quota()
 	if err != nil {
 		return false, fmt.Sprintf("Failed to determine free space: %s", err)
 	}
 
-	quota := about.StorageQuota
-	if quota.Limit == 0 {
+	if limit == 0 {
 		return true, ""
 	}
 
-	freeSpace := quota.Limit - quota.Usage
+	freeSpace := limit - usage
 
 	var totalSize int64
 
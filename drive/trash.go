@@ -0,0 +1,105 @@
+package drive
+
+import (
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"io"
+)
+
+type TrashArgs struct {
+	Out io.Writer
+	Id  string
+}
+
+func (self *Drive) Trash(args TrashArgs) error {
+	f, err := self.trashFile(args.Id)
+	if err != nil {
+		return err
+	}
+
+	self.event(args.Out, Event{Action: "trash", Path: f.Name, Id: f.Id, Message: fmt.Sprintf("Moved '%s' to trash", f.Name)})
+	return nil
+}
+
+func (self *Drive) Untrash(args TrashArgs) error {
+	// Trashed is false by default and has to be sent explicitly
+	dstFile := &drive.File{Trashed: false, ForceSendFields: []string{"Trashed"}}
+
+	f, err := self.backend.UpdateFile(args.Id, dstFile, FileUpdate{Fields: []googleapi.Field{"id", "name"}})
+	if err != nil {
+		return fmt.Errorf("Failed to restore file: %s", err)
+	}
+
+	self.event(args.Out, Event{Action: "untrash", Path: f.Name, Id: f.Id, Message: fmt.Sprintf("Restored '%s'", f.Name)})
+	return nil
+}
+
+type ListTrashArgs struct {
+	Out         io.Writer
+	MaxFiles    int64
+	NameWidth   int64
+	SkipHeader  bool
+	SizeInBytes bool
+}
+
+// Lists the files that were moved to trash, the content of trashed
+// directories is not listed separately
+func (self *Drive) ListTrash(args ListTrashArgs) error {
+	query := "trashed = true"
+
+	// Files in shared drives are owned by the drive
+	if self.rootId() == "root" {
+		query += " and 'me' in owners"
+	}
+
+	files, err := self.listAllFiles(listAllFilesArgs{
+		query:     query,
+		fields:    []googleapi.Field{"nextPageToken", "files(id,name,md5Checksum,mimeType,size,createdTime,parents,explicitlyTrashed)"},
+		sortOrder: "modifiedTime desc",
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to list trash: %s", err)
+	}
+
+	var trashed []*drive.File
+	for _, f := range files {
+		if f.ExplicitlyTrashed {
+			trashed = append(trashed, f)
+		}
+	}
+
+	if args.MaxFiles > 0 {
+		trashed = trashed[:min(len(trashed), int(args.MaxFiles))]
+	}
+
+	return self.printFiles(ListFilesArgs{
+		Out:         args.Out,
+		NameWidth:   args.NameWidth,
+		SkipHeader:  args.SkipHeader,
+		SizeInBytes: args.SizeInBytes,
+	}, trashed)
+}
+
+type EmptyTrashArgs struct {
+	Out io.Writer
+}
+
+func (self *Drive) EmptyTrash(args EmptyTrashArgs) error {
+	if err := self.backend.EmptyTrash(); err != nil {
+		return fmt.Errorf("Failed to empty trash: %s", err)
+	}
+
+	self.event(args.Out, Event{Action: "empty-trash", Message: "Trash emptied"})
+	return nil
+}
+
+func (self *Drive) trashFile(fileId string) (*drive.File, error) {
+	dstFile := &drive.File{Trashed: true}
+
+	f, err := self.backend.UpdateFile(fileId, dstFile, FileUpdate{Fields: []googleapi.Field{"id", "name"}})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to trash file: %s", err)
+	}
+	return f, nil
+}
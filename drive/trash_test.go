@@ -0,0 +1,89 @@
+package drive
+
+import (
+	"github.com/prasmussen/gdrive/drive/drivetest"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestDeleteMovesToTrash(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	dirId := server.AddFolder(drivetest.RootId, "dir")
+	fileId := server.AddFile(dirId, "a.txt", []byte("a"))
+
+	err := gdrive.Delete(DeleteArgs{Out: ioutil.Discard, Id: dirId, Recursive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f := server.File(fileId); f == nil || !f.Trashed {
+		t.Fatal("Expected the content of the directory to be trashed")
+	}
+
+	if err := gdrive.Untrash(TrashArgs{Out: ioutil.Discard, Id: dirId}); err != nil {
+		t.Fatal(err)
+	}
+
+	if server.File(fileId).Trashed {
+		t.Fatal("Expected the file to be restored with its directory")
+	}
+
+	err = gdrive.Delete(DeleteArgs{Out: ioutil.Discard, Id: dirId, Recursive: true, Permanent: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if server.File(fileId) != nil {
+		t.Fatal("Expected the file to be deleted permanently")
+	}
+}
+
+func TestEmptyTrash(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	trashedId := server.AddFile(drivetest.RootId, "trashed.txt", []byte("a"))
+	keptId := server.AddFile(drivetest.RootId, "kept.txt", []byte("b"))
+
+	if err := gdrive.Trash(TrashArgs{Out: ioutil.Discard, Id: trashedId}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := gdrive.EmptyTrash(EmptyTrashArgs{Out: ioutil.Discard}); err != nil {
+		t.Fatal(err)
+	}
+
+	if server.File(trashedId) != nil {
+		t.Fatal("Expected the trashed file to be deleted")
+	}
+
+	if server.File(keptId) == nil {
+		t.Fatal("Expected files outside the trash to be kept")
+	}
+}
+
+func TestDownloadSyncSkipsTrashedDirectory(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	rootId := server.AddFolder(drivetest.RootId, "sync")
+	src := t.TempDir()
+	stateDir := t.TempDir()
+
+	writeTestFiles(t, src, map[string]string{
+		"a.txt":     "a",
+		"dir/b.txt": "b",
+	})
+	uploadSync(t, gdrive, rootId, src, stateDir)
+
+	dst := t.TempDir()
+	downloadSync(t, gdrive, rootId, dst, stateDir)
+
+	// Only the directory itself shows up as a change
+	if err := gdrive.Trash(TrashArgs{Out: ioutil.Discard, Id: server.Find(rootId, "dir").Id}); err != nil {
+		t.Fatal(err)
+	}
+	downloadSync(t, gdrive, rootId, dst, stateDir)
+
+	assertLocalFile(t, filepath.Join(dst, "a.txt"), []byte("a"))
+	if _, err := ioutil.ReadFile(filepath.Join(dst, "dir", "b.txt")); err == nil {
+		t.Fatal("Expected the content of the trashed directory to be removed locally")
+	}
+}
@@ -1,6 +1,7 @@
 package drive
 
 import (
+	"crypto/md5"
 	"fmt"
 	"google.golang.org/api/drive/v3"
 	"google.golang.org/api/googleapi"
@@ -22,6 +23,7 @@ type UpdateArgs struct {
 	Recursive   bool
 	ChunkSize   int64
 	Timeout     time.Duration
+	Verify      VerifyPolicy
 }
 
 func (self *Drive) Update(args UpdateArgs) error {
@@ -52,19 +54,22 @@ func (self *Drive) Update(args UpdateArgs) error {
 	// Set parent folders
 	dstFile.Parents = args.Parents
 
-	// Chunk size option
-	chunkSize := googleapi.ChunkSize(int(args.ChunkSize))
+	// Hash file content while it is uploaded
+	hasher := md5.New()
 
 	// Wrap file in progress reader
-	progressReader := getProgressReader(srcFile, args.Progress, srcFileInfo.Size())
+	progressReader := getProgressReader(getMd5Reader(srcFile, hasher), args.Progress, srcFileInfo.Size())
 
 	// Wrap reader in timeout reader
 	reader, ctx := getTimeoutReaderContext(progressReader, args.Timeout)
 
-	fmt.Fprintf(args.Out, "Uploading %s\n", args.Path)
+	self.event(args.Out, Event{Action: "upload", Path: args.Path, Id: args.Id, Message: fmt.Sprintf("Uploading %s", args.Path)})
 	started := time.Now()
 
-	f, err := self.service.Files.Update(args.Id, dstFile).Fields("id", "name", "size").Context(ctx).Media(reader, chunkSize).Do()
+	f, err := self.backend.UpdateFile(args.Id, dstFile, FileUpdate{
+		Media:  &Media{Reader: reader, ChunkSize: args.ChunkSize, Context: ctx},
+		Fields: []googleapi.Field{"id", "name", "size", "md5Checksum"},
+	})
 	if err != nil {
 		if isTimeoutError(err) {
 			return fmt.Errorf("Failed to upload file: timeout, no data was transferred for %v", args.Timeout)
@@ -75,6 +80,17 @@ func (self *Drive) Update(args UpdateArgs) error {
 	// Calculate average upload rate
 	rate := calcRate(f.Size, started, time.Now())
 
-	fmt.Fprintf(args.Out, "Updated %s at %s/s, total %s\n", f.Id, formatSize(rate, false), formatSize(f.Size, false))
+	self.event(args.Out, Event{
+		Action:  "updated",
+		Id:      f.Id,
+		Size:    f.Size,
+		Message: fmt.Sprintf("Updated %s at %s/s, total %s", f.Id, formatSize(rate, false), formatSize(f.Size, false)),
+	})
+
+	verified := &verifyStats{}
+	if err = self.verifyUpload(f, args.Path, hasher, false, args.Verify, verified); err != nil {
+		return err
+	}
+	self.printVerifyStats(args.Out, verified)
 	return nil
 }
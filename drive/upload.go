@@ -1,6 +1,7 @@
 package drive
 
 import (
+	"crypto/md5"
 	"fmt"
 	"google.golang.org/api/drive/v3"
 	"google.golang.org/api/googleapi"
@@ -19,11 +20,16 @@ type UploadArgs struct {
 	Description string
 	Parents     []string
 	Mime        string
+	Properties  map[string]string
 	Recursive   bool
 	Share       bool
 	Delete      bool
 	ChunkSize   int64
 	Timeout     time.Duration
+	Parallel    int
+	SessionDir  string
+	Verify      VerifyPolicy
+	verified    *verifyStats
 }
 
 func (self *Drive) Upload(args UploadArgs) error {
@@ -43,8 +49,12 @@ func (self *Drive) Upload(args UploadArgs) error {
 		}
 	}
 
+	args.verified = &verifyStats{}
+
 	if args.Recursive {
-		return self.uploadRecursive(args)
+		err := self.uploadRecursive(args)
+		self.printVerifyStats(args.Out, args.verified)
+		return err
 	}
 
 	info, err := os.Stat(args.Path)
@@ -60,7 +70,13 @@ func (self *Drive) Upload(args UploadArgs) error {
 	if err != nil {
 		return err
 	}
-	fmt.Fprintf(args.Out, "Uploaded %s at %s/s, total %s\n", f.Id, formatSize(rate, false), formatSize(f.Size, false))
+	self.event(args.Out, Event{
+		Action:  "uploaded",
+		Id:      f.Id,
+		Size:    f.Size,
+		Message: fmt.Sprintf("Uploaded %s at %s/s, total %s", f.Id, formatSize(rate, false), formatSize(f.Size, false)),
+	})
+	self.printVerifyStats(args.Out, args.verified)
 
 	if args.Share {
 		err = self.shareAnyoneReader(f.Id)
@@ -68,7 +84,12 @@ func (self *Drive) Upload(args UploadArgs) error {
 			return err
 		}
 
-		fmt.Fprintf(args.Out, "File is readable by anyone at %s\n", f.WebContentLink)
+		self.event(args.Out, Event{
+			Action:  "share",
+			Id:      f.Id,
+			Target:  f.WebContentLink,
+			Message: fmt.Sprintf("File is readable by anyone at %s", f.WebContentLink),
+		})
 	}
 
 	if args.Delete {
@@ -76,7 +97,7 @@ func (self *Drive) Upload(args UploadArgs) error {
 		if err != nil {
 			return fmt.Errorf("Failed to delete file: %s", err)
 		}
-		fmt.Fprintf(args.Out, "Removed %s\n", args.Path)
+		self.event(args.Out, Event{Action: "remove", Path: args.Path, Message: fmt.Sprintf("Removed %s", args.Path)})
 	}
 
 	return nil
@@ -100,32 +121,56 @@ func (self *Drive) uploadRecursive(args UploadArgs) error {
 }
 
 func (self *Drive) uploadDirectory(args UploadArgs) error {
-	srcFile, srcFileInfo, err := openFile(args.Path)
+	uploads, err := self.prepareDirectoryUploads(args)
 	if err != nil {
 		return err
 	}
 
+	out, progress, done := prepareParallelOutput(args.Parallel, args.Out, args.Progress, 0)
+	defer done()
+
+	return runParallel(args.Parallel, len(uploads), func(i int) error {
+		uploadArgs := uploads[i]
+		uploadArgs.Out = out
+		uploadArgs.Progress = progress
+
+		_, _, err := self.uploadFile(uploadArgs)
+		return err
+	})
+}
+
+// Creates the directory tree on drive and returns the
+// arguments for uploading each of the files in it
+func (self *Drive) prepareDirectoryUploads(args UploadArgs) ([]UploadArgs, error) {
+	srcFile, srcFileInfo, err := openFile(args.Path)
+	if err != nil {
+		return nil, err
+	}
+
 	// Close file on function exit
 	defer srcFile.Close()
 
-	fmt.Fprintf(args.Out, "Creating directory %s\n", srcFileInfo.Name())
+	self.event(args.Out, Event{Action: "mkdir", Path: args.Path, Message: fmt.Sprintf("Creating directory %s", srcFileInfo.Name())})
 	// Make directory on drive
 	f, err := self.mkdir(MkdirArgs{
 		Out:         args.Out,
 		Name:        srcFileInfo.Name(),
 		Parents:     args.Parents,
 		Description: args.Description,
+		Properties:  args.Properties,
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Read files from directory
 	names, err := srcFile.Readdirnames(0)
 	if err != nil && err != io.EOF {
-		return fmt.Errorf("Failed reading directory: %s", err)
+		return nil, fmt.Errorf("Failed reading directory: %s", err)
 	}
 
+	var uploads []UploadArgs
+
 	for _, name := range names {
 		// Copy args and set new path and parents
 		newArgs := args
@@ -133,14 +178,23 @@ func (self *Drive) uploadDirectory(args UploadArgs) error {
 		newArgs.Parents = []string{f.Id}
 		newArgs.Description = ""
 
-		// Upload
-		err = self.uploadRecursive(newArgs)
+		info, err := os.Stat(newArgs.Path)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("Failed stat file: %s", err)
+		}
+
+		if info.IsDir() {
+			children, err := self.prepareDirectoryUploads(newArgs)
+			if err != nil {
+				return nil, err
+			}
+			uploads = append(uploads, children...)
+		} else if info.Mode().IsRegular() {
+			uploads = append(uploads, newArgs)
 		}
 	}
 
-	return nil
+	return uploads, nil
 }
 
 func (self *Drive) uploadFile(args UploadArgs) (*drive.File, int64, error) {
@@ -153,7 +207,7 @@ func (self *Drive) uploadFile(args UploadArgs) (*drive.File, int64, error) {
 	defer srcFile.Close()
 
 	// Instantiate empty drive file
-	dstFile := &drive.File{Description: args.Description}
+	dstFile := &drive.File{Description: args.Description, Properties: args.Properties}
 
 	// Use provided file name or use filename
 	if args.Name == "" {
@@ -170,21 +224,45 @@ func (self *Drive) uploadFile(args UploadArgs) (*drive.File, int64, error) {
 	}
 
 	// Set parent folders
-	dstFile.Parents = args.Parents
+	dstFile.Parents = self.parentsOrRoot(args.Parents)
+
+	fields := []googleapi.Field{"id", "name", "size", "md5Checksum", "webContentLink"}
+
+	// Large files are uploaded in a session that can be resumed
+	if self.useResumableUpload(args.SessionDir, srcFileInfo.Size(), args.ChunkSize) {
+		self.event(args.Out, Event{Action: "upload", Path: args.Path, Message: fmt.Sprintf("Uploading %s", args.Path)})
+		started := time.Now()
+
+		f, hasher, err := self.uploadResumable(resumableUploadArgs{
+			progress:   args.Progress,
+			sessionDir: args.SessionDir,
+			path:       args.Path,
+			file:       dstFile,
+			fields:     fields,
+			chunkSize:  args.ChunkSize,
+			timeout:    args.Timeout,
+		})
+		if err != nil {
+			return nil, 0, err
+		}
 
-	// Chunk size option
-	chunkSize := googleapi.ChunkSize(int(args.ChunkSize))
+		rate := calcRate(f.Size, started, time.Now())
+		return f, rate, self.verifyUpload(f, args.Path, hasher, true, args.Verify, args.verified)
+	}
+
+	// Hash file content while it is uploaded
+	hasher := md5.New()
 
 	// Wrap file in progress reader
-	progressReader := getProgressReader(srcFile, args.Progress, srcFileInfo.Size())
+	progressReader := getProgressReader(getMd5Reader(srcFile, hasher), args.Progress, srcFileInfo.Size())
 
 	// Wrap reader in timeout reader
 	reader, ctx := getTimeoutReaderContext(progressReader, args.Timeout)
 
-	fmt.Fprintf(args.Out, "Uploading %s\n", args.Path)
+	self.event(args.Out, Event{Action: "upload", Path: args.Path, Message: fmt.Sprintf("Uploading %s", args.Path)})
 	started := time.Now()
 
-	f, err := self.service.Files.Create(dstFile).Fields("id", "name", "size", "md5Checksum", "webContentLink").Context(ctx).Media(reader, chunkSize).Do()
+	f, err := self.backend.CreateFile(dstFile, &Media{Reader: reader, ChunkSize: args.ChunkSize, Context: ctx}, fields...)
 	if err != nil {
 		if isTimeoutError(err) {
 			return nil, 0, fmt.Errorf("Failed to upload file: timeout, no data was transferred for %v", args.Timeout)
@@ -195,7 +273,7 @@ func (self *Drive) uploadFile(args UploadArgs) (*drive.File, int64, error) {
 	// Calculate average upload rate
 	rate := calcRate(f.Size, started, time.Now())
 
-	return f, rate, nil
+	return f, rate, self.verifyUpload(f, args.Path, hasher, true, args.Verify, args.verified)
 }
 
 type UploadStreamArgs struct {
@@ -205,10 +283,12 @@ type UploadStreamArgs struct {
 	Description string
 	Parents     []string
 	Mime        string
+	Properties  map[string]string
 	Share       bool
 	ChunkSize   int64
 	Progress    io.Writer
 	Timeout     time.Duration
+	Verify      VerifyPolicy
 }
 
 func (self *Drive) UploadStream(args UploadStreamArgs) error {
@@ -217,7 +297,7 @@ func (self *Drive) UploadStream(args UploadStreamArgs) error {
 	}
 
 	// Instantiate empty drive file
-	dstFile := &drive.File{Name: args.Name, Description: args.Description}
+	dstFile := &drive.File{Name: args.Name, Description: args.Description, Properties: args.Properties}
 
 	// Set mime type if provided
 	if args.Mime != "" {
@@ -225,21 +305,21 @@ func (self *Drive) UploadStream(args UploadStreamArgs) error {
 	}
 
 	// Set parent folders
-	dstFile.Parents = args.Parents
+	dstFile.Parents = self.parentsOrRoot(args.Parents)
 
-	// Chunk size option
-	chunkSize := googleapi.ChunkSize(int(args.ChunkSize))
+	// Hash content while it is uploaded
+	hasher := md5.New()
 
 	// Wrap file in progress reader
-	progressReader := getProgressReader(args.In, args.Progress, 0)
+	progressReader := getProgressReader(getMd5Reader(args.In, hasher), args.Progress, 0)
 
 	// Wrap reader in timeout reader
 	reader, ctx := getTimeoutReaderContext(progressReader, args.Timeout)
 
-	fmt.Fprintf(args.Out, "Uploading %s\n", dstFile.Name)
+	self.event(args.Out, Event{Action: "upload", Path: dstFile.Name, Message: fmt.Sprintf("Uploading %s", dstFile.Name)})
 	started := time.Now()
 
-	f, err := self.service.Files.Create(dstFile).Fields("id", "name", "size", "webContentLink").Context(ctx).Media(reader, chunkSize).Do()
+	f, err := self.backend.CreateFile(dstFile, &Media{Reader: reader, ChunkSize: args.ChunkSize, Context: ctx}, "id", "name", "size", "md5Checksum", "webContentLink")
 	if err != nil {
 		if isTimeoutError(err) {
 			return fmt.Errorf("Failed to upload file: timeout, no data was transferred for %v", args.Timeout)
@@ -250,14 +330,31 @@ func (self *Drive) UploadStream(args UploadStreamArgs) error {
 	// Calculate average upload rate
 	rate := calcRate(f.Size, started, time.Now())
 
-	fmt.Fprintf(args.Out, "Uploaded %s at %s/s, total %s\n", f.Id, formatSize(rate, false), formatSize(f.Size, false))
+	self.event(args.Out, Event{
+		Action:  "uploaded",
+		Id:      f.Id,
+		Size:    f.Size,
+		Message: fmt.Sprintf("Uploaded %s at %s/s, total %s", f.Id, formatSize(rate, false), formatSize(f.Size, false)),
+	})
+
+	verified := &verifyStats{}
+	if err = self.verifyUpload(f, dstFile.Name, hasher, true, args.Verify, verified); err != nil {
+		return err
+	}
+	self.printVerifyStats(args.Out, verified)
+
 	if args.Share {
 		err = self.shareAnyoneReader(f.Id)
 		if err != nil {
 			return err
 		}
 
-		fmt.Fprintf(args.Out, "File is readable by anyone at %s\n", f.WebContentLink)
+		self.event(args.Out, Event{
+			Action:  "share",
+			Id:      f.Id,
+			Target:  f.WebContentLink,
+			Message: fmt.Sprintf("File is readable by anyone at %s", f.WebContentLink),
+		})
 	}
 	return nil
 }
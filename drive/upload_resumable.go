@@ -0,0 +1,553 @@
+package drive
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Upload sessions are valid for a week on drive
+const UploadSessionMaxAge = time.Hour * 24 * 7
+
+var errUploadSessionExpired = fmt.Errorf("Upload session has expired")
+
+var errUploadStalled = fmt.Errorf("No data was committed after %d attempts", MaxErrorRetries)
+
+// uploadSession holds a resumable upload session, it is persisted
+// after every committed chunk so that an interrupted upload can
+// be continued later, even by another gdrive process
+type uploadSession struct {
+	path     string
+	Target   string    `json:"target"`
+	Uri      string    `json:"uri"`
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Modified int64     `json:"modified"`
+	Offset   int64     `json:"offset"`
+	Created  time.Time `json:"created"`
+}
+
+type resumableUploadArgs struct {
+	progress   io.Writer
+	sessionDir string
+	path       string
+	fileId     string
+	file       *drive.File
+	fields     []googleapi.Field
+	chunkSize  int64
+	timeout    time.Duration
+}
+
+// Resumable sessions are only used for files that needs more than one chunk,
+// and only google drive knows about them
+func (self *Drive) useResumableUpload(sessionDir string, size, chunkSize int64) bool {
+	return self.google != nil && sessionDir != "" && chunkSize > 0 && size > chunkSize
+}
+
+// Uploads the file using a persisted resumable upload session. If a session
+// for the same file and target exists, the upload continues from the last
+// chunk that was committed by drive. The md5 hash of the uploaded content
+// is returned together with the file
+func (self *Drive) uploadResumable(args resumableUploadArgs) (*drive.File, hash.Hash, error) {
+	absPath, err := filepath.Abs(args.path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to determine absolute path: %s", err)
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed stat file: %s", err)
+	}
+
+	target := uploadSessionTarget(args.fileId, args.file)
+
+	session, err := loadUploadSession(args.sessionDir, absPath, target)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Discard session if the file has changed since it was started
+	if session != nil && !session.matches(info) {
+		session.remove()
+		session = nil
+	}
+
+	if session != nil {
+		f, err := self.refreshUploadSession(session)
+		if err == errUploadSessionExpired {
+			session.remove()
+			session = nil
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("Failed to resume upload: %s", err)
+		} else if f != nil {
+			return completeUploadSession(session, f)
+		}
+	}
+
+	if session == nil {
+		session, err = self.startUploadSession(args, absPath, target, info)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return self.uploadSessionChunks(session, args, 0)
+}
+
+func (self *Drive) uploadSessionChunks(session *uploadSession, args resumableUploadArgs, try int) (*drive.File, hash.Hash, error) {
+	f, hasher, err := self.sendUploadChunks(session, args)
+	if err == nil {
+		session.remove()
+		return f, hasher, nil
+	}
+
+	if isTimeoutError(err) {
+		return nil, nil, fmt.Errorf("Failed to upload file: timeout, no data was transferred for %v", args.timeout)
+	}
+
+	if err == errUploadStalled {
+		return nil, nil, fmt.Errorf("Failed to upload file: %s", err)
+	}
+
+	// Network errors are retried as well, the session tells us where to continue
+	_, isApiError := err.(*googleapi.Error)
+	if (isBackendOrRateLimitError(err) || !isApiError) && try < MaxErrorRetries {
+		exponentialBackoffSleep(try)
+		try++
+
+		f, err := self.refreshUploadSession(session)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to upload file: %s", err)
+		}
+
+		if f != nil {
+			return completeUploadSession(session, f)
+		}
+
+		return self.uploadSessionChunks(session, args, try)
+	}
+
+	return nil, nil, fmt.Errorf("Failed to upload file: %s", err)
+}
+
+// Removes a session that drive reports as complete, the content was not
+// hashed while it was sent so the local file is hashed instead
+func completeUploadSession(session *uploadSession, f *drive.File) (*drive.File, hash.Hash, error) {
+	session.remove()
+
+	hasher, err := md5File(session.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return f, hasher, nil
+}
+
+func (self *Drive) startUploadSession(args resumableUploadArgs, absPath, target string, info os.FileInfo) (*uploadSession, error) {
+	body, err := googleapi.WithoutDataWrapper.JSONReader(args.file)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode file metadata: %s", err)
+	}
+
+	params := url.Values{}
+	params.Set("uploadType", "resumable")
+	params.Set("alt", "json")
+	self.google.setFileOptions(params)
+	if len(args.fields) > 0 {
+		params.Set("fields", googleapi.CombineFields(args.fields))
+	}
+
+	method := "POST"
+	urls := googleapi.ResolveRelative(self.uploadBasePath(), "files")
+	if args.fileId != "" {
+		method = "PATCH"
+		urls = googleapi.ResolveRelative(self.uploadBasePath(), "files/"+args.fileId)
+	}
+
+	req, err := http.NewRequest(method, urls+"?"+params.Encode(), body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to start upload: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("X-Upload-Content-Length", strconv.FormatInt(info.Size(), 10))
+	if args.file.MimeType != "" {
+		req.Header.Set("X-Upload-Content-Type", args.file.MimeType)
+	}
+
+	res, err := self.google.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to start upload: %s", err)
+	}
+	defer googleapi.CloseBody(res)
+
+	if err := googleapi.CheckResponse(res); err != nil {
+		return nil, fmt.Errorf("Failed to start upload: %s", err)
+	}
+
+	session := &uploadSession{
+		path:     uploadSessionPath(args.sessionDir, absPath, target),
+		Target:   target,
+		Uri:      res.Header.Get("Location"),
+		Path:     absPath,
+		Size:     info.Size(),
+		Modified: info.ModTime().UnixNano(),
+		Created:  time.Now(),
+	}
+
+	if session.Uri == "" {
+		return nil, fmt.Errorf("Failed to start upload: no session uri was returned")
+	}
+
+	return session, session.save()
+}
+
+// Asks drive how much of the file has been committed and updates the
+// session offset. The file is returned if the upload is already complete
+func (self *Drive) refreshUploadSession(session *uploadSession) (*drive.File, error) {
+	req, err := http.NewRequest("PUT", session.Uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = 0
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", session.Size))
+
+	res, err := self.google.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer googleapi.CloseBody(res)
+
+	if res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone {
+		return nil, errUploadSessionExpired
+	}
+
+	if res.StatusCode == http.StatusPermanentRedirect {
+		session.Offset = committedOffset(res)
+		return nil, session.save()
+	}
+
+	return decodeUploadResponse(res)
+}
+
+func (self *Drive) sendUploadChunks(session *uploadSession, args resumableUploadArgs) (*drive.File, hash.Hash, error) {
+	srcFile, err := os.Open(session.Path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to open file: %s", err)
+	}
+
+	// Close file on function exit
+	defer srcFile.Close()
+
+	chunkSize := uploadChunkSize(args.chunkSize)
+
+	var hasher hash.Hash
+	var reader io.Reader
+	var ctx context.Context
+
+	// Number of partial commits in a row where drive did not commit anything
+	stalled := 0
+
+	for {
+		if reader == nil {
+			// Hash what has already been sent, this also moves to the offset
+			hasher, err = md5Prefix(srcFile, session.Offset)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			// Wrap file in progress reader
+			progressReader := getProgressReader(getMd5Reader(srcFile, hasher), args.progress, session.Size-session.Offset)
+
+			// Wrap reader in timeout reader
+			reader, ctx = getTimeoutReaderContext(progressReader, args.timeout)
+		}
+
+		start := session.Offset
+		n := min64(chunkSize, session.Size-start)
+
+		req, err := http.NewRequest("PUT", session.Uri, io.LimitReader(reader, n))
+		if err != nil {
+			return nil, nil, err
+		}
+		req.ContentLength = n
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+n-1, session.Size))
+
+		res, err := ctxhttp.Do(ctx, self.google.client, req)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if res.StatusCode != http.StatusPermanentRedirect {
+			defer googleapi.CloseBody(res)
+			f, err := decodeUploadResponse(res)
+			return f, hasher, err
+		}
+
+		googleapi.CloseBody(res)
+
+		session.Offset = committedOffset(res)
+		if err := session.save(); err != nil {
+			return nil, nil, err
+		}
+
+		if session.Offset == start+n {
+			stalled = 0
+			continue
+		}
+
+		if session.Offset > start {
+			stalled = 0
+		} else {
+			stalled++
+		}
+
+		if stalled >= MaxErrorRetries {
+			return nil, nil, errUploadStalled
+		}
+
+		// Start over from the committed offset if drive did not get the whole chunk
+		reader = nil
+	}
+}
+
+func (self *Drive) uploadBasePath() string {
+	return strings.Replace(self.google.service.BasePath, "/drive/v3/", "/upload/drive/v3/", 1)
+}
+
+func decodeUploadResponse(res *http.Response) (*drive.File, error) {
+	if err := googleapi.CheckResponse(res); err != nil {
+		return nil, err
+	}
+
+	f := &drive.File{}
+	if err := json.NewDecoder(res.Body).Decode(f); err != nil {
+		return nil, fmt.Errorf("Failed to decode file: %s", err)
+	}
+
+	return f, nil
+}
+
+// Returns the offset of the next byte drive expects, based
+// on the range header of a 308 response, i.e. 'bytes=0-1234'
+func committedOffset(res *http.Response) int64 {
+	rangeHeader := res.Header.Get("Range")
+	idx := strings.LastIndex(rangeHeader, "-")
+	if idx == -1 {
+		return 0
+	}
+
+	end, err := strconv.ParseInt(rangeHeader[idx+1:], 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return end + 1
+}
+
+// Chunks must be a multiple of 256 KiB
+func uploadChunkSize(size int64) int64 {
+	minSize := int64(googleapi.MinUploadChunkSize)
+	if size%minSize != 0 {
+		size += minSize - size%minSize
+	}
+	return size
+}
+
+func uploadSessionTarget(fileId string, f *drive.File) string {
+	if fileId != "" {
+		return "update:" + fileId
+	}
+	return "create:" + strings.Join(f.Parents, ",") + "/" + f.Name
+}
+
+func uploadSessionPath(dir, absPath, target string) string {
+	sum := sha1.Sum([]byte(absPath + "\n" + target))
+	return filepath.Join(dir, fmt.Sprintf("%x.json", sum))
+}
+
+func loadUploadSession(dir, absPath, target string) (*uploadSession, error) {
+	session, err := readUploadSession(uploadSessionPath(dir, absPath, target))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return session, err
+}
+
+func readUploadSession(path string) (*uploadSession, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Close file on function exit
+	defer f.Close()
+
+	session := &uploadSession{path: path}
+	err = json.NewDecoder(f).Decode(session)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read upload session %s: %s", path, err)
+	}
+
+	return session, nil
+}
+
+func listUploadSessions(dir string) ([]*uploadSession, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []*uploadSession
+	for _, path := range paths {
+		session, err := readUploadSession(path)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+func (self *uploadSession) save() error {
+	if err := mkdir(self.path); err != nil {
+		return fmt.Errorf("Failed to create upload session directory: %s", err)
+	}
+
+	data, err := json.Marshal(self)
+	if err != nil {
+		return fmt.Errorf("Failed to save upload session: %s", err)
+	}
+
+	// Write to tmp file first
+	tmpPath := self.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("Failed to save upload session: %s", err)
+	}
+
+	// Move file to correct path
+	return os.Rename(tmpPath, self.path)
+}
+
+func (self *uploadSession) remove() error {
+	return os.Remove(self.path)
+}
+
+// Returns true if the local file is the same as when the session was started
+func (self *uploadSession) matches(info os.FileInfo) bool {
+	return self.Size == info.Size() && self.Modified == info.ModTime().UnixNano()
+}
+
+// Returns true if the session can no longer be resumed
+func (self *uploadSession) isStale() bool {
+	if time.Since(self.Created) > UploadSessionMaxAge {
+		return true
+	}
+
+	info, err := os.Stat(self.Path)
+	return err != nil || !self.matches(info)
+}
+
+type UploadSessionsArgs struct {
+	Out        io.Writer
+	SessionDir string
+	Clean      bool
+	All        bool
+	SkipHeader bool
+	Renderer   Renderer
+}
+
+func UploadSessions(args UploadSessionsArgs) error {
+	renderer := args.Renderer
+	if renderer == nil {
+		renderer = tableRenderer{}
+	}
+
+	sessions, err := listUploadSessions(args.SessionDir)
+	if err != nil {
+		return fmt.Errorf("Failed to list upload sessions: %s", err)
+	}
+
+	if args.Clean {
+		for _, session := range sessions {
+			if !args.All && !session.isStale() {
+				continue
+			}
+
+			if err := session.remove(); err != nil {
+				return fmt.Errorf("Failed to remove upload session: %s", err)
+			}
+			renderer.RenderEvent(args.Out, Event{
+				Action:  "remove",
+				Path:    session.Path,
+				Target:  session.Target,
+				Message: fmt.Sprintf("Removed upload session for %s", session.Path),
+			})
+		}
+		return nil
+	}
+
+	records := make([]UploadSessionRecord, 0, len(sessions))
+	var rows [][]string
+	for _, session := range sessions {
+		status := "pending"
+		if session.isStale() {
+			status = "stale"
+		}
+
+		r := UploadSessionRecord{session.Path, session.Target, session.Offset, session.Size, session.Created.Format(time.RFC3339), status}
+		records = append(records, r)
+		rows = append(rows, []string{r.Path, r.Target, strconv.FormatInt(r.Uploaded, 10), strconv.FormatInt(r.Size, 10), r.Started, r.Status})
+	}
+
+	return renderer.Render(args.Out, Result{
+		Value:      records,
+		Header:     []string{"Path", "Target", "Uploaded", "Size", "Started", "Status"},
+		Rows:       rows,
+		SkipHeader: args.SkipHeader,
+		Table: func(out io.Writer) {
+			w := new(tabwriter.Writer)
+			w.Init(out, 0, 0, 3, ' ', 0)
+
+			if !args.SkipHeader {
+				fmt.Fprintln(w, "Path\tUploaded\tSize\tStarted\tStatus")
+			}
+
+			for _, r := range records {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+					r.Path,
+					formatSize(r.Uploaded, false),
+					formatSize(r.Size, false),
+					formatDatetime(r.Started),
+					r.Status,
+				)
+			}
+
+			w.Flush()
+		},
+	})
+}
+
+type UploadSessionRecord struct {
+	Path     string `json:"path"`
+	Target   string `json:"target"`
+	Uploaded int64  `json:"uploaded"`
+	Size     int64  `json:"size"`
+	Started  string `json:"started"`
+	Status   string `json:"status"`
+}
@@ -0,0 +1,192 @@
+package drive
+
+import (
+	"bytes"
+	"github.com/prasmussen/gdrive/drive/drivetest"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUploadFile(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"hello.txt": "hello world"})
+
+	out := &bytes.Buffer{}
+	err := gdrive.Upload(UploadArgs{
+		Out:     out,
+		Path:    filepath.Join(dir, "hello.txt"),
+		Parents: []string{"root"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertRemoteFile(t, server, drivetest.RootId, "hello.txt", []byte("hello world"))
+
+	if !strings.Contains(out.String(), "1 verified") {
+		t.Fatalf("Expected the upload to be verified, got output:\n%s", out)
+	}
+}
+
+func TestUploadRecursive(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"docs/a.txt":     "a",
+		"docs/sub/b.txt": "b",
+	})
+
+	err := gdrive.Upload(UploadArgs{
+		Out:       ioutil.Discard,
+		Path:      filepath.Join(dir, "docs"),
+		Recursive: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertRemoteFile(t, server, drivetest.RootId, "docs/a.txt", []byte("a"))
+	assertRemoteFile(t, server, drivetest.RootId, "docs/sub/b.txt", []byte("b"))
+}
+
+func TestUploadChunked(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	dir := t.TempDir()
+	content := largeContent(600 * 1024)
+	writeTestFiles(t, dir, map[string]string{"large.bin": string(content)})
+
+	err := gdrive.Upload(UploadArgs{
+		Out:       ioutil.Discard,
+		Path:      filepath.Join(dir, "large.bin"),
+		ChunkSize: 256 * 1024,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertRemoteFile(t, server, drivetest.RootId, "large.bin", content)
+}
+
+func TestUploadResumableSession(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	dir := t.TempDir()
+	sessionDir := t.TempDir()
+	content := largeContent(600 * 1024)
+	writeTestFiles(t, dir, map[string]string{"large.bin": string(content)})
+
+	// The first chunk fails once, the upload continues from the committed offset
+	server.Fail(drivetest.Fault{Method: "PUT", Path: "/upload/drive/v3/files", Status: http.StatusServiceUnavailable})
+
+	err := gdrive.Upload(UploadArgs{
+		Out:        ioutil.Discard,
+		Path:       filepath.Join(dir, "large.bin"),
+		ChunkSize:  256 * 1024,
+		SessionDir: sessionDir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertRemoteFile(t, server, drivetest.RootId, "large.bin", content)
+
+	// Three chunks, one failed chunk and a status request
+	if puts := countRequests(server, "PUT "); puts != 5 {
+		t.Fatalf("Expected 5 upload requests, got %d", puts)
+	}
+
+	sessions, err := listUploadSessions(sessionDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 0 {
+		t.Fatalf("Expected the upload session to be removed, found %d sessions", len(sessions))
+	}
+}
+
+func TestUploadResumablePartialCommits(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	dir := t.TempDir()
+	content := largeContent(600 * 1024)
+	writeTestFiles(t, dir, map[string]string{"large.bin": string(content)})
+
+	// Drive only gets part of every chunk, the upload continues from the committed offset
+	server.SetChunkLimit(100 * 1024)
+
+	err := gdrive.Upload(UploadArgs{
+		Out:        ioutil.Discard,
+		Path:       filepath.Join(dir, "large.bin"),
+		ChunkSize:  256 * 1024,
+		SessionDir: t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertRemoteFile(t, server, drivetest.RootId, "large.bin", content)
+}
+
+func TestUploadResumableFailsWithoutProgress(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"large.bin": string(largeContent(600 * 1024))})
+
+	server.SetChunkLimit(0)
+
+	err := gdrive.Upload(UploadArgs{
+		Out:        ioutil.Discard,
+		Path:       filepath.Join(dir, "large.bin"),
+		ChunkSize:  256 * 1024,
+		SessionDir: t.TempDir(),
+	})
+	if err == nil || !strings.Contains(err.Error(), "No data was committed") {
+		t.Fatalf("Expected the upload to fail without progress, got %v", err)
+	}
+
+	if puts := countRequests(server, "PUT "); puts != MaxErrorRetries {
+		t.Fatalf("Expected %d upload requests, got %d", MaxErrorRetries, puts)
+	}
+}
+
+func TestUploadStream(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+
+	err := gdrive.UploadStream(UploadStreamArgs{
+		Out:  ioutil.Discard,
+		In:   strings.NewReader("streamed"),
+		Name: "stream.txt",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertRemoteFile(t, server, drivetest.RootId, "stream.txt", []byte("streamed"))
+}
+
+func TestUpdateCreatesRevision(t *testing.T) {
+	gdrive, server := newTestDrive(t)
+	id := server.AddFile(drivetest.RootId, "notes.txt", []byte("v1"))
+
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"notes.txt": "v2"})
+
+	err := gdrive.Update(UpdateArgs{
+		Out:  ioutil.Discard,
+		Id:   id,
+		Path: filepath.Join(dir, "notes.txt"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if content := server.Content(id); string(content) != "v2" {
+		t.Fatalf("Expected updated content, got %q", content)
+	}
+
+	if revisions := server.Revisions(id); len(revisions) != 2 {
+		t.Fatalf("Expected 2 revisions, got %d", len(revisions))
+	}
+}
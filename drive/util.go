@@ -5,6 +5,7 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -20,6 +21,16 @@ func formatList(a []string) string {
 	return strings.Join(a, ", ")
 }
 
+// Formats properties as key=value pairs sorted by key
+func formatProperties(props map[string]string) string {
+	var pairs []string
+	for key, value := range props {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return formatList(pairs)
+}
+
 func formatSize(bytes int64, forceBytes bool) string {
 	if bytes == 0 {
 		return ""
@@ -154,6 +165,13 @@ func min(x int, y int) int {
 	return int(n)
 }
 
+func min64(x int64, y int64) int64 {
+	if x < y {
+		return x
+	}
+	return y
+}
+
 func openFile(path string) (*os.File, os.FileInfo, error) {
 	f, err := os.Open(path)
 	if err != nil {
@@ -0,0 +1,148 @@
+package drive
+
+import (
+	"crypto/md5"
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"hash"
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+type VerifyPolicy int
+
+const (
+	// Verify checksums and remove files that fails verification
+	VerifyRemove VerifyPolicy = iota
+
+	// Verify checksums and keep files that fails verification
+	VerifyKeep
+
+	// Don't verify checksums
+	VerifyOff
+)
+
+// verifyStats counts verified and unverified transfers,
+// it is safe to use from several goroutines
+type verifyStats struct {
+	verified   int64
+	unverified int64
+}
+
+func (self *verifyStats) record(verified bool) {
+	if self == nil {
+		return
+	}
+
+	if verified {
+		atomic.AddInt64(&self.verified, 1)
+	} else {
+		atomic.AddInt64(&self.unverified, 1)
+	}
+}
+
+func (self *Drive) printVerifyStats(out io.Writer, stats *verifyStats) {
+	if stats == nil {
+		return
+	}
+
+	verified := atomic.LoadInt64(&stats.verified)
+	unverified := atomic.LoadInt64(&stats.unverified)
+	if verified == 0 && unverified == 0 {
+		return
+	}
+
+	self.message(out, "Checksums: %d verified, %d unverified", verified, unverified)
+}
+
+// Hashes everything that is read from r
+func getMd5Reader(r io.Reader, hasher hash.Hash) io.Reader {
+	return io.TeeReader(r, hasher)
+}
+
+// Returns a hash of the first n bytes of f, leaving the read offset at n
+func md5Prefix(f *os.File, n int64) (hash.Hash, error) {
+	hasher := md5.New()
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("Failed to seek file: %s", err)
+	}
+
+	if _, err := io.CopyN(hasher, f, n); err != nil {
+		return nil, fmt.Errorf("Failed to read file: %s", err)
+	}
+
+	return hasher, nil
+}
+
+func md5File(path string) (hash.Hash, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open file: %s", err)
+	}
+
+	// Close file on function exit
+	defer f.Close()
+
+	hasher := md5.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return nil, fmt.Errorf("Failed to read file: %s", err)
+	}
+
+	return hasher, nil
+}
+
+func formatMd5(hasher hash.Hash) string {
+	return fmt.Sprintf("%x", hasher.Sum(nil))
+}
+
+// Moves a verified download into place. A download that fails
+// verification is either removed or kept, depending on the policy
+func finishDownload(tmpPath, fpath string, hasher hash.Hash, expectedMd5 string, policy VerifyPolicy, stats *verifyStats) error {
+	if policy == VerifyOff || expectedMd5 == "" {
+		stats.record(false)
+		return os.Rename(tmpPath, fpath)
+	}
+
+	actualMd5 := formatMd5(hasher)
+	if actualMd5 == expectedMd5 {
+		stats.record(true)
+		return os.Rename(tmpPath, fpath)
+	}
+
+	if policy == VerifyKeep {
+		if err := os.Rename(tmpPath, fpath); err != nil {
+			return err
+		}
+		return fmt.Errorf("Checksum mismatch for %s, expected md5 %s got %s, the file was kept", fpath, expectedMd5, actualMd5)
+	}
+
+	os.Remove(tmpPath)
+	return fmt.Errorf("Checksum mismatch for %s, expected md5 %s got %s, the file was removed", fpath, expectedMd5, actualMd5)
+}
+
+// Compares the md5 of the uploaded content with the one calculated by drive.
+// A newly created file that fails verification is removed if the policy says so,
+// new revisions of existing files are always kept
+func (self *Drive) verifyUpload(f *drive.File, path string, hasher hash.Hash, created bool, policy VerifyPolicy, stats *verifyStats) error {
+	if policy == VerifyOff || f.Md5Checksum == "" {
+		stats.record(false)
+		return nil
+	}
+
+	actualMd5 := formatMd5(hasher)
+	if actualMd5 == f.Md5Checksum {
+		stats.record(true)
+		return nil
+	}
+
+	if policy == VerifyRemove && created {
+		if err := self.deleteFile(f.Id); err != nil {
+			return fmt.Errorf("Checksum mismatch for %s, expected md5 %s got %s, failed to remove the remote file: %s", path, actualMd5, f.Md5Checksum, err)
+		}
+		return fmt.Errorf("Checksum mismatch for %s, expected md5 %s got %s, the remote file was removed", path, actualMd5, f.Md5Checksum)
+	}
+
+	return fmt.Errorf("Checksum mismatch for %s, expected md5 %s got %s, the remote file was kept", path, actualMd5, f.Md5Checksum)
+}
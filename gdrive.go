@@ -16,9 +16,14 @@ const DefaultNameWidth = 40
 const DefaultPathWidth = 60
 const DefaultUploadChunkSize = 8 * 1024 * 1024
 const DefaultTimeout = 5 * 60
+const DefaultParallel = 1
 const DefaultQuery = "trashed = false and 'me' in owners"
+const DefaultSharedDriveQuery = "trashed = false"
 const DefaultShareRole = "reader"
 const DefaultShareType = "anyone"
+const DefaultVerifyPolicy = "remove"
+const DefaultSymlinkPolicy = "skip"
+const DefaultOutput = "table"
 
 var DefaultConfigDir = GetDefaultConfigDir()
 
@@ -45,6 +50,22 @@ func main() {
 			Patterns:    []string{"--service-account"},
 			Description: "Oauth service account filename, used for server to server communication without user interaction (filename path is relative to config dir)",
 		},
+		cli.StringFlag{
+			Name:        "driveId",
+			Patterns:    []string{"--drive"},
+			Description: "Id of shared drive to work in, see drives list",
+		},
+		cli.StringFlag{
+			Name:        "localBackend",
+			Patterns:    []string{"--local-backend"},
+			Description: "Use a local directory instead of google drive, no authentication needed. Sharing, revisions, import and export are not available",
+		},
+		cli.StringFlag{
+			Name:         "output",
+			Patterns:     []string{"--output"},
+			Description:  fmt.Sprintf("Output format: table, json, jsonl or csv, default: %s", DefaultOutput),
+			DefaultValue: DefaultOutput,
+		},
 	}
 
 	handlers := []*cli.Handler{
@@ -121,9 +142,20 @@ func main() {
 					cli.BoolFlag{
 						Name:        "recursive",
 						Patterns:    []string{"-r", "--recursive"},
-						Description: "Download directory recursively, documents will be skipped",
+						Description: "Download directory recursively, documents are skipped unless --export is given",
 						OmitValue:   true,
 					},
+					cli.BoolFlag{
+						Name:        "export",
+						Patterns:    []string{"--export"},
+						Description: "Export google documents instead of skipping them",
+						OmitValue:   true,
+					},
+					cli.StringSliceFlag{
+						Name:        "exportMime",
+						Patterns:    []string{"--export-mime"},
+						Description: "Export mime per document type as type=mime, i.e. document=application/vnd.oasis.opendocument.text, can be specified multiple times",
+					},
 					cli.StringFlag{
 						Name:        "path",
 						Patterns:    []string{"--path"},
@@ -132,7 +164,13 @@ func main() {
 					cli.BoolFlag{
 						Name:        "delete",
 						Patterns:    []string{"--delete"},
-						Description: "Delete remote file when download is successful",
+						Description: "Move remote file to trash when download is successful",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "permanent",
+						Patterns:    []string{"--permanent"},
+						Description: "Delete remote file permanently instead of moving it to trash, used with --delete",
 						OmitValue:   true,
 					},
 					cli.BoolFlag{
@@ -141,6 +179,12 @@ func main() {
 						Description: "Hide progress",
 						OmitValue:   true,
 					},
+					cli.BoolFlag{
+						Name:        "resume",
+						Patterns:    []string{"--resume"},
+						Description: "Resume interrupted download, the partial file is kept on failure and the result is verified against the remote md5",
+						OmitValue:   true,
+					},
 					cli.BoolFlag{
 						Name:        "stdout",
 						Patterns:    []string{"--stdout"},
@@ -153,6 +197,18 @@ func main() {
 						Description:  fmt.Sprintf("Set timeout in seconds, use 0 for no timeout. Timeout is reached when no data is transferred in set amount of seconds, default: %d", DefaultTimeout),
 						DefaultValue: DefaultTimeout,
 					},
+					cli.IntFlag{
+						Name:         "parallel",
+						Patterns:     []string{"--parallel"},
+						Description:  fmt.Sprintf("Number of files to transfer concurrently, default: %d", DefaultParallel),
+						DefaultValue: DefaultParallel,
+					},
+					cli.StringFlag{
+						Name:         "verify",
+						Patterns:     []string{"--verify"},
+						Description:  fmt.Sprintf("What to do with files that fail md5 verification: remove, keep or off, default: %s", DefaultVerifyPolicy),
+						DefaultValue: DefaultVerifyPolicy,
+					},
 				),
 			},
 		},
@@ -178,9 +234,20 @@ func main() {
 					cli.BoolFlag{
 						Name:        "recursive",
 						Patterns:    []string{"-r", "--recursive"},
-						Description: "Download directories recursively, documents will be skipped",
+						Description: "Download directories recursively, documents are skipped unless --export is given",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "export",
+						Patterns:    []string{"--export"},
+						Description: "Export google documents instead of skipping them",
 						OmitValue:   true,
 					},
+					cli.StringSliceFlag{
+						Name:        "exportMime",
+						Patterns:    []string{"--export-mime"},
+						Description: "Export mime per document type as type=mime, i.e. document=application/vnd.oasis.opendocument.text, can be specified multiple times",
+					},
 					cli.StringFlag{
 						Name:        "path",
 						Patterns:    []string{"--path"},
@@ -192,6 +259,46 @@ func main() {
 						Description: "Hide progress",
 						OmitValue:   true,
 					},
+					cli.IntFlag{
+						Name:         "parallel",
+						Patterns:     []string{"--parallel"},
+						Description:  fmt.Sprintf("Number of files to transfer concurrently, default: %d", DefaultParallel),
+						DefaultValue: DefaultParallel,
+					},
+					cli.StringFlag{
+						Name:         "verify",
+						Patterns:     []string{"--verify"},
+						Description:  fmt.Sprintf("What to do with files that fail md5 verification: remove, keep or off, default: %s", DefaultVerifyPolicy),
+						DefaultValue: DefaultVerifyPolicy,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] upload resume [options]",
+			Description: "List or clean up pending resumable uploads",
+			Callback:    uploadSessionsHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.BoolFlag{
+						Name:        "clean",
+						Patterns:    []string{"--clean"},
+						Description: "Remove stale upload sessions, i.e. expired sessions or sessions where the local file has changed",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "all",
+						Patterns:    []string{"--all"},
+						Description: "Remove all upload sessions when used with --clean",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "skipHeader",
+						Patterns:    []string{"--no-header"},
+						Description: "Dont print the header",
+						OmitValue:   true,
+					},
 				),
 			},
 		},
@@ -211,7 +318,12 @@ func main() {
 					cli.StringSliceFlag{
 						Name:        "parent",
 						Patterns:    []string{"-p", "--parent"},
-						Description: "Parent id, used to upload file to a specific directory, can be specified multiple times to give many parents",
+						Description: "Parent id or drive:/path, used to upload file to a specific directory, can be specified multiple times to give many parents",
+					},
+					cli.StringSliceFlag{
+						Name:        "property",
+						Patterns:    []string{"--property"},
+						Description: "Set property as key=value, can be specified multiple times",
 					},
 					cli.StringFlag{
 						Name:        "name",
@@ -258,6 +370,18 @@ func main() {
 						Description:  fmt.Sprintf("Set chunk size in bytes, default: %d", DefaultUploadChunkSize),
 						DefaultValue: DefaultUploadChunkSize,
 					},
+					cli.IntFlag{
+						Name:         "parallel",
+						Patterns:     []string{"--parallel"},
+						Description:  fmt.Sprintf("Number of files to transfer concurrently, default: %d", DefaultParallel),
+						DefaultValue: DefaultParallel,
+					},
+					cli.StringFlag{
+						Name:         "verify",
+						Patterns:     []string{"--verify"},
+						Description:  fmt.Sprintf("What to do with files that fail md5 verification: remove, keep or off, default: %s", DefaultVerifyPolicy),
+						DefaultValue: DefaultVerifyPolicy,
+					},
 				),
 			},
 		},
@@ -271,7 +395,12 @@ func main() {
 					cli.StringSliceFlag{
 						Name:        "parent",
 						Patterns:    []string{"-p", "--parent"},
-						Description: "Parent id, used to upload file to a specific directory, can be specified multiple times to give many parents",
+						Description: "Parent id or drive:/path, used to upload file to a specific directory, can be specified multiple times to give many parents",
+					},
+					cli.StringSliceFlag{
+						Name:        "property",
+						Patterns:    []string{"--property"},
+						Description: "Set property as key=value, can be specified multiple times",
 					},
 					cli.IntFlag{
 						Name:         "chunksize",
@@ -307,6 +436,12 @@ func main() {
 						Description: "Hide progress",
 						OmitValue:   true,
 					},
+					cli.StringFlag{
+						Name:         "verify",
+						Patterns:     []string{"--verify"},
+						Description:  fmt.Sprintf("What to do with files that fail md5 verification: remove, keep or off, default: %s", DefaultVerifyPolicy),
+						DefaultValue: DefaultVerifyPolicy,
+					},
 				),
 			},
 		},
@@ -320,7 +455,7 @@ func main() {
 					cli.StringSliceFlag{
 						Name:        "parent",
 						Patterns:    []string{"-p", "--parent"},
-						Description: "Parent id, used to upload file to a specific directory, can be specified multiple times to give many parents",
+						Description: "Parent id or drive:/path, used to upload file to a specific directory, can be specified multiple times to give many parents",
 					},
 					cli.StringFlag{
 						Name:        "name",
@@ -355,6 +490,12 @@ func main() {
 						Description:  fmt.Sprintf("Set chunk size in bytes, default: %d", DefaultUploadChunkSize),
 						DefaultValue: DefaultUploadChunkSize,
 					},
+					cli.StringFlag{
+						Name:         "verify",
+						Patterns:     []string{"--verify"},
+						Description:  fmt.Sprintf("What to do with files that fail md5 verification: remove, keep or off, default: %s", DefaultVerifyPolicy),
+						DefaultValue: DefaultVerifyPolicy,
+					},
 				),
 			},
 		},
@@ -384,7 +525,12 @@ func main() {
 					cli.StringSliceFlag{
 						Name:        "parent",
 						Patterns:    []string{"-p", "--parent"},
-						Description: "Parent id of created directory, can be specified multiple times to give many parents",
+						Description: "Parent id or drive:/path of created directory, can be specified multiple times to give many parents",
+					},
+					cli.StringSliceFlag{
+						Name:        "property",
+						Patterns:    []string{"--property"},
+						Description: "Set property as key=value, can be specified multiple times",
 					},
 					cli.StringFlag{
 						Name:        "description",
@@ -467,6 +613,218 @@ func main() {
 						Description: "Delete directory and all it's content",
 						OmitValue:   true,
 					},
+					cli.BoolFlag{
+						Name:        "permanent",
+						Patterns:    []string{"--permanent"},
+						Description: "Delete permanently instead of moving to trash",
+						OmitValue:   true,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] trash list [options]",
+			Description: "List files in trash",
+			Callback:    listTrashHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.IntFlag{
+						Name:         "maxFiles",
+						Patterns:     []string{"-m", "--max"},
+						Description:  fmt.Sprintf("Max files to list, default: %d", DefaultMaxFiles),
+						DefaultValue: DefaultMaxFiles,
+					},
+					cli.IntFlag{
+						Name:         "nameWidth",
+						Patterns:     []string{"--name-width"},
+						Description:  fmt.Sprintf("Width of name column, default: %d, minimum: 9, use 0 for full width", DefaultNameWidth),
+						DefaultValue: DefaultNameWidth,
+					},
+					cli.BoolFlag{
+						Name:        "skipHeader",
+						Patterns:    []string{"--no-header"},
+						Description: "Dont print the header",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "sizeInBytes",
+						Patterns:    []string{"--bytes"},
+						Description: "Size in bytes",
+						OmitValue:   true,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] trash empty",
+			Description: "Permanently delete all files in trash",
+			Callback:    emptyTrashHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] trash <fileId>",
+			Description: "Move file or directory to trash",
+			Callback:    trashHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] untrash <fileId>",
+			Description: "Restore file or directory from trash",
+			Callback:    untrashHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] copy [options] <fileId>",
+			Description: "Copy file or directory server-side",
+			Callback:    copyHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.StringFlag{
+						Name:        "parent",
+						Patterns:    []string{"-p", "--parent"},
+						Description: "Parent id of the copy, defaults to the parent of the original",
+					},
+					cli.StringFlag{
+						Name:        "name",
+						Patterns:    []string{"--name"},
+						Description: "Name of the copy, defaults to the name of the original",
+					},
+					cli.BoolFlag{
+						Name:        "recursive",
+						Patterns:    []string{"-r", "--recursive"},
+						Description: "Copy directory and all it's content",
+						OmitValue:   true,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] move [options] <fileId>",
+			Description: "Move file or directory to another directory",
+			Callback:    moveHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.StringFlag{
+						Name:        "parent",
+						Patterns:    []string{"-p", "--parent"},
+						Description: "Id of the new parent directory",
+					},
+					cli.BoolFlag{
+						Name:        "recursive",
+						Patterns:    []string{"-r", "--recursive"},
+						Description: "Move directory and all it's content",
+						OmitValue:   true,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] rename <fileId> <newName>",
+			Description: "Rename file or directory",
+			Callback:    renameHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] meta set [options] <fileId>",
+			Description: "Update file metadata without uploading new content",
+			Callback:    metaSetHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.StringFlag{
+						Name:        "name",
+						Patterns:    []string{"--name"},
+						Description: "New file name",
+					},
+					cli.StringFlag{
+						Name:        "description",
+						Patterns:    []string{"--description"},
+						Description: "New file description",
+					},
+					cli.StringFlag{
+						Name:        "starred",
+						Patterns:    []string{"--starred"},
+						Description: "Star or unstar the file, true/false",
+					},
+					cli.StringFlag{
+						Name:        "mime",
+						Patterns:    []string{"--mime"},
+						Description: "New mime type",
+					},
+					cli.StringSliceFlag{
+						Name:        "property",
+						Patterns:    []string{"--property"},
+						Description: "Set property as key=value, can be specified multiple times",
+					},
+					cli.StringSliceFlag{
+						Name:        "appProperty",
+						Patterns:    []string{"--app-property"},
+						Description: "Set app property as key=value, can be specified multiple times",
+					},
+					cli.StringFlag{
+						Name:        "modifiedTime",
+						Patterns:    []string{"--modified-time"},
+						Description: "New modification time, i.e. 2016-01-02T15:04:05Z",
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] props get [options] <fileId>",
+			Description: "Show properties and app properties of file",
+			Callback:    propsGetHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.BoolFlag{
+						Name:        "skipHeader",
+						Patterns:    []string{"--no-header"},
+						Description: "Dont print the header",
+						OmitValue:   true,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] props set [options] <fileId> <key> <value>",
+			Description: "Set property of file",
+			Callback:    propsSetHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.BoolFlag{
+						Name:        "app",
+						Patterns:    []string{"--app"},
+						Description: "Use app properties instead of properties",
+						OmitValue:   true,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] props delete [options] <fileId> <key>",
+			Description: "Delete property of file",
+			Callback:    propsDeleteHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.BoolFlag{
+						Name:        "app",
+						Patterns:    []string{"--app"},
+						Description: "Use app properties instead of properties",
+						OmitValue:   true,
+					},
 				),
 			},
 		},
@@ -519,6 +877,34 @@ func main() {
 				),
 			},
 		},
+		&cli.Handler{
+			Pattern:     "[global] sync doctor [options] <fileId>",
+			Description: "Find and repair name collisions, orphans and files with multiple parents in a syncable directory",
+			Callback:    doctorSyncHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.BoolFlag{
+						Name:        "mergeIdentical",
+						Patterns:    []string{"--merge-identical"},
+						Description: "Trash files with the same name and md5 as an older file in the same directory",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "renameDuplicates",
+						Patterns:    []string{"--rename-duplicates"},
+						Description: "Rename all but the oldest of the files with the same name in a directory, i.e. 'name (1).ext'",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "dryRun",
+						Patterns:    []string{"--dry-run"},
+						Description: "Show what would have been repaired",
+						OmitValue:   true,
+					},
+				),
+			},
+		},
 		&cli.Handler{
 			Pattern:     "[global] sync download [options] <fileId> <path>",
 			Description: "Sync drive directory to local directory",
@@ -544,12 +930,41 @@ func main() {
 						Description: "Keep largest file when a conflict is encountered",
 						OmitValue:   true,
 					},
+					cli.BoolFlag{
+						Name:        "keepNewest",
+						Patterns:    []string{"--keep-newest"},
+						Description: "Keep the most recently modified file when a conflict is encountered",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "keepBoth",
+						Patterns:    []string{"--keep-both"},
+						Description: "Keep both files when a conflict is encountered, the file that would be overwritten is renamed to 'name (conflict <host> <date>).ext'",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "interactive",
+						Patterns:    []string{"--interactive"},
+						Description: "Ask what to do for every conflict, showing the size and modification time of both files",
+						OmitValue:   true,
+					},
 					cli.BoolFlag{
 						Name:        "deleteExtraneous",
 						Patterns:    []string{"--delete-extraneous"},
 						Description: "Delete extraneous local files",
 						OmitValue:   true,
 					},
+					cli.BoolFlag{
+						Name:        "export",
+						Patterns:    []string{"--export"},
+						Description: "Export google documents instead of skipping them, exported files are tracked by the modified time of the document and never uploaded",
+						OmitValue:   true,
+					},
+					cli.StringSliceFlag{
+						Name:        "exportMime",
+						Patterns:    []string{"--export-mime"},
+						Description: "Export mime per document type as type=mime, i.e. document=application/vnd.openxmlformats-officedocument.wordprocessingml.document, can be specified multiple times",
+					},
 					cli.BoolFlag{
 						Name:        "dryRun",
 						Patterns:    []string{"--dry-run"},
@@ -568,6 +983,30 @@ func main() {
 						Description:  fmt.Sprintf("Set timeout in seconds, use 0 for no timeout. Timeout is reached when no data is transferred in set amount of seconds, default: %d", DefaultTimeout),
 						DefaultValue: DefaultTimeout,
 					},
+					cli.IntFlag{
+						Name:         "parallel",
+						Patterns:     []string{"--parallel"},
+						Description:  fmt.Sprintf("Number of files to transfer concurrently, default: %d", DefaultParallel),
+						DefaultValue: DefaultParallel,
+					},
+					cli.StringFlag{
+						Name:         "verify",
+						Patterns:     []string{"--verify"},
+						Description:  fmt.Sprintf("What to do with files that fail md5 verification: remove, keep or off, default: %s", DefaultVerifyPolicy),
+						DefaultValue: DefaultVerifyPolicy,
+					},
+					cli.StringFlag{
+						Name:         "symlinks",
+						Patterns:     []string{"--symlinks"},
+						Description:  fmt.Sprintf("How to sync symlinks: follow, skip or preserve, default: %s", DefaultSymlinkPolicy),
+						DefaultValue: DefaultSymlinkPolicy,
+					},
+					cli.BoolFlag{
+						Name:        "skipInvalid",
+						Patterns:    []string{"--skip-invalid"},
+						Description: "Skip remote files with name collisions or unexpected parents instead of failing, see 'sync doctor'",
+						OmitValue:   true,
+					},
 				),
 			},
 		},
@@ -596,10 +1035,179 @@ func main() {
 						Description: "Keep largest file when a conflict is encountered",
 						OmitValue:   true,
 					},
+					cli.BoolFlag{
+						Name:        "keepNewest",
+						Patterns:    []string{"--keep-newest"},
+						Description: "Keep the most recently modified file when a conflict is encountered",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "keepBoth",
+						Patterns:    []string{"--keep-both"},
+						Description: "Keep both files when a conflict is encountered, the file that would be overwritten is renamed to 'name (conflict <host> <date>).ext'",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "interactive",
+						Patterns:    []string{"--interactive"},
+						Description: "Ask what to do for every conflict, showing the size and modification time of both files",
+						OmitValue:   true,
+					},
 					cli.BoolFlag{
 						Name:        "deleteExtraneous",
 						Patterns:    []string{"--delete-extraneous"},
-						Description: "Delete extraneous remote files",
+						Description: "Move extraneous remote files to trash",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "permanent",
+						Patterns:    []string{"--permanent"},
+						Description: "Delete remote files permanently instead of moving them to trash",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "dryRun",
+						Patterns:    []string{"--dry-run"},
+						Description: "Show what would have been transferred",
+						OmitValue:   true,
+					},
+					cli.StringFlag{
+						Name:        "planOut",
+						Patterns:    []string{"--plan-out"},
+						Description: "Write the actions that would be taken to a json file instead of syncing, the plan can be executed with sync apply",
+					},
+					cli.BoolFlag{
+						Name:        "noProgress",
+						Patterns:    []string{"--no-progress"},
+						Description: "Hide progress",
+						OmitValue:   true,
+					},
+					cli.IntFlag{
+						Name:         "timeout",
+						Patterns:     []string{"--timeout"},
+						Description:  fmt.Sprintf("Set timeout in seconds, use 0 for no timeout. Timeout is reached when no data is transferred in set amount of seconds, default: %d", DefaultTimeout),
+						DefaultValue: DefaultTimeout,
+					},
+					cli.IntFlag{
+						Name:         "chunksize",
+						Patterns:     []string{"--chunksize"},
+						Description:  fmt.Sprintf("Set chunk size in bytes, default: %d", DefaultUploadChunkSize),
+						DefaultValue: DefaultUploadChunkSize,
+					},
+					cli.IntFlag{
+						Name:         "parallel",
+						Patterns:     []string{"--parallel"},
+						Description:  fmt.Sprintf("Number of files to transfer concurrently, default: %d", DefaultParallel),
+						DefaultValue: DefaultParallel,
+					},
+					cli.StringFlag{
+						Name:         "verify",
+						Patterns:     []string{"--verify"},
+						Description:  fmt.Sprintf("What to do with files that fail md5 verification: remove, keep or off, default: %s", DefaultVerifyPolicy),
+						DefaultValue: DefaultVerifyPolicy,
+					},
+					cli.StringFlag{
+						Name:         "symlinks",
+						Patterns:     []string{"--symlinks"},
+						Description:  fmt.Sprintf("How to sync symlinks: follow, skip or preserve, default: %s", DefaultSymlinkPolicy),
+						DefaultValue: DefaultSymlinkPolicy,
+					},
+					cli.BoolFlag{
+						Name:        "skipInvalid",
+						Patterns:    []string{"--skip-invalid"},
+						Description: "Skip remote files with name collisions or unexpected parents instead of failing, see 'sync doctor'",
+						OmitValue:   true,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] sync apply [options] <plan>",
+			Description: "Execute a plan written by sync upload --plan-out",
+			Callback:    applySyncHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.BoolFlag{
+						Name:        "permanent",
+						Patterns:    []string{"--permanent"},
+						Description: "Delete remote files permanently instead of moving them to trash",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "noProgress",
+						Patterns:    []string{"--no-progress"},
+						Description: "Hide progress",
+						OmitValue:   true,
+					},
+					cli.IntFlag{
+						Name:         "timeout",
+						Patterns:     []string{"--timeout"},
+						Description:  fmt.Sprintf("Set timeout in seconds, use 0 for no timeout. Timeout is reached when no data is transferred in set amount of seconds, default: %d", DefaultTimeout),
+						DefaultValue: DefaultTimeout,
+					},
+					cli.IntFlag{
+						Name:         "chunksize",
+						Patterns:     []string{"--chunksize"},
+						Description:  fmt.Sprintf("Set chunk size in bytes, default: %d", DefaultUploadChunkSize),
+						DefaultValue: DefaultUploadChunkSize,
+					},
+					cli.StringFlag{
+						Name:         "verify",
+						Patterns:     []string{"--verify"},
+						Description:  fmt.Sprintf("What to do with files that fail md5 verification: remove, keep or off, default: %s", DefaultVerifyPolicy),
+						DefaultValue: DefaultVerifyPolicy,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] sync bidirectional [options] <path> <fileId>",
+			Description: "Sync local directory and drive directory in both directions",
+			Callback:    bidirectionalSyncHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.BoolFlag{
+						Name:        "keepRemote",
+						Patterns:    []string{"--keep-remote"},
+						Description: "Keep remote file when a conflict is encountered",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "keepLocal",
+						Patterns:    []string{"--keep-local"},
+						Description: "Keep local file when a conflict is encountered",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "keepLargest",
+						Patterns:    []string{"--keep-largest"},
+						Description: "Keep largest file when a conflict is encountered",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "keepNewest",
+						Patterns:    []string{"--keep-newest"},
+						Description: "Keep the most recently modified file when a conflict is encountered",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "keepBoth",
+						Patterns:    []string{"--keep-both"},
+						Description: "Keep both files when a conflict is encountered, the file that would be overwritten is renamed to 'name (conflict <host> <date>).ext'",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "interactive",
+						Patterns:    []string{"--interactive"},
+						Description: "Ask what to do for every conflict, showing the size and modification time of both files",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "permanent",
+						Patterns:    []string{"--permanent"},
+						Description: "Delete remote files permanently instead of moving them to trash",
 						OmitValue:   true,
 					},
 					cli.BoolFlag{
@@ -626,6 +1234,46 @@ func main() {
 						Description:  fmt.Sprintf("Set chunk size in bytes, default: %d", DefaultUploadChunkSize),
 						DefaultValue: DefaultUploadChunkSize,
 					},
+					cli.IntFlag{
+						Name:         "parallel",
+						Patterns:     []string{"--parallel"},
+						Description:  fmt.Sprintf("Number of files to transfer concurrently, default: %d", DefaultParallel),
+						DefaultValue: DefaultParallel,
+					},
+					cli.StringFlag{
+						Name:         "verify",
+						Patterns:     []string{"--verify"},
+						Description:  fmt.Sprintf("What to do with files that fail md5 verification: remove, keep or off, default: %s", DefaultVerifyPolicy),
+						DefaultValue: DefaultVerifyPolicy,
+					},
+					cli.StringFlag{
+						Name:         "symlinks",
+						Patterns:     []string{"--symlinks"},
+						Description:  fmt.Sprintf("How to sync symlinks: follow, skip or preserve, default: %s", DefaultSymlinkPolicy),
+						DefaultValue: DefaultSymlinkPolicy,
+					},
+					cli.BoolFlag{
+						Name:        "skipInvalid",
+						Patterns:    []string{"--skip-invalid"},
+						Description: "Skip remote files with name collisions or unexpected parents instead of failing, see 'sync doctor'",
+						OmitValue:   true,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] drives list [options]",
+			Description: "List shared drives",
+			Callback:    listDrivesHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.BoolFlag{
+						Name:        "skipHeader",
+						Patterns:    []string{"--no-header"},
+						Description: "Dont print the header",
+						OmitValue:   true,
+					},
 				),
 			},
 		},
@@ -754,7 +1402,7 @@ func main() {
 					cli.StringSliceFlag{
 						Name:        "parent",
 						Patterns:    []string{"-p", "--parent"},
-						Description: "Parent id, used to upload file to a specific directory, can be specified multiple times to give many parents",
+						Description: "Parent id or drive:/path, used to upload file to a specific directory, can be specified multiple times to give many parents",
 					},
 					cli.BoolFlag{
 						Name:        "noProgress",
@@ -783,6 +1431,17 @@ func main() {
 						Description: "Overwrite existing file",
 						OmitValue:   true,
 					},
+					cli.BoolFlag{
+						Name:        "skip",
+						Patterns:    []string{"-s", "--skip"},
+						Description: "Skip existing files",
+						OmitValue:   true,
+					},
+					cli.StringFlag{
+						Name:        "path",
+						Patterns:    []string{"--path"},
+						Description: "Export path",
+					},
 					cli.StringFlag{
 						Name:        "mime",
 						Patterns:    []string{"--mime"},
@@ -794,6 +1453,68 @@ func main() {
 						Description: "Print available mime types for given file",
 						OmitValue:   true,
 					},
+					cli.BoolFlag{
+						Name:        "stdout",
+						Patterns:    []string{"--stdout"},
+						Description: "Write exported content to stdout",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "noProgress",
+						Patterns:    []string{"--no-progress"},
+						Description: "Hide progress",
+						OmitValue:   true,
+					},
+					cli.IntFlag{
+						Name:         "timeout",
+						Patterns:     []string{"--timeout"},
+						Description:  fmt.Sprintf("Set timeout in seconds, use 0 for no timeout. Timeout is reached when no data is transferred in set amount of seconds, default: %d", DefaultTimeout),
+						DefaultValue: DefaultTimeout,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] export query [options] <query>",
+			Description: "Export all google documents matching query",
+			Callback:    exportQueryHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.BoolFlag{
+						Name:        "force",
+						Patterns:    []string{"-f", "--force"},
+						Description: "Overwrite existing file",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "skip",
+						Patterns:    []string{"-s", "--skip"},
+						Description: "Skip existing files",
+						OmitValue:   true,
+					},
+					cli.StringFlag{
+						Name:        "path",
+						Patterns:    []string{"--path"},
+						Description: "Export path",
+					},
+					cli.StringFlag{
+						Name:        "mime",
+						Patterns:    []string{"--mime"},
+						Description: "Mime type of exported files, defaults to the default export mime of each document type",
+					},
+					cli.BoolFlag{
+						Name:        "noProgress",
+						Patterns:    []string{"--no-progress"},
+						Description: "Hide progress",
+						OmitValue:   true,
+					},
+					cli.IntFlag{
+						Name:         "timeout",
+						Patterns:     []string{"--timeout"},
+						Description:  fmt.Sprintf("Set timeout in seconds, use 0 for no timeout. Timeout is reached when no data is transferred in set amount of seconds, default: %d", DefaultTimeout),
+						DefaultValue: DefaultTimeout,
+					},
 				),
 			},
 		},
@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/prasmussen/gdrive/auth"
@@ -18,6 +20,8 @@ const ClientId = "367116221053-7n0vf5akeru7on6o2fjinrecpdoe99eg.apps.googleuserc
 const ClientSecret = "1qsNodXNaWq1mQuBjUjmvhoO"
 const TokenFilename = "token_v2.json"
 const DefaultCacheFileName = "file_cache.json"
+const DefaultSyncStateDirName = "sync_state"
+const DefaultUploadSessionDirName = "upload_sessions"
 
 func listHandler(ctx cli.Context) {
 	args := ctx.Args()
@@ -25,7 +29,7 @@ func listHandler(ctx cli.Context) {
 		Out:         os.Stdout,
 		MaxFiles:    args.Int64("maxFiles"),
 		NameWidth:   args.Int64("nameWidth"),
-		Query:       args.String("query"),
+		Query:       listQuery(args),
 		SortOrder:   args.String("sortOrder"),
 		SkipHeader:  args.Bool("skipHeader"),
 		SizeInBytes: args.Bool("sizeInBytes"),
@@ -34,6 +38,15 @@ func listHandler(ctx cli.Context) {
 	checkErr(err)
 }
 
+func listDrivesHandler(ctx cli.Context) {
+	args := ctx.Args()
+	err := newDrive(args).ListDrives(drive.ListDrivesArgs{
+		Out:        os.Stdout,
+		SkipHeader: args.Bool("skipHeader"),
+	})
+	checkErr(err)
+}
+
 func listChangesHandler(ctx cli.Context) {
 	args := ctx.Args()
 	err := newDrive(args).ListChanges(drive.ListChangesArgs{
@@ -50,17 +63,24 @@ func listChangesHandler(ctx cli.Context) {
 func downloadHandler(ctx cli.Context) {
 	args := ctx.Args()
 	checkDownloadArgs(args)
-	err := newDrive(args).Download(drive.DownloadArgs{
-		Out:       os.Stdout,
-		Id:        args.String("fileId"),
-		Force:     args.Bool("force"),
-		Skip:      args.Bool("skip"),
-		Path:      args.String("path"),
-		Delete:    args.Bool("delete"),
-		Recursive: args.Bool("recursive"),
-		Stdout:    args.Bool("stdout"),
-		Progress:  progressWriter(args.Bool("noProgress")),
-		Timeout:   durationInSeconds(args.Int64("timeout")),
+	gdrive := newDrive(args)
+	err := gdrive.Download(drive.DownloadArgs{
+		Out:         os.Stdout,
+		Id:          resolveId(gdrive, args.String("fileId")),
+		Force:       args.Bool("force"),
+		Skip:        args.Bool("skip"),
+		Path:        args.String("path"),
+		Delete:      args.Bool("delete"),
+		Permanent:   args.Bool("permanent"),
+		Recursive:   args.Bool("recursive"),
+		Stdout:      args.Bool("stdout"),
+		Progress:    progressWriter(args.Bool("noProgress")),
+		Timeout:     durationInSeconds(args.Int64("timeout")),
+		Parallel:    int(args.Int64("parallel")),
+		Resume:      args.Bool("resume"),
+		Verify:      verifyPolicy(args),
+		Export:      args.Bool("export"),
+		ExportMimes: keyValues(args, "exportMime"),
 	})
 	checkErr(err)
 }
@@ -68,39 +88,53 @@ func downloadHandler(ctx cli.Context) {
 func downloadQueryHandler(ctx cli.Context) {
 	args := ctx.Args()
 	err := newDrive(args).DownloadQuery(drive.DownloadQueryArgs{
-		Out:       os.Stdout,
-		Query:     args.String("query"),
-		Force:     args.Bool("force"),
-		Skip:      args.Bool("skip"),
-		Recursive: args.Bool("recursive"),
-		Path:      args.String("path"),
-		Progress:  progressWriter(args.Bool("noProgress")),
+		Out:         os.Stdout,
+		Query:       args.String("query"),
+		Force:       args.Bool("force"),
+		Skip:        args.Bool("skip"),
+		Recursive:   args.Bool("recursive"),
+		Path:        args.String("path"),
+		Progress:    progressWriter(args.Bool("noProgress")),
+		Parallel:    int(args.Int64("parallel")),
+		Verify:      verifyPolicy(args),
+		Export:      args.Bool("export"),
+		ExportMimes: keyValues(args, "exportMime"),
 	})
 	checkErr(err)
 }
 
 func downloadSyncHandler(ctx cli.Context) {
 	args := ctx.Args()
-	cachePath := filepath.Join(args.String("configDir"), DefaultCacheFileName)
-	err := newDrive(args).DownloadSync(drive.DownloadSyncArgs{
+	configDir := getConfigDir(args)
+	cachePath := filepath.Join(configDir, DefaultCacheFileName)
+	gdrive := newDrive(args)
+	err := gdrive.DownloadSync(drive.DownloadSyncArgs{
 		Out:              os.Stdout,
 		Progress:         progressWriter(args.Bool("noProgress")),
 		Path:             args.String("path"),
-		RootId:           args.String("fileId"),
+		RootId:           resolveId(gdrive, args.String("fileId")),
 		DryRun:           args.Bool("dryRun"),
 		DeleteExtraneous: args.Bool("deleteExtraneous"),
 		Timeout:          durationInSeconds(args.Int64("timeout")),
 		Resolution:       conflictResolution(args),
 		Comparer:         NewCachedMd5Comparer(cachePath),
+		StateDir:         filepath.Join(configDir, DefaultSyncStateDirName),
+		Parallel:         int(args.Int64("parallel")),
+		Verify:           verifyPolicy(args),
+		Symlinks:         symlinkPolicy(args),
+		SkipInvalid:      args.Bool("skipInvalid"),
+		Export:           args.Bool("export"),
+		ExportMimes:      keyValues(args, "exportMime"),
 	})
 	checkErr(err)
 }
 
 func downloadRevisionHandler(ctx cli.Context) {
 	args := ctx.Args()
-	err := newDrive(args).DownloadRevision(drive.DownloadRevisionArgs{
+	gdrive := newDrive(args)
+	err := gdrive.DownloadRevision(drive.DownloadRevisionArgs{
 		Out:        os.Stdout,
-		FileId:     args.String("fileId"),
+		FileId:     resolveId(gdrive, args.String("fileId")),
 		RevisionId: args.String("revId"),
 		Force:      args.Bool("force"),
 		Stdout:     args.Bool("stdout"),
@@ -114,80 +148,157 @@ func downloadRevisionHandler(ctx cli.Context) {
 func uploadHandler(ctx cli.Context) {
 	args := ctx.Args()
 	checkUploadArgs(args)
-	err := newDrive(args).Upload(drive.UploadArgs{
+	gdrive := newDrive(args)
+	err := gdrive.Upload(drive.UploadArgs{
 		Out:         os.Stdout,
 		Progress:    progressWriter(args.Bool("noProgress")),
 		Path:        args.String("path"),
 		Name:        args.String("name"),
 		Description: args.String("description"),
-		Parents:     args.StringSlice("parent"),
+		Parents:     resolveIds(gdrive, args.StringSlice("parent")),
 		Mime:        args.String("mime"),
+		Properties:  keyValues(args, "property"),
 		Recursive:   args.Bool("recursive"),
 		Share:       args.Bool("share"),
 		Delete:      args.Bool("delete"),
 		ChunkSize:   args.Int64("chunksize"),
 		Timeout:     durationInSeconds(args.Int64("timeout")),
+		Parallel:    int(args.Int64("parallel")),
+		SessionDir:  filepath.Join(getConfigDir(args), DefaultUploadSessionDirName),
+		Verify:      verifyPolicy(args),
+	})
+	checkErr(err)
+}
+
+func uploadSessionsHandler(ctx cli.Context) {
+	args := ctx.Args()
+	err := drive.UploadSessions(drive.UploadSessionsArgs{
+		Out:        os.Stdout,
+		SessionDir: filepath.Join(getConfigDir(args), DefaultUploadSessionDirName),
+		Clean:      args.Bool("clean"),
+		All:        args.Bool("all"),
+		SkipHeader: args.Bool("skipHeader"),
+		Renderer:   newRenderer(args),
 	})
 	checkErr(err)
 }
 
 func uploadStdinHandler(ctx cli.Context) {
 	args := ctx.Args()
-	err := newDrive(args).UploadStream(drive.UploadStreamArgs{
+	gdrive := newDrive(args)
+	err := gdrive.UploadStream(drive.UploadStreamArgs{
 		Out:         os.Stdout,
 		In:          os.Stdin,
 		Name:        args.String("name"),
 		Description: args.String("description"),
-		Parents:     args.StringSlice("parent"),
+		Parents:     resolveIds(gdrive, args.StringSlice("parent")),
 		Mime:        args.String("mime"),
+		Properties:  keyValues(args, "property"),
 		Share:       args.Bool("share"),
 		ChunkSize:   args.Int64("chunksize"),
 		Timeout:     durationInSeconds(args.Int64("timeout")),
 		Progress:    progressWriter(args.Bool("noProgress")),
+		Verify:      verifyPolicy(args),
 	})
 	checkErr(err)
 }
 
 func uploadSyncHandler(ctx cli.Context) {
 	args := ctx.Args()
-	cachePath := filepath.Join(args.String("configDir"), DefaultCacheFileName)
-	err := newDrive(args).UploadSync(drive.UploadSyncArgs{
+	configDir := getConfigDir(args)
+	cachePath := filepath.Join(configDir, DefaultCacheFileName)
+	gdrive := newDrive(args)
+	err := gdrive.UploadSync(drive.UploadSyncArgs{
 		Out:              os.Stdout,
 		Progress:         progressWriter(args.Bool("noProgress")),
 		Path:             args.String("path"),
-		RootId:           args.String("fileId"),
+		RootId:           resolveId(gdrive, args.String("fileId")),
 		DryRun:           args.Bool("dryRun"),
 		DeleteExtraneous: args.Bool("deleteExtraneous"),
+		Permanent:        args.Bool("permanent"),
 		ChunkSize:        args.Int64("chunksize"),
 		Timeout:          durationInSeconds(args.Int64("timeout")),
 		Resolution:       conflictResolution(args),
 		Comparer:         NewCachedMd5Comparer(cachePath),
+		StateDir:         filepath.Join(configDir, DefaultSyncStateDirName),
+		Parallel:         int(args.Int64("parallel")),
+		SessionDir:       filepath.Join(configDir, DefaultUploadSessionDirName),
+		Verify:           verifyPolicy(args),
+		Symlinks:         symlinkPolicy(args),
+		SkipInvalid:      args.Bool("skipInvalid"),
+		PlanOut:          args.String("planOut"),
+	})
+	checkErr(err)
+}
+
+func applySyncHandler(ctx cli.Context) {
+	args := ctx.Args()
+	configDir := getConfigDir(args)
+	err := newDrive(args).SyncApply(drive.SyncApplyArgs{
+		Out:        os.Stdout,
+		Progress:   progressWriter(args.Bool("noProgress")),
+		PlanPath:   args.String("plan"),
+		ChunkSize:  args.Int64("chunksize"),
+		Timeout:    durationInSeconds(args.Int64("timeout")),
+		StateDir:   filepath.Join(configDir, DefaultSyncStateDirName),
+		SessionDir: filepath.Join(configDir, DefaultUploadSessionDirName),
+		Verify:     verifyPolicy(args),
+		Permanent:  args.Bool("permanent"),
+	})
+	checkErr(err)
+}
+
+func bidirectionalSyncHandler(ctx cli.Context) {
+	args := ctx.Args()
+	configDir := getConfigDir(args)
+	cachePath := filepath.Join(configDir, DefaultCacheFileName)
+	gdrive := newDrive(args)
+	err := gdrive.BidirectionalSync(drive.BidirectionalSyncArgs{
+		Out:         os.Stdout,
+		Progress:    progressWriter(args.Bool("noProgress")),
+		Path:        args.String("path"),
+		RootId:      resolveId(gdrive, args.String("fileId")),
+		DryRun:      args.Bool("dryRun"),
+		ChunkSize:   args.Int64("chunksize"),
+		Timeout:     durationInSeconds(args.Int64("timeout")),
+		Resolution:  conflictResolution(args),
+		Comparer:    NewCachedMd5Comparer(cachePath),
+		StateDir:    filepath.Join(configDir, DefaultSyncStateDirName),
+		Parallel:    int(args.Int64("parallel")),
+		SessionDir:  filepath.Join(configDir, DefaultUploadSessionDirName),
+		Verify:      verifyPolicy(args),
+		Symlinks:    symlinkPolicy(args),
+		SkipInvalid: args.Bool("skipInvalid"),
+		Permanent:   args.Bool("permanent"),
 	})
 	checkErr(err)
 }
 
 func updateHandler(ctx cli.Context) {
 	args := ctx.Args()
-	err := newDrive(args).Update(drive.UpdateArgs{
+	gdrive := newDrive(args)
+	err := gdrive.Update(drive.UpdateArgs{
 		Out:         os.Stdout,
-		Id:          args.String("fileId"),
+		Id:          resolveId(gdrive, args.String("fileId")),
 		Path:        args.String("path"),
 		Name:        args.String("name"),
 		Description: args.String("description"),
-		Parents:     args.StringSlice("parent"),
+		Parents:     resolveIds(gdrive, args.StringSlice("parent")),
 		Mime:        args.String("mime"),
 		Progress:    progressWriter(args.Bool("noProgress")),
 		ChunkSize:   args.Int64("chunksize"),
 		Timeout:     durationInSeconds(args.Int64("timeout")),
+		Verify:      verifyPolicy(args),
 	})
 	checkErr(err)
 }
 
 func infoHandler(ctx cli.Context) {
 	args := ctx.Args()
-	err := newDrive(args).Info(drive.FileInfoArgs{
+	gdrive := newDrive(args)
+	err := gdrive.Info(drive.FileInfoArgs{
 		Out:         os.Stdout,
-		Id:          args.String("fileId"),
+		Id:          resolveId(gdrive, args.String("fileId")),
 		SizeInBytes: args.Bool("sizeInBytes"),
 	})
 	checkErr(err)
@@ -195,11 +306,12 @@ func infoHandler(ctx cli.Context) {
 
 func importHandler(ctx cli.Context) {
 	args := ctx.Args()
-	err := newDrive(args).Import(drive.ImportArgs{
+	gdrive := newDrive(args)
+	err := gdrive.Import(drive.ImportArgs{
 		Mime:     args.String("mime"),
 		Out:      os.Stdout,
 		Path:     args.String("path"),
-		Parents:  args.StringSlice("parent"),
+		Parents:  resolveIds(gdrive, args.StringSlice("parent")),
 		Progress: progressWriter(args.Bool("noProgress")),
 	})
 	checkErr(err)
@@ -207,21 +319,43 @@ func importHandler(ctx cli.Context) {
 
 func exportHandler(ctx cli.Context) {
 	args := ctx.Args()
-	err := newDrive(args).Export(drive.ExportArgs{
+	gdrive := newDrive(args)
+	err := gdrive.Export(drive.ExportArgs{
 		Out:        os.Stdout,
-		Id:         args.String("fileId"),
+		Progress:   progressWriter(args.Bool("noProgress")),
+		Id:         resolveId(gdrive, args.String("fileId")),
+		Path:       args.String("path"),
 		Mime:       args.String("mime"),
 		PrintMimes: args.Bool("printMimes"),
 		Force:      args.Bool("force"),
+		Skip:       args.Bool("skip"),
+		Stdout:     args.Bool("stdout"),
+		Timeout:    durationInSeconds(args.Int64("timeout")),
+	})
+	checkErr(err)
+}
+
+func exportQueryHandler(ctx cli.Context) {
+	args := ctx.Args()
+	err := newDrive(args).ExportQuery(drive.ExportQueryArgs{
+		Out:      os.Stdout,
+		Progress: progressWriter(args.Bool("noProgress")),
+		Query:    args.String("query"),
+		Path:     args.String("path"),
+		Mime:     args.String("mime"),
+		Force:    args.Bool("force"),
+		Skip:     args.Bool("skip"),
+		Timeout:  durationInSeconds(args.Int64("timeout")),
 	})
 	checkErr(err)
 }
 
 func listRevisionsHandler(ctx cli.Context) {
 	args := ctx.Args()
-	err := newDrive(args).ListRevisions(drive.ListRevisionsArgs{
+	gdrive := newDrive(args)
+	err := gdrive.ListRevisions(drive.ListRevisionsArgs{
 		Out:         os.Stdout,
-		Id:          args.String("fileId"),
+		Id:          resolveId(gdrive, args.String("fileId")),
 		NameWidth:   args.Int64("nameWidth"),
 		SizeInBytes: args.Bool("sizeInBytes"),
 		SkipHeader:  args.Bool("skipHeader"),
@@ -231,20 +365,23 @@ func listRevisionsHandler(ctx cli.Context) {
 
 func mkdirHandler(ctx cli.Context) {
 	args := ctx.Args()
-	err := newDrive(args).Mkdir(drive.MkdirArgs{
+	gdrive := newDrive(args)
+	err := gdrive.Mkdir(drive.MkdirArgs{
 		Out:         os.Stdout,
 		Name:        args.String("name"),
 		Description: args.String("description"),
-		Parents:     args.StringSlice("parent"),
+		Parents:     resolveIds(gdrive, args.StringSlice("parent")),
+		Properties:  keyValues(args, "property"),
 	})
 	checkErr(err)
 }
 
 func shareHandler(ctx cli.Context) {
 	args := ctx.Args()
-	err := newDrive(args).Share(drive.ShareArgs{
+	gdrive := newDrive(args)
+	err := gdrive.Share(drive.ShareArgs{
 		Out:          os.Stdout,
-		FileId:       args.String("fileId"),
+		FileId:       resolveId(gdrive, args.String("fileId")),
 		Role:         args.String("role"),
 		Type:         args.String("type"),
 		Email:        args.String("email"),
@@ -256,18 +393,20 @@ func shareHandler(ctx cli.Context) {
 
 func shareListHandler(ctx cli.Context) {
 	args := ctx.Args()
-	err := newDrive(args).ListPermissions(drive.ListPermissionsArgs{
+	gdrive := newDrive(args)
+	err := gdrive.ListPermissions(drive.ListPermissionsArgs{
 		Out:    os.Stdout,
-		FileId: args.String("fileId"),
+		FileId: resolveId(gdrive, args.String("fileId")),
 	})
 	checkErr(err)
 }
 
 func shareRevokeHandler(ctx cli.Context) {
 	args := ctx.Args()
-	err := newDrive(args).RevokePermission(drive.RevokePermissionArgs{
+	gdrive := newDrive(args)
+	err := gdrive.RevokePermission(drive.RevokePermissionArgs{
 		Out:          os.Stdout,
-		FileId:       args.String("fileId"),
+		FileId:       resolveId(gdrive, args.String("fileId")),
 		PermissionId: args.String("permissionId"),
 	})
 	checkErr(err)
@@ -275,10 +414,142 @@ func shareRevokeHandler(ctx cli.Context) {
 
 func deleteHandler(ctx cli.Context) {
 	args := ctx.Args()
-	err := newDrive(args).Delete(drive.DeleteArgs{
+	gdrive := newDrive(args)
+	err := gdrive.Delete(drive.DeleteArgs{
 		Out:       os.Stdout,
-		Id:        args.String("fileId"),
+		Id:        resolveId(gdrive, args.String("fileId")),
 		Recursive: args.Bool("recursive"),
+		Permanent: args.Bool("permanent"),
+	})
+	checkErr(err)
+}
+
+func copyHandler(ctx cli.Context) {
+	args := ctx.Args()
+	gdrive := newDrive(args)
+
+	err := gdrive.Copy(drive.CopyArgs{
+		Out:       os.Stdout,
+		Id:        resolveId(gdrive, args.String("fileId")),
+		Parent:    resolveId(gdrive, args.String("parent")),
+		Name:      args.String("name"),
+		Recursive: args.Bool("recursive"),
+	})
+	checkErr(err)
+}
+
+func moveHandler(ctx cli.Context) {
+	args := ctx.Args()
+	gdrive := newDrive(args)
+
+	err := gdrive.Move(drive.MoveArgs{
+		Out:       os.Stdout,
+		Id:        resolveId(gdrive, args.String("fileId")),
+		Parent:    resolveId(gdrive, args.String("parent")),
+		Recursive: args.Bool("recursive"),
+	})
+	checkErr(err)
+}
+
+func renameHandler(ctx cli.Context) {
+	args := ctx.Args()
+	gdrive := newDrive(args)
+	err := gdrive.Rename(drive.RenameArgs{
+		Out:  os.Stdout,
+		Id:   resolveId(gdrive, args.String("fileId")),
+		Name: args.String("newName"),
+	})
+	checkErr(err)
+}
+
+func metaSetHandler(ctx cli.Context) {
+	args := ctx.Args()
+	gdrive := newDrive(args)
+	err := gdrive.MetaSet(drive.MetaSetArgs{
+		Out:           os.Stdout,
+		Id:            resolveId(gdrive, args.String("fileId")),
+		Name:          args.String("name"),
+		Description:   args.String("description"),
+		Starred:       optionalBool(args, "starred"),
+		Mime:          args.String("mime"),
+		Properties:    keyValues(args, "property"),
+		AppProperties: keyValues(args, "appProperty"),
+		ModifiedTime:  args.String("modifiedTime"),
+	})
+	checkErr(err)
+}
+
+func propsGetHandler(ctx cli.Context) {
+	args := ctx.Args()
+	gdrive := newDrive(args)
+	err := gdrive.PropsGet(drive.PropsGetArgs{
+		Out:        os.Stdout,
+		Id:         resolveId(gdrive, args.String("fileId")),
+		SkipHeader: args.Bool("skipHeader"),
+	})
+	checkErr(err)
+}
+
+func propsSetHandler(ctx cli.Context) {
+	args := ctx.Args()
+	gdrive := newDrive(args)
+	err := gdrive.PropsSet(drive.PropsSetArgs{
+		Out:   os.Stdout,
+		Id:    resolveId(gdrive, args.String("fileId")),
+		Key:   args.String("key"),
+		Value: args.String("value"),
+		App:   args.Bool("app"),
+	})
+	checkErr(err)
+}
+
+func propsDeleteHandler(ctx cli.Context) {
+	args := ctx.Args()
+	gdrive := newDrive(args)
+	err := gdrive.PropsDelete(drive.PropsDeleteArgs{
+		Out: os.Stdout,
+		Id:  resolveId(gdrive, args.String("fileId")),
+		Key: args.String("key"),
+		App: args.Bool("app"),
+	})
+	checkErr(err)
+}
+
+func trashHandler(ctx cli.Context) {
+	args := ctx.Args()
+	gdrive := newDrive(args)
+	err := gdrive.Trash(drive.TrashArgs{
+		Out: os.Stdout,
+		Id:  resolveId(gdrive, args.String("fileId")),
+	})
+	checkErr(err)
+}
+
+func untrashHandler(ctx cli.Context) {
+	args := ctx.Args()
+	err := newDrive(args).Untrash(drive.TrashArgs{
+		Out: os.Stdout,
+		Id:  args.String("fileId"),
+	})
+	checkErr(err)
+}
+
+func listTrashHandler(ctx cli.Context) {
+	args := ctx.Args()
+	err := newDrive(args).ListTrash(drive.ListTrashArgs{
+		Out:         os.Stdout,
+		MaxFiles:    args.Int64("maxFiles"),
+		NameWidth:   args.Int64("nameWidth"),
+		SkipHeader:  args.Bool("skipHeader"),
+		SizeInBytes: args.Bool("sizeInBytes"),
+	})
+	checkErr(err)
+}
+
+func emptyTrashHandler(ctx cli.Context) {
+	args := ctx.Args()
+	err := newDrive(args).EmptyTrash(drive.EmptyTrashArgs{
+		Out: os.Stdout,
 	})
 	checkErr(err)
 }
@@ -294,9 +565,10 @@ func listSyncHandler(ctx cli.Context) {
 
 func listRecursiveSyncHandler(ctx cli.Context) {
 	args := ctx.Args()
-	err := newDrive(args).ListRecursiveSync(drive.ListRecursiveSyncArgs{
+	gdrive := newDrive(args)
+	err := gdrive.ListRecursiveSync(drive.ListRecursiveSyncArgs{
 		Out:         os.Stdout,
-		RootId:      args.String("fileId"),
+		RootId:      resolveId(gdrive, args.String("fileId")),
 		SkipHeader:  args.Bool("skipHeader"),
 		PathWidth:   args.Int64("pathWidth"),
 		SizeInBytes: args.Bool("sizeInBytes"),
@@ -305,11 +577,25 @@ func listRecursiveSyncHandler(ctx cli.Context) {
 	checkErr(err)
 }
 
+func doctorSyncHandler(ctx cli.Context) {
+	args := ctx.Args()
+	gdrive := newDrive(args)
+	err := gdrive.SyncDoctor(drive.SyncDoctorArgs{
+		Out:              os.Stdout,
+		RootId:           resolveId(gdrive, args.String("fileId")),
+		MergeIdentical:   args.Bool("mergeIdentical"),
+		RenameDuplicates: args.Bool("renameDuplicates"),
+		DryRun:           args.Bool("dryRun"),
+	})
+	checkErr(err)
+}
+
 func deleteRevisionHandler(ctx cli.Context) {
 	args := ctx.Args()
-	err := newDrive(args).DeleteRevision(drive.DeleteRevisionArgs{
+	gdrive := newDrive(args)
+	err := gdrive.DeleteRevision(drive.DeleteRevisionArgs{
 		Out:        os.Stdout,
-		FileId:     args.String("fileId"),
+		FileId:     resolveId(gdrive, args.String("fileId")),
 		RevisionId: args.String("revId"),
 	})
 	checkErr(err)
@@ -377,6 +663,18 @@ func getConfigDir(args cli.Arguments) string {
 }
 
 func newDrive(args cli.Arguments) *drive.Drive {
+	if args.String("localBackend") != "" {
+		backend, err := drive.NewLocalBackend(args.String("localBackend"))
+		if err != nil {
+			ExitF("Failed opening local backend: %s", err.Error())
+		}
+
+		client := drive.NewWithBackend(backend)
+		client.SetRenderer(newRenderer(args))
+		setDriveId(client, args)
+		return client
+	}
+
 	oauth, err := getOauthClient(args)
 	if err != nil {
 		ExitF("Failed getting oauth client: %s", err.Error())
@@ -387,9 +685,23 @@ func newDrive(args cli.Arguments) *drive.Drive {
 		ExitF("Failed getting drive: %s", err.Error())
 	}
 
+	client.SetRenderer(newRenderer(args))
+	setDriveId(client, args)
 	return client
 }
 
+func setDriveId(client *drive.Drive, args cli.Arguments) {
+	if args.String("driveId") != "" {
+		checkErr(client.SetDriveId(args.String("driveId")))
+	}
+}
+
+func newRenderer(args cli.Arguments) drive.Renderer {
+	renderer, err := drive.NewRenderer(args.String("output"))
+	checkErr(err)
+	return renderer
+}
+
 func authCodePrompt(url string) func() string {
 	return func() string {
 		fmt.Println("Authentication needed")
@@ -417,27 +729,104 @@ func durationInSeconds(seconds int64) time.Duration {
 }
 
 func conflictResolution(args cli.Arguments) drive.ConflictResolution {
-	keepLocal := args.Bool("keepLocal")
-	keepRemote := args.Bool("keepRemote")
-	keepLargest := args.Bool("keepLargest")
+	resolutions := map[string]drive.ConflictResolution{
+		"keepLocal":   drive.KeepLocal,
+		"keepRemote":  drive.KeepRemote,
+		"keepLargest": drive.KeepLargest,
+		"keepNewest":  drive.KeepNewest,
+		"keepBoth":    drive.KeepBoth,
+		"interactive": drive.NewInteractiveResolution(os.Stdin, os.Stdout),
+	}
+
+	var resolution drive.ConflictResolution
+	for _, key := range []string{"keepLocal", "keepRemote", "keepLargest", "keepNewest", "keepBoth", "interactive"} {
+		if !args.Bool(key) {
+			continue
+		}
 
-	if (keepLocal && keepRemote) || (keepLocal && keepLargest) || (keepRemote && keepLargest) {
-		ExitF("Only one conflict resolution flag can be given")
+		if resolution != nil {
+			ExitF("Only one conflict resolution flag can be given")
+		}
+		resolution = resolutions[key]
+	}
+
+	return resolution
+}
+
+// Returns nil when the flag is not given
+func optionalBool(args cli.Arguments, key string) *bool {
+	value := args.String(key)
+	if value == "" {
+		return nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		ExitF("Invalid value '%s' for %s, must be true or false", value, key)
+	}
+	return &b
+}
+
+// Parses key=value pairs given with a string slice flag
+func keyValues(args cli.Arguments, key string) map[string]string {
+	values := map[string]string{}
+	for _, kv := range args.StringSlice(key) {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			ExitF("Invalid %s '%s', must be given as key=value", key, kv)
+		}
+		values[parts[0]] = parts[1]
 	}
+	return values
+}
+
+func resolveId(gdrive *drive.Drive, id string) string {
+	resolved, err := gdrive.ResolveId(id)
+	checkErr(err)
+	return resolved
+}
 
-	if keepLocal {
-		return drive.KeepLocal
+func resolveIds(gdrive *drive.Drive, ids []string) []string {
+	resolved, err := gdrive.ResolveIds(ids)
+	checkErr(err)
+	return resolved
+}
+
+// Files in shared drives are owned by the drive, not by me
+func listQuery(args cli.Arguments) string {
+	query := args.String("query")
+	if args.String("driveId") != "" && query == DefaultQuery {
+		return DefaultSharedDriveQuery
 	}
+	return query
+}
 
-	if keepRemote {
-		return drive.KeepRemote
+func verifyPolicy(args cli.Arguments) drive.VerifyPolicy {
+	switch args.String("verify") {
+	case "remove":
+		return drive.VerifyRemove
+	case "keep":
+		return drive.VerifyKeep
+	case "off":
+		return drive.VerifyOff
 	}
 
-	if keepLargest {
-		return drive.KeepLargest
+	ExitF("Invalid verify policy '%s', must be one of remove, keep or off", args.String("verify"))
+	return drive.VerifyOff
+}
+
+func symlinkPolicy(args cli.Arguments) drive.SymlinkPolicy {
+	switch args.String("symlinks") {
+	case "skip":
+		return drive.SymlinkSkip
+	case "follow":
+		return drive.SymlinkFollow
+	case "preserve":
+		return drive.SymlinkPreserve
 	}
 
-	return drive.NoResolution
+	ExitF("Invalid symlink policy '%s', must be one of follow, skip or preserve", args.String("symlinks"))
+	return drive.SymlinkSkip
 }
 
 func checkUploadArgs(args cli.Arguments) {